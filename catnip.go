@@ -2,7 +2,6 @@ package catnip
 
 import (
 	"context"
-	"sync"
 
 	"github.com/noriah/catnip/input"
 	"github.com/noriah/catnip/processor"
@@ -18,35 +17,186 @@ type StartFunc func(ctx context.Context) (context.Context, error)
 type CleanupFunc func() error
 
 func Run(cfg *Config, ctx context.Context) error {
+	var updates <-chan update
+
+	if cfg.Control != nil {
+		detach, err := cfg.Control.attach()
+		if err != nil {
+			return err
+		}
+		defer detach()
+
+		updates = cfg.Control.updates
+	}
+
+	return run(ctx, *cfg, updates)
+}
+
+// update is a change to the config of a running pipeline. The result is sent
+// on done.
+type update struct {
+	fn   func(*Config) error
+	done chan error
+}
+
+// run sets up the output and captures until ctx is done or capture fails.
+// Capture is restarted with the changed config for every update, without the
+// output being set up again.
+func run(ctx context.Context, cfg Config, updates <-chan update) error {
 	if err := cfg.Validate(); err != nil {
 		return err
 	}
 
-	inputBuffers := input.MakeBuffers(cfg.ChannelCount, cfg.SampleSize)
+	output := cfg.output()
 
-	procConfig := processor.Config{
-		SampleRate:   cfg.SampleRate,
-		SampleSize:   cfg.SampleSize,
-		ChannelCount: cfg.ChannelCount,
-		ProcessRate:  cfg.ProcessRate,
-		Buffers:      inputBuffers,
-		Analyzer:     cfg.Analyzer,
-		Output:       cfg.Output,
-		Smoother:     cfg.Smoother,
-		Windower:     cfg.Windower,
+	sess, err := openSession(&cfg, output)
+	if err != nil {
+		return err
 	}
 
-	var vis processor.Processor
+	defer func() {
+		if sess != nil {
+			sess.close()
+		}
+	}()
 
-	if cfg.UseThreaded {
-		vis = processor.NewThreaded(procConfig)
-	} else {
-		vis = processor.New(procConfig)
+	ctx, cleanup, err := startOutput(ctx, &cfg, output)
+	if err != nil {
+		return err
 	}
+	defer cleanup()
+
+	for {
+		sessCtx, cancel := context.WithCancel(ctx)
+		errc := make(chan error, 1)
+
+		go func(s *session) {
+			errc <- s.run(sessCtx)
+		}(sess)
+
+		var u update
+
+		select {
+		case err := <-errc:
+			cancel()
+			return err
 
+		case u = <-updates:
+		}
+
+		// drain the session before anything it uses is changed.
+		cancel()
+		if err := <-errc; err != nil {
+			u.done <- err
+			return err
+		}
+
+		sess.close()
+
+		if sess, err = reopen(&cfg, output, u); err != nil {
+			return err
+		}
+	}
+}
+
+// startOutput sets up and starts output. cleanup must be called at the end of
+// the run if it succeeds.
+func startOutput(ctx context.Context, cfg *Config, output processor.FrameOutput) (_ context.Context, _ func(), err error) {
+	var cleanups []func() error
+
+	cleanup := func() {
+		for idx := len(cleanups) - 1; idx >= 0; idx-- {
+			cleanups[idx]()
+		}
+	}
+
+	defer func() {
+		if err != nil {
+			cleanup()
+		}
+	}()
+
+	if cfg.SetupFunc != nil {
+		if err := cfg.SetupFunc(); err != nil {
+			return ctx, nil, err
+		}
+	}
+
+	if cfg.CleanupFunc != nil {
+		cleanups = append(cleanups, cfg.CleanupFunc)
+	}
+
+	if cfg.StartFunc != nil {
+		if ctx, err = cfg.StartFunc(ctx); err != nil {
+			return ctx, nil, err
+		}
+	}
+
+	if lc, ok := output.(processor.Lifecycle); ok {
+		if err := lc.Setup(cfg.stream()); err != nil {
+			return ctx, nil, errors.Wrap(err, "failed to set up the output")
+		}
+
+		cleanups = append(cleanups, lc.Cleanup)
+
+		if ctx, err = lc.Start(ctx); err != nil {
+			return ctx, nil, errors.Wrap(err, "failed to start the output")
+		}
+	}
+
+	return ctx, cleanup, nil
+}
+
+// reopen applies u to cfg and opens a session with it. If that fails the error
+// is sent to the requester and a session with the old config is opened.
+func reopen(cfg *Config, output processor.FrameOutput, u update) (*session, error) {
+	next := *cfg
+
+	err := u.fn(&next)
+	if err == nil {
+		err = next.Validate()
+	}
+
+	var sess *session
+	if err == nil {
+		sess, err = openSession(&next, output)
+	}
+
+	if err != nil {
+		u.done <- err
+		return openSession(cfg, output)
+	}
+
+	*cfg = next
+
+	if so, ok := output.(processor.StreamOutput); ok {
+		if err := so.SetStream(cfg.stream()); err != nil {
+			sess.close()
+			err = errors.Wrap(err, "failed to change the output stream")
+			u.done <- err
+			return nil, err
+		}
+	}
+
+	u.done <- nil
+
+	return sess, nil
+}
+
+// session is one capture: a backend reading a device into buffers that a
+// processor works on.
+type session struct {
+	backend input.Backend
+	audio   input.Session
+	vis     processor.Processor
+	buffers *input.TripleBuffer
+}
+
+// openSession starts capture for cfg. Processing starts with run.
+func openSession(cfg *Config, output processor.FrameOutput) (*session, error) {
 	backend, err := input.InitBackend(cfg.Backend)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	sessConfig := input.SessionConfig{
@@ -55,44 +205,79 @@ func Run(cfg *Config, ctx context.Context) error {
 		SampleRate: cfg.SampleRate,
 	}
 
+	if cfg.Metrics != nil {
+		sessConfig.Stats = &cfg.Metrics.Input
+	}
+
 	if sessConfig.Device, err = input.GetDevice(backend, cfg.Device); err != nil {
-		return err
+		backend.Close()
+		return nil, err
 	}
 
 	audio, err := backend.Start(sessConfig)
-	defer backend.Close()
-
 	if err != nil {
-		return errors.Wrap(err, "failed to start the input backend")
+		backend.Close()
+		return nil, errors.Wrap(err, "failed to start the input backend")
 	}
 
-	if cfg.SetupFunc != nil {
-		if err := cfg.SetupFunc(); err != nil {
-			return err
-		}
+	s := &session{
+		backend: backend,
+		audio:   audio,
+		buffers: input.NewTripleBuffer(cfg.ChannelCount, cfg.SampleSize),
 	}
+	s.buffers.Stats = sessConfig.Stats
 
-	if cfg.CleanupFunc != nil {
-		defer cfg.CleanupFunc()
+	procConfig := processor.Config{
+		SampleRate:   cfg.SampleRate,
+		SampleSize:   cfg.SampleSize,
+		ChannelCount: cfg.ChannelCount,
+		ProcessRate:  cfg.ProcessRate,
+		Workers:      cfg.Workers,
+		Input:        s.buffers,
+		Analyzer:     cfg.Analyzer,
+		FrameOutput:  output,
+		Smoother:     cfg.Smoother,
+		NoiseFloor:   cfg.NoiseFloor,
+		Stages:       cfg.Stages,
+		Windower:     cfg.Windower,
+		Filter:       cfg.Filter,
+		Meter:        cfg.Meter,
+		Features:     cfg.Features,
+		Key:          cfg.Key,
+		Metrics:      cfg.Metrics,
 	}
 
-	if cfg.StartFunc != nil {
-		if ctx, err = cfg.StartFunc(ctx); err != nil {
-			return err
-		}
+	if cfg.UseThreaded {
+		s.vis = processor.NewThreaded(procConfig)
+	} else {
+		s.vis = processor.New(procConfig)
 	}
 
+	return s, nil
+}
+
+// run processes what is captured until ctx is done or either side fails. The
+// processor is stopped when it returns.
+func (s *session) run(ctx context.Context) error {
 	kickChan := make(chan bool, 1)
-	mu := &sync.Mutex{}
 
-	ctx = vis.Start(ctx, kickChan, mu)
-	defer vis.Stop()
+	ctx = s.vis.Start(ctx, kickChan)
+	defer s.vis.Stop()
 
-	if err := audio.Start(ctx, inputBuffers, kickChan, mu); err != nil {
-		if !errors.Is(ctx.Err(), context.Canceled) {
-			return errors.Wrap(err, "failed to start input session")
-		}
+	err := s.audio.Start(ctx, s.buffers, kickChan)
+
+	// the processor cancels with its error, see processor.OutputError.
+	if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.Canceled) {
+		return errors.Wrap(cause, "processing failed")
+	}
+
+	if err != nil && !errors.Is(ctx.Err(), context.Canceled) {
+		return errors.Wrap(err, "failed to start input session")
 	}
 
 	return nil
 }
+
+func (s *session) close() error {
+	return s.backend.Close()
+}
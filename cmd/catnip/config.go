@@ -2,8 +2,13 @@ package main
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/noriah/catnip/dsp"
+	"github.com/noriah/catnip/dsp/filter"
+	"github.com/noriah/catnip/dsp/scale"
+	"github.com/noriah/catnip/dsp/window"
+	"github.com/noriah/catnip/fft"
 	"github.com/noriah/catnip/graphic"
 	"github.com/noriah/catnip/input"
 )
@@ -22,6 +27,8 @@ type config struct {
 	smoothingMethod int
 	// Size of window used for averaging methods.
 	smoothingAverageWindowSize int
+	// Noise floor removal method used before smoothing.
+	noiseMethod int
 	// SampleSize is how much we draw. Play with it
 	sampleSize int
 	// FrameRate is the number of frames to draw every second (0 draws it every
@@ -39,12 +46,38 @@ type config struct {
 	drawType int
 	// Combine determines if we merge streams (stereo -> mono)
 	combine bool
+	// Analyzer is the analyzer to use (spectrum, chroma)
+	analyzer string
+	// BinMethod is how fft values are combined into a bin
+	binMethod string
+	// Filter is the pre-filter spec, see filter.Parse
+	filter string
+	// Stages is the list of stages run on the bars, see parseStages
+	stages string
+	// FFTPlanner is how hard fftw looks for a fast plan
+	fftPlanner string
+	// FFTWisdom is the fftw wisdom file (empty to disable)
+	fftWisdom string
+	// Window is the window function name with an optional parameter
+	window string
 	// Don't run math.Log on the output of the analyzer
 	dontNormalize bool
 	// Use threaded processor
 	useThreaded bool
 	// Invert the order of bin drawing
 	invertDraw bool
+	// Show the level and loudness meter
+	showMeter bool
+	// Show the spectral features overlay
+	showFeatures bool
+	// Show frequency labels along the bars
+	showLabels bool
+	// Use 24-bit colors
+	trueColor bool
+	// Scale is the scaling method spec, see scale.ParseConfig
+	scale string
+	// Scale each channel on its own
+	scalePerChannel bool
 	// Styles is the configuration for bar color styles
 	styles graphic.Styles
 
@@ -54,6 +87,24 @@ type config struct {
 	rawOutputBins int
 	// Mirror the output bins similar to graphical output
 	rawOutputMirror bool
+	// Append the spectral features of each channel to the raw output
+	rawOutputFeatures bool
+	// Print a header with the center frequency of each bin to the raw output
+	rawOutputHeader bool
+	// Extra raw outputs written to files, "path[,bins][,policy]"
+	rawOutputFiles []string
+	// Extra raw outputs sent over the network, "scheme://address[,bins][,policy]"
+	rawOutputNets []string
+
+	// Audio file to render offline instead of capturing
+	renderFile string
+	// File to write rendered frames to (empty for stdout)
+	renderOutput string
+	// Format of rendered frames (json, raw)
+	renderFormat string
+
+	// File to log pipeline stats to every second ("-" for stderr)
+	statsFile string
 }
 
 // NewZeroConfig returns a zero config
@@ -72,6 +123,13 @@ func newZeroConfig() config {
 		spaceSize:                  1,
 		channelCount:               2,
 		drawType:                   int(graphic.DrawDefault),
+		analyzer:                   "spectrum",
+		binMethod:                  "max",
+		window:                     "lanczos",
+		stages:                     "noise,smooth",
+		scale:                      "stat",
+		fftPlanner:                 "measure",
+		fftWisdom:                  defaultWisdomFile(),
 		dontNormalize:              false,
 		combine:                    false,
 		useThreaded:                false,
@@ -79,9 +137,20 @@ func newZeroConfig() config {
 		useRawOutput:               false,
 		rawOutputBins:              50,
 		rawOutputMirror:            false,
+		renderFormat:               "json",
 	}
 }
 
+// defaultWisdomFile returns the default fftw wisdom file, or nothing if there
+// is no cache directory.
+func defaultWisdomFile() string {
+	path, err := fft.DefaultWisdomFile()
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
 // Sanitize cleans things up
 func (cfg *config) validate() error {
 
@@ -112,9 +181,61 @@ func (cfg *config) validate() error {
 		cfg.smoothFactor /= 100.0
 	}
 
+	switch cfg.analyzer {
+	case "spectrum":
+	case "chroma":
+		// there are only ever 12 pitch classes.
+		cfg.rawOutputBins = dsp.PitchClasses
+		if cfg.drawType == int(graphic.DrawDefault) {
+			cfg.drawType = int(graphic.DrawChroma)
+		}
+	default:
+		return fmt.Errorf("unknown analyzer %q (spectrum, chroma)", cfg.analyzer)
+	}
+
+	if _, err := dsp.ParseBinMethod(cfg.binMethod); err != nil {
+		return err
+	}
+
+	if _, err := fft.ParsePlanner(cfg.fftPlanner); err != nil {
+		return err
+	}
+
+	if _, err := scale.ParseConfig(cfg.scale); err != nil {
+		return err
+	}
+
+	if _, err := filter.Parse(cfg.filter, cfg.sampleRate); err != nil {
+		return err
+	}
+
+	if _, err := window.Parse(cfg.window); err != nil {
+		return err
+	}
+
+	if _, err := parseStages(cfg.stages); err != nil {
+		return err
+	}
+
+	if cfg.noiseMethod < int(dsp.NoiseOff) || cfg.noiseMethod > int(dsp.NoiseGate) {
+		return fmt.Errorf("unknown noise method %d (0, 1, 2)", cfg.noiseMethod)
+	}
+
 	if cfg.rawOutputBins <= 0 {
 		cfg.rawOutputBins = 50
 	}
 
+	switch cfg.renderFormat {
+	case "json", "raw":
+	default:
+		return fmt.Errorf("unknown render format %q (json, raw)", cfg.renderFormat)
+	}
+
+	for _, spec := range append(cfg.rawOutputFiles, cfg.rawOutputNets...) {
+		if _, err := parseSinkSpec(spec); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+
+	"github.com/noriah/catnip/dsp/features"
+	"github.com/noriah/catnip/dsp/scale"
+	"github.com/noriah/catnip/processor"
+)
+
+// JSONOutput writes frames as JSON lines. A {"bands": [...]} line is written
+// before the first frame and every time the bins change.
+type JSONOutput struct {
+	w        *bufio.Writer
+	enc      *json.Encoder
+	Features *features.Extractor
+	Scaler   *scale.Scaler
+	Origin   time.Time
+	binCount int
+	scaled   [][]float64
+}
+
+type jsonBand struct {
+	Low    float64 `json:"low"`
+	Center float64 `json:"center"`
+	High   float64 `json:"high"`
+}
+
+type jsonFeatures struct {
+	Centroid float64 `json:"centroid"`
+	Flux     float64 `json:"flux"`
+	Rolloff  float64 `json:"rolloff"`
+	Flatness float64 `json:"flatness"`
+	Crest    float64 `json:"crest"`
+}
+
+type jsonFrame struct {
+	Sequence uint64         `json:"seq"`
+	Time     float64        `json:"time"` // seconds from Origin
+	Peak     []float64      `json:"peak"`
+	Bins     [][]float64    `json:"bins"`
+	Features []jsonFeatures `json:"features,omitempty"`
+}
+
+var _ processor.FrameOutput = &JSONOutput{}
+var _ processor.Lifecycle = &JSONOutput{}
+var _ processor.StreamOutput = &JSONOutput{}
+
+// NewJSONOutput creates a JSONOutput writing bins per channel to w.
+func NewJSONOutput(w io.Writer, bins int) *JSONOutput {
+	bw := bufio.NewWriter(w)
+
+	return &JSONOutput{
+		w:        bw,
+		enc:      json.NewEncoder(bw),
+		binCount: bins,
+	}
+}
+
+// Bins returns the number of bins per channel.
+func (o *JSONOutput) Bins(int) int {
+	return o.binCount
+}
+
+// WriteFrame writes f as a line. Bins are scaled like the raw output, and the
+// peak is of the scaled bins.
+func (o *JSONOutput) WriteFrame(f *processor.Frame) error {
+	if f.BandsChanged {
+		bands := make([]jsonBand, len(f.Bands))
+		for idx, b := range f.Bands {
+			bands[idx] = jsonBand{Low: b.Low, Center: b.Center, High: b.High}
+		}
+
+		if err := o.enc.Encode(struct {
+			Bands []jsonBand `json:"bands"`
+		}{bands}); err != nil {
+			return err
+		}
+	}
+
+	o.scaled = o.Scaler.Scale(o.scaled, f.Bins, f.BinCount())
+
+	frame := jsonFrame{
+		Sequence: f.Sequence,
+		Time:     f.Time.Sub(o.Origin).Seconds(),
+		Peak:     make([]float64, len(o.scaled)),
+		Bins:     make([][]float64, len(o.scaled)),
+	}
+
+	for ch, buf := range o.scaled {
+		frame.Bins[ch] = buf[:f.BinCount()]
+
+		for _, v := range frame.Bins[ch] {
+			frame.Peak[ch] = math.Max(frame.Peak[ch], v)
+		}
+	}
+
+	if o.Features != nil {
+		for _, ft := range o.Features.Features() {
+			frame.Features = append(frame.Features, jsonFeatures(ft))
+		}
+	}
+
+	return o.enc.Encode(frame)
+}
+
+// Setup makes a scaler for stream if none is set.
+func (o *JSONOutput) Setup(stream processor.Stream) error {
+	if o.Scaler == nil {
+		o.Scaler = scale.New(scale.Config{
+			SampleRate: stream.SampleRate,
+			SampleSize: stream.SampleSize,
+		})
+	}
+
+	return nil
+}
+
+func (o *JSONOutput) Start(ctx context.Context) (context.Context, error) {
+	return ctx, nil
+}
+
+// SetStream follows a change of the stream.
+func (o *JSONOutput) SetStream(stream processor.Stream) error {
+	o.Scaler.SetStream(stream.SampleRate, stream.SampleSize)
+	return nil
+}
+
+// Cleanup flushes anything not yet written.
+func (o *JSONOutput) Cleanup() error {
+	return o.w.Flush()
+}
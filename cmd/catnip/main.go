@@ -2,14 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/noriah/catnip"
 	"github.com/noriah/catnip/dsp"
+	"github.com/noriah/catnip/dsp/features"
+	"github.com/noriah/catnip/dsp/filter"
+	"github.com/noriah/catnip/dsp/meter"
+	"github.com/noriah/catnip/dsp/scale"
 	"github.com/noriah/catnip/dsp/window"
+	"github.com/noriah/catnip/fft"
 	"github.com/noriah/catnip/graphic"
 	"github.com/noriah/catnip/input"
 	"github.com/noriah/catnip/processor"
@@ -28,6 +37,9 @@ const AppDesc = "Continuous Automatic Terminal Number Interpretation Printer"
 // AppSite is the app website
 const AppSite = "https://github.com/noriah/catnip"
 
+// keySeconds is the time constant of the key estimate of the chroma analyzer.
+const keySeconds = 3.0
+
 var version = "git"
 
 func main() {
@@ -41,105 +53,382 @@ func main() {
 
 	chk(cfg.validate(), "invalid config")
 
-	smoother := dsp.NewSmoother(dsp.SmootherConfig{
-		SampleSize:      cfg.sampleSize,
-		SampleRate:      cfg.sampleRate,
-		ChannelCount:    cfg.channelCount,
-		SmoothingFactor: cfg.smoothFactor,
-		SmoothingMethod: dsp.SmoothingMethod(cfg.smoothingMethod),
-		AverageSize:     cfg.smoothingAverageWindowSize,
-	})
+	// run returns its errors rather than exiting, so its deferred cleanups
+	// run first.
+	if err := run(cfg); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// run runs catnip, or renders a file, with cfg.
+func run(cfg config) error {
+	// checked by validate.
+	planner, _ := fft.ParsePlanner(cfg.fftPlanner)
+	fft.SetPlanner(planner)
+
+	if err := fft.SetWisdomFile(cfg.fftWisdom); err != nil {
+		// without wisdom plans are only slower to make.
+		log.Println("fftw wisdom:", err)
+	}
+
+	defer func() {
+		if err := fft.SaveWisdom(); err != nil {
+			log.Println("fftw wisdom:", err)
+		}
+	}()
+
+	if cfg.renderFile != "" {
+		if err := render(&cfg); err != nil {
+			return fmt.Errorf("failed to render: %w", err)
+		}
+		return nil
+	}
+
+	// checked by validate.
+	scaleCfg, _ := scale.ParseConfig(cfg.scale)
+	scaleCfg.SampleRate = cfg.sampleRate
+	scaleCfg.SampleSize = cfg.sampleSize
+	scaleCfg.PerChannel = cfg.scalePerChannel
+
+	scaler := scale.New(scaleCfg)
+
+	windows, err := window.NewSelector(cfg.window)
+	if err != nil {
+		return fmt.Errorf("invalid window: %w", err)
+	}
+
+	catnipCfg := catnip.Config{
+		ProcessRate: cfg.frameRate,
+		Combine:     cfg.combine,
+		UseThreaded: cfg.useThreaded,
+		Windower:    windows,
+		Control:     catnip.NewControl(),
+		Metrics:     processor.NewMetrics(),
+	}
+
+	setCapture(&cfg, &catnipCfg)
 
 	display := graphic.NewDisplay()
-	display.Smoother = smoother
+	display.Windows = windows
+	display.Scaler = scaler
+	display.Metrics = catnipCfg.Metrics
+	display.SetTrueColor(cfg.trueColor)
+	display.SetSizes(cfg.barSize, cfg.spaceSize)
+	display.SetBase(cfg.baseSize)
+	display.SetChroma(cfg.analyzer == "chroma")
+	display.SetDrawType(graphic.DrawType(cfg.drawType))
+	display.SetStyles(cfg.styles)
+	display.SetInvertDraw(cfg.invertDraw)
+	display.SetShowMeter(cfg.showMeter)
+	display.SetShowLabels(cfg.showLabels)
 
 	var output processor.Output
 	output = display
 
+	var rawOutputs []*RawOutput
+
 	if cfg.useRawOutput {
-		rawOutput := NewRawOutput()
-		rawOutput.Init(cfg.sampleRate, cfg.sampleSize)
-		rawOutput.SetBinCount(cfg.rawOutputBins)
-		rawOutput.SetInvertDraw(cfg.invertDraw)
-		rawOutput.SetMirrorOutput(cfg.rawOutputMirror)
+		rawOutput := newRawOutput(os.Stdout, cfg.rawOutputBins, &cfg)
+		rawOutput.Scaler = scaler
+		rawOutputs = append(rawOutputs, rawOutput)
 		output = rawOutput
 	}
 
-	catnipCfg := catnip.Config{
-		Backend:      cfg.backend,
-		Device:       cfg.device,
-		SampleRate:   cfg.sampleRate,
-		SampleSize:   cfg.sampleSize,
-		ChannelCount: cfg.channelCount,
-		ProcessRate:  cfg.frameRate,
-		Combine:      cfg.combine,
-		UseThreaded:  cfg.useThreaded,
-		SetupFunc:    setupFunc(!cfg.useRawOutput, &cfg, display),
-		StartFunc:    startFunc(!cfg.useRawOutput, display),
-		CleanupFunc:  cleanupFunc(!cfg.useRawOutput, display),
-		Output:       output,
-		Windower:     window.Lanczos(),
-		Analyzer: dsp.NewAnalyzer(dsp.AnalyzerConfig{
-			SampleRate:    cfg.sampleRate,
-			SampleSize:    cfg.sampleSize,
-			SquashLow:     true,
-			SquashLowOld:  true,
-			DontNormalize: cfg.dontNormalize,
-			BinMethod:     dsp.MaxSampleValue(),
-		}),
-		Smoother: smoother,
+	// sinks are closed by the cleanup of the output.
+	sinks, err := openSinks(&cfg, scaleCfg)
+	if err != nil {
+		return fmt.Errorf("failed to open output: %w", err)
+	}
+
+	frameOutput := processor.Adapt(output)
+
+	if len(sinks) > 0 {
+		outputs := []processor.FrameOutput{frameOutput}
+		for _, s := range sinks {
+			outputs = append(outputs, processor.Adapt(s))
+			rawOutputs = append(rawOutputs, s.RawOutput)
+		}
+
+		multi := processor.NewMultiOutput(processor.Factories{
+			Analyzer: func() dsp.Analyzer { return newAnalyzer(&cfg) },
+			Stages: func() []processor.Stage {
+				return newStages(&cfg, newNoiseFloor(&cfg), newSmoother(&cfg))
+			},
+		}, outputs...)
+
+		for idx, s := range sinks {
+			multi.SetPolicy(idx+1, s.policy)
+		}
+
+		frameOutput = multi
+	}
+
+	catnipCfg.FrameOutput = frameOutput
+
+	// share points the outputs at the parts of the capture they show.
+	share := func(c *catnip.Config) {
+		display.Smoother = c.Smoother
+		display.NoiseFloor = c.NoiseFloor
+		display.Meter = c.Meter
+		display.Features = c.Features
+		display.Key = c.Key
+
+		if cfg.rawOutputFeatures {
+			for _, rawOutput := range rawOutputs {
+				rawOutput.Features = c.Features
+			}
+		}
+	}
+
+	share(&catnipCfg)
+
+	display.Devices = func() ([]string, error) {
+		return deviceNames(cfg.backend)
+	}
+
+	display.Reconfigure = func(r graphic.Reconfig) error {
+		prev, applied := cfg, false
+
+		err := catnipCfg.Control.Update(func(c *catnip.Config) error {
+			next := cfg
+			next.smoothingMethod = int(display.Smoother.GetMethod())
+			applyReconfig(&next, r)
+
+			setCapture(&next, c)
+			if err := c.Validate(); err != nil {
+				return err
+			}
+
+			cfg, applied = next, true
+			share(c)
+			return nil
+		})
+
+		if err != nil && applied {
+			// the old capture was resumed, show its parts again.
+			catnipCfg.Control.Update(func(c *catnip.Config) error {
+				cfg = prev
+				share(c)
+				return nil
+			})
+		}
+
+		return err
 	}
 
 	// Root Context
+	if cfg.useRawOutput {
+		// get write errors instead of being killed when the reader goes away.
+		signal.Ignore(syscall.SIGPIPE)
+	}
+
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	chk(catnip.Run(&catnipCfg, ctx), "failed to run catnip")
+	if cfg.statsFile != "" {
+		stop, err := logStats(cfg.statsFile, catnipCfg.Metrics)
+		if err != nil {
+			return fmt.Errorf("failed to open stats log: %w", err)
+		}
+		defer stop()
+	}
+
+	err = catnip.Run(&catnipCfg, ctx)
+
+	// the reader of the raw output going away is a normal end.
+	if cfg.useRawOutput && errors.Is(err, syscall.EPIPE) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to run catnip: %w", err)
+	}
+
+	return nil
 }
 
-func setupFunc(isDisplay bool, cfg *config, display *graphic.Display) func() error {
-	if !isDisplay {
-		return func() error { return nil }
+// setCapture sets what c captures from cfg, with new analysis parts for it.
+func setCapture(cfg *config, c *catnip.Config) {
+	c.Backend = cfg.backend
+	c.Device = cfg.device
+	c.SampleRate = cfg.sampleRate
+	c.SampleSize = cfg.sampleSize
+	c.ChannelCount = cfg.channelCount
+
+	c.Analyzer = newAnalyzer(cfg)
+	c.Smoother = newSmoother(cfg)
+	c.NoiseFloor = newNoiseFloor(cfg)
+	c.Stages = newStages(cfg, c.NoiseFloor, c.Smoother)
+
+	c.Filter = nil
+	if filters, _ := filter.Parse(cfg.filter, cfg.sampleRate); len(filters) > 0 {
+		c.Filter = filter.NewChain(cfg.channelCount, filters...)
 	}
 
-	return func() error {
-		if err := display.Init(cfg.sampleRate, cfg.sampleSize); err != nil {
-			return err
-		}
+	// the meter and features are only built for what shows them.
+	c.Meter = nil
+	if cfg.showMeter {
+		c.Meter = meter.New(meter.Config{
+			SampleRate:   cfg.sampleRate,
+			ChannelCount: cfg.channelCount,
+		})
+	}
 
-		display.SetSizes(cfg.barSize, cfg.spaceSize)
-		display.SetBase(cfg.baseSize)
-		display.SetDrawType(graphic.DrawType(cfg.drawType))
-		display.SetStyles(cfg.styles)
-		display.SetInvertDraw(cfg.invertDraw)
+	c.Features = nil
+	if cfg.showFeatures || cfg.rawOutputFeatures {
+		c.Features = features.New(features.Config{
+			SampleRate:   cfg.sampleRate,
+			SampleSize:   cfg.sampleSize,
+			ChannelCount: cfg.channelCount,
+		})
+	}
 
-		return nil
+	c.Key = nil
+	if cfg.analyzer == "chroma" {
+		c.Key = dsp.NewKeyTracker(dsp.ChromaConfig{
+			SampleRate: cfg.sampleRate,
+			SampleSize: cfg.sampleSize,
+		}, keySeconds)
 	}
 }
 
-func startFunc(isDisplay bool, display *graphic.Display) func(context.Context) (context.Context, error) {
-	if !isDisplay {
-		return func(ctx context.Context) (context.Context, error) {
-			return ctx, nil
-		}
+// applyReconfig applies a change asked for from the display to cfg.
+func applyReconfig(cfg *config, r graphic.Reconfig) {
+	if r.Device != "" {
+		cfg.device = r.Device
 	}
 
-	return func(ctx context.Context) (context.Context, error) {
-		ctx = display.Start(ctx)
-		return ctx, nil
+	if r.SampleSteps > 0 {
+		cfg.sampleSize <<= r.SampleSteps
+	} else if r.SampleSteps < 0 {
+		cfg.sampleSize >>= -r.SampleSteps
+	}
+
+	if r.ChannelCount != 0 {
+		cfg.channelCount = r.ChannelCount
+	}
+
+	if r.Meter {
+		cfg.showMeter = true
+	}
+
+	if r.Features {
+		cfg.showFeatures = true
 	}
 }
 
-func cleanupFunc(isDisplay bool, display *graphic.Display) func() error {
-	if !isDisplay {
-		return func() error { return nil }
+// deviceNames returns the names of the devices of a backend.
+func deviceNames(name string) ([]string, error) {
+	backend, err := input.InitBackend(name)
+	if err != nil {
+		return nil, err
 	}
+	defer backend.Close()
+
+	devices, err := backend.Devices()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(devices))
+	for idx, device := range devices {
+		names[idx] = device.String()
+	}
+
+	return names, nil
+}
+
+func newAnalyzer(cfg *config) dsp.Analyzer {
+	if cfg.analyzer == "chroma" {
+		return dsp.NewChromaAnalyzer(dsp.ChromaConfig{
+			SampleRate:    cfg.sampleRate,
+			SampleSize:    cfg.sampleSize,
+			DontNormalize: cfg.dontNormalize,
+		})
+	}
+
+	// checked by validate.
+	binMethod, _ := dsp.ParseBinMethod(cfg.binMethod)
+
+	return dsp.NewAnalyzer(dsp.AnalyzerConfig{
+		SampleRate:    cfg.sampleRate,
+		SampleSize:    cfg.sampleSize,
+		SquashLow:     true,
+		SquashLowOld:  true,
+		DontNormalize: cfg.dontNormalize,
+		BinReducer:    binMethod,
+	})
+}
+
+func newSmoother(cfg *config) dsp.Smoother {
+	return dsp.NewSmoother(dsp.SmootherConfig{
+		SampleSize:      cfg.sampleSize,
+		SampleRate:      cfg.sampleRate,
+		ChannelCount:    cfg.channelCount,
+		SmoothingFactor: cfg.smoothFactor,
+		SmoothingMethod: dsp.SmoothingMethod(cfg.smoothingMethod),
+		AverageSize:     cfg.smoothingAverageWindowSize,
+	})
+}
+
+func newNoiseFloor(cfg *config) dsp.NoiseFloor {
+	return dsp.NewNoiseFloor(dsp.NoiseFloorConfig{
+		SampleSize:   cfg.sampleSize,
+		SampleRate:   cfg.sampleRate,
+		ChannelCount: cfg.channelCount,
+		Method:       dsp.NoiseMethod(cfg.noiseMethod),
+	})
+}
+
+// newRawOutput creates a raw output printing bins per channel to w.
+func newRawOutput(w io.Writer, bins int, cfg *config) *RawOutput {
+	rawOutput := NewRawOutput(w)
+	rawOutput.SetBinCount(bins)
+	rawOutput.SetChannelCount(cfg.channelCount)
+	rawOutput.SetInvertDraw(cfg.invertDraw)
+	rawOutput.SetMirrorOutput(cfg.rawOutputMirror)
+	rawOutput.SetShowHeader(cfg.rawOutputHeader)
+	return rawOutput
+}
+
+// openSinks opens the file and network raw outputs. Each has its own scaler.
+func openSinks(cfg *config, scaleCfg scale.Config) ([]*sink, error) {
+	var sinks []*sink
+
+	open := func(spec string, opener func(string) (io.WriteCloser, error)) error {
+		// checked by validate.
+		parsed, _ := parseSinkSpec(spec)
 
-	return func() error {
-		display.Stop()
-		display.Close()
+		bins := parsed.bins
+		if bins == 0 {
+			bins = cfg.rawOutputBins
+		}
+
+		w, err := opener(parsed.dest)
+		if err != nil {
+			return err
+		}
+
+		rawOutput := newRawOutput(w, bins, cfg)
+		rawOutput.Scaler = scale.New(scaleCfg)
+
+		sinks = append(sinks, &sink{RawOutput: rawOutput, dest: w, policy: parsed.policy})
 		return nil
 	}
+
+	for _, spec := range cfg.rawOutputFiles {
+		if err := open(spec, openFileSink); err != nil {
+			return sinks, err
+		}
+	}
+
+	for _, spec := range cfg.rawOutputNets {
+		if err := open(spec, openNetSink); err != nil {
+			return sinks, err
+		}
+	}
+
+	return sinks, nil
 }
 
 func doFlags(cfg *config) bool {
@@ -167,6 +456,19 @@ func doFlags(cfg *config) bool {
 
 	parser.AttachSubcommand(&listDevicesCmd, 1)
 
+	renderCmd := flaggy.Subcommand{
+		Name:                 "render",
+		ShortName:            "rd",
+		Description:          "analyze an audio file faster than real time",
+		AdditionalHelpAppend: "\none frame is written per 1/fps seconds of the file (-f, 60 if not set)",
+	}
+
+	renderCmd.AddPositionalValue(&cfg.renderFile, "file", 1, true, "audio file (wav, or anything ffmpeg reads)")
+	renderCmd.String(&cfg.renderOutput, "o", "out", "file to write frames to (default stdout)")
+	renderCmd.String(&cfg.renderFormat, "of", "format", "frame format (json, raw)")
+
+	parser.AttachSubcommand(&renderCmd, 1)
+
 	parser.String(&cfg.backend, "b", "backend", "backend name")
 	parser.String(&cfg.device, "d", "device", "device name")
 	parser.Float64(&cfg.sampleRate, "r", "rate", "sample rate")
@@ -176,17 +478,41 @@ func doFlags(cfg *config) bool {
 	parser.Float64(&cfg.smoothFactor, "sf", "smoothing", "smooth factor (0-100)")
 	parser.Int(&cfg.smoothingMethod, "sm", "smooth-method", "smoothing method (0, 1, 2, 3, 4, 5)")
 	parser.Int(&cfg.smoothingAverageWindowSize, "sas", "smooth-average-size", "smoothing window size")
+	parser.Int(&cfg.noiseMethod, "nm", "noise-method", "noise floor removal (0 off, 1 subtract, 2 gate; calibrate with 'c')")
 	parser.Int(&cfg.baseSize, "bt", "base", "base thickness [0, +Inf)")
 	parser.Int(&cfg.barSize, "bw", "bar", "bar width [1, +Inf)")
 	parser.Int(&cfg.spaceSize, "bs", "space", "space width [0, +Inf)")
-	parser.Int(&cfg.drawType, "dt", "draw", "draw type (1, 2, 3, 4, 5, 6, 7, 8, 9)")
+	parser.Int(&cfg.drawType, "dt", "draw", "draw type (1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13)")
+	parser.String(&cfg.analyzer, "am", "analyzer", "analyzer (spectrum, chroma)")
+	parser.String(&cfg.scale, "sc", "scale",
+		"auto-scaling, name[:param] (stat[:seconds], peak[:seconds], percentile[:0-1], fixed[:gain])")
+	parser.Bool(&cfg.scalePerChannel, "scc", "scale-channels", "scale each channel on its own")
+	parser.String(&cfg.filter, "fi", "filter",
+		"filters run before the fft, name:freq[:q[:gain]],... ("+strings.Join(filter.Names, ", ")+")")
+	parser.String(&cfg.stages, "st", "stages",
+		"stages run on the bars in order, name[:args],... (noise, smooth, peak[:frames[:fall]], weight:a|c)")
+	parser.String(&cfg.binMethod, "bm", "bin-method",
+		"how fft values are combined into a bar ("+strings.Join(dsp.BinMethodNames(), ", ")+")")
+	parser.String(&cfg.fftPlanner, "fp", "fft-planner",
+		"how hard fftw looks for a fast plan ("+strings.Join(fft.PlannerNames, ", ")+")")
+	parser.String(&cfg.fftWisdom, "fw", "fft-wisdom", "fftw wisdom file, plans are saved here for a fast start (empty to disable)")
+	parser.String(&cfg.window, "w", "window",
+		"window function, name[:param] ("+strings.Join(window.Names(), ", ")+")")
 	parser.Bool(&cfg.dontNormalize, "dn", "dont-normalize", "dont normalize analyzer output")
 	parser.Bool(&cfg.useThreaded, "t", "threaded", "use the threaded processor")
 	parser.Bool(&cfg.invertDraw, "i", "invert", "invert the direction of bin drawing")
+	parser.Bool(&cfg.trueColor, "tc", "truecolor", "use 24-bit colors (colors flags are still 256-color)")
+	parser.Bool(&cfg.showMeter, "mt", "meter", "show the level and loudness meter (toggle with 'm')")
+	parser.Bool(&cfg.showLabels, "lb", "labels", "show frequency labels along the bars (toggle with 'l')")
+	parser.String(&cfg.statsFile, "ss", "stats", "log pipeline timings to a file every second, \"-\" for stderr (overlay with 'v')")
 
 	parser.Bool(&cfg.useRawOutput, "raw", "output-raw", "print raw frequency bins")
 	parser.Int(&cfg.rawOutputBins, "rawb", "output-raw-bins", "number of bins per channel for the raw output")
 	parser.Bool(&cfg.rawOutputMirror, "rawm", "output-raw-mirror", "mirror the raw output similar to \"graphical\" output")
+	parser.Bool(&cfg.rawOutputHeader, "rawh", "output-raw-header", "print the center frequency of each bin in a '#' line before the raw output")
+	parser.Bool(&cfg.rawOutputFeatures, "rawf", "output-raw-features", "append spectral features of each channel to the raw output")
+	parser.StringSlice(&cfg.rawOutputFiles, "rawo", "output-raw-file", "also write raw output to a file, \"path[,bins][,policy]\" (repeatable)")
+	parser.StringSlice(&cfg.rawOutputNets, "rawn", "output-raw-net", "also send raw output over the network, \"tcp://host:port[,bins][,policy]\" (tcp, udp, unix; repeatable)")
 
 	fg, bg, center := graphic.DefaultStyles().AsUInt16s()
 	parser.UInt16(&fg, "fg", "foreground",
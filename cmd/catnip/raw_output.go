@@ -1,60 +1,91 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 
 	"github.com/noriah/catnip/dsp"
+	"github.com/noriah/catnip/dsp/features"
+	"github.com/noriah/catnip/dsp/scale"
 	"github.com/noriah/catnip/processor"
-	"github.com/noriah/catnip/util"
-)
-
-// Constants
-const (
-	// ScalingWindow in seconds
-	ScalingWindow = 1.5
-	// PeakThreshold is the threshold to not draw if the peak is less.
-	PeakThreshold = 0.001
 )
 
 // RawOutput handles printing our raw data.
 type RawOutput struct {
+	w            *bufio.Writer
 	Smoother     dsp.Smoother
-	trackZero    int
+	Features     *features.Extractor
+	Scaler       *scale.Scaler
 	binCount     int
+	channelCount int
 	invertDraw   bool
 	mirrorOutput bool
-	window       *util.MovingWindow
+	showHeader   bool
+	bands        []dsp.Band
+	scaled       [][]float64
 }
 
-var _ processor.Output = &RawOutput{}
+var _ processor.BandOutput = &RawOutput{}
+var _ processor.Lifecycle = &RawOutput{}
+var _ processor.StreamOutput = &RawOutput{}
 
-func NewRawOutput() *RawOutput {
+// NewRawOutput creates a RawOutput printing to w, one line per write.
+func NewRawOutput(w io.Writer) *RawOutput {
 	return &RawOutput{
-		binCount: 50,
+		w:            bufio.NewWriter(w),
+		binCount:     50,
+		channelCount: 1,
 	}
 }
 
 // Init initializes the display.
 // Should be called before any other display method.
 func (d *RawOutput) Init(sampleRate float64, sampleSize int) error {
-	// make a large buffer as this could be as big as the screen width/height.
-
-	windowSize := ((int(ScalingWindow * sampleRate)) / sampleSize) * 2
-	d.window = util.NewMovingWindow(windowSize)
+	if d.Scaler == nil {
+		d.Scaler = scale.New(scale.Config{
+			SampleRate: sampleRate,
+			SampleSize: sampleSize,
+		})
+	}
 
 	return nil
 }
 
-// Close will stop display and clean up the terminal.
+// Close flushes anything not yet written.
 func (d *RawOutput) Close() error {
+	return d.w.Flush()
+}
+
+// Setup initializes the output for stream.
+func (d *RawOutput) Setup(stream processor.Stream) error {
+	d.channelCount = stream.ChannelCount
+	return d.Init(stream.SampleRate, stream.SampleSize)
+}
+
+// SetStream follows a change of the stream. The header is printed again with
+// the next bands.
+func (d *RawOutput) SetStream(stream processor.Stream) error {
+	d.channelCount = stream.ChannelCount
+	d.Scaler.SetStream(stream.SampleRate, stream.SampleSize)
 	return nil
 }
 
+// Cleanup flushes anything not yet written.
+func (d *RawOutput) Cleanup() error {
+	return d.Close()
+}
+
 func (d *RawOutput) SetBinCount(count int) {
 	d.binCount = count
 }
 
+// SetChannelCount sets the number of channels written, used for the header.
+func (d *RawOutput) SetChannelCount(count int) {
+	d.channelCount = count
+}
+
 func (d *RawOutput) SetMirrorOutput(mirror bool) {
 	d.mirrorOutput = mirror
 }
@@ -63,73 +94,93 @@ func (d *RawOutput) SetInvertDraw(invert bool) {
 	d.invertDraw = invert
 }
 
-// Start display is bad.
-func (d *RawOutput) Start(ctx context.Context) context.Context {
-	return ctx
+// SetShowHeader sets whether a header line is printed when the bands change.
+func (d *RawOutput) SetShowHeader(show bool) {
+	d.showHeader = show
 }
 
-// Stop display not work.
-func (d *RawOutput) Stop() error {
-	return nil
+// SetBands prints the header for bands, if enabled.
+func (d *RawOutput) SetBands(bands []dsp.Band) {
+	d.bands = append(d.bands[:0], bands...)
+
+	if d.showHeader {
+		d.printHeader()
+	}
 }
 
-// Draw takes data and draws.
-func (d *RawOutput) Write(buffers [][]float64, channels int) error {
+// printHeader prints the center frequency in Hz of each bin in the order the
+// bins are printed, followed by the names of the features.
+func (d *RawOutput) printHeader() {
+	if len(d.bands) < d.binCount {
+		return
+	}
 
-	peak := 0.0
-	bins := d.Bins(channels)
+	d.w.WriteString("#")
 
-	for i := 0; i < channels; i++ {
-		for _, val := range buffers[i][:bins] {
-			if val > peak {
-				peak = val
-			}
+	for xSet := 0; xSet < d.channelCount; xSet++ {
+		for xBar := 0; xBar < d.binCount; xBar++ {
+			fmt.Fprintf(d.w, "%6.0f ", d.bands[d.binIndex(xSet, xBar)].Center)
 		}
 	}
 
-	scale := 1.0
+	if d.Features != nil {
+		for ch := 0; ch < d.channelCount; ch++ {
+			d.w.WriteString("centroid flux rolloff flatness crest ")
+		}
+	}
 
-	if peak >= PeakThreshold {
-		d.trackZero = 0
+	d.w.WriteString("\n")
+}
 
-		// do some scaling if we are above the PeakThreshold
-		d.window.Update(peak)
+// binIndex returns the bin printed at xBar of channel xSet.
+func (d *RawOutput) binIndex(xSet, xBar int) int {
+	xBin := xBar
 
-	} else {
-		if d.trackZero++; d.trackZero == 5 {
-			d.window.Recalculate()
-		}
+	if d.mirrorOutput {
+		xBin = (xBar * (1 - xSet)) + (((d.binCount - 1) - xBar) * xSet)
 	}
 
-	vMean, vSD := d.window.Stats()
-
-	if t := vMean + (2.0 * vSD); t > 1.0 {
-		scale = t
+	if d.invertDraw {
+		xBin = d.binCount - 1 - xBin
 	}
 
-	scale = 100.0 / scale
+	return xBin
+}
 
-	for xSet, chBins := range buffers {
+// Start display is bad.
+func (d *RawOutput) Start(ctx context.Context) (context.Context, error) {
+	return ctx, nil
+}
 
-		for xBar := 0; xBar < d.binCount; xBar++ {
+// Stop display not work.
+func (d *RawOutput) Stop() error {
+	return nil
+}
 
-			xBin := xBar
+// Draw takes data and draws.
+func (d *RawOutput) Write(buffers [][]float64, channels int) error {
 
-			if d.mirrorOutput {
-				xBin = (xBar * (1 - xSet)) + (((d.binCount - 1) - xBar) * xSet)
-			}
+	buffers = d.Scaler.Scale(d.scaled, buffers[:channels], d.Bins(channels))
+	d.scaled = buffers
 
-			if d.invertDraw {
-				xBin = d.binCount - 1 - xBin
-			}
+	// values are printed as a percentage of the scale.
+	for xSet, chBins := range buffers {
 
-			fmt.Printf("%6.3f ", chBins[xBin]*scale)
+		for xBar := 0; xBar < d.binCount; xBar++ {
+			fmt.Fprintf(d.w, "%6.3f ", chBins[d.binIndex(xSet, xBar)]*100.0)
 		}
 	}
 
-	fmt.Println()
+	if d.Features != nil {
+		for _, f := range d.Features.Features() {
+			fmt.Fprintf(d.w, "%6.3f %6.3f %6.3f %6.3f %6.3f ",
+				f.Centroid, f.Flux, f.Rolloff, f.Flatness, f.Crest)
+		}
+	}
 
-	return nil
+	d.w.WriteString("\n")
+
+	return d.w.Flush()
 }
 
 // Bins returns the number of bars we will draw.
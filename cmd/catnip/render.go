@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/noriah/catnip"
+	"github.com/noriah/catnip/dsp/scale"
+	"github.com/noriah/catnip/dsp/window"
+	"github.com/noriah/catnip/input/file"
+)
+
+// defaultRenderFPS is the frame rate of a render if none is set.
+const defaultRenderFPS = 60
+
+// render analyzes cfg.renderFile and writes its frames to cfg.renderOutput.
+func render(cfg *config) (err error) {
+	src, err := file.Open(cfg.renderFile, cfg.sampleRate, cfg.channelCount)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cErr := src.Close(); err == nil && cErr != nil {
+			err = fmt.Errorf("failed to read %s: %w", cfg.renderFile, cErr)
+		}
+	}()
+
+	// wav files are analyzed at their own rate.
+	cfg.sampleRate = src.SampleRate()
+
+	var w io.Writer = os.Stdout
+
+	if cfg.renderOutput != "" {
+		f, err := os.Create(cfg.renderOutput)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	// checked by validate.
+	windows, _ := window.NewSelector(cfg.window)
+
+	catnipCfg := catnip.Config{Windower: windows}
+	setCapture(cfg, &catnipCfg)
+
+	// checked by validate.
+	scaleCfg, _ := scale.ParseConfig(cfg.scale)
+	scaleCfg.SampleRate = cfg.sampleRate
+	scaleCfg.SampleSize = cfg.sampleSize
+	scaleCfg.PerChannel = cfg.scalePerChannel
+
+	switch cfg.renderFormat {
+	case "raw":
+		rawOutput := newRawOutput(w, cfg.rawOutputBins, cfg)
+		rawOutput.Scaler = scale.New(scaleCfg)
+		if cfg.rawOutputFeatures {
+			rawOutput.Features = catnipCfg.Features
+		}
+		catnipCfg.Output = rawOutput
+
+	default:
+		jsonOutput := NewJSONOutput(w, cfg.rawOutputBins)
+		jsonOutput.Scaler = scale.New(scaleCfg)
+		if cfg.rawOutputFeatures {
+			jsonOutput.Features = catnipCfg.Features
+		}
+		catnipCfg.FrameOutput = jsonOutput
+	}
+
+	fps := cfg.frameRate
+	if fps <= 0 {
+		fps = defaultRenderFPS
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	// frames are timed from the zero time, the origin of the json output.
+	return catnip.Render(&catnipCfg, ctx, src, catnip.RenderConfig{
+		FrameRate: float64(fps),
+	})
+}
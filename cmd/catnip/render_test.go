@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeWAV writes a 16 bit stereo wav file of a chord with a sweep.
+func writeWAV(t *testing.T, path string, rate, seconds int) {
+	t.Helper()
+
+	frames := rate * seconds
+	data := make([]byte, frames*4)
+
+	for idx := 0; idx < frames; idx++ {
+		tm := float64(idx) / float64(rate)
+		sweep := 100 + 4000*tm/float64(seconds)
+
+		left := 0.3*math.Sin(2*math.Pi*220*tm) + 0.2*math.Sin(2*math.Pi*sweep*tm)
+		right := 0.3*math.Sin(2*math.Pi*330*tm) + 0.2*math.Sin(2*math.Pi*(sweep/2)*tm)
+
+		binary.LittleEndian.PutUint16(data[idx*4:], uint16(int16(left*(1<<15))))
+		binary.LittleEndian.PutUint16(data[idx*4+2:], uint16(int16(right*(1<<15))))
+	}
+
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+
+	buf.WriteString("RIFF")
+	binary.Write(&buf, le, uint32(36+len(data)))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, le, []uint32{16})
+	binary.Write(&buf, le, []uint16{1, 2})
+	binary.Write(&buf, le, []uint32{uint32(rate), uint32(rate * 4)})
+	binary.Write(&buf, le, []uint16{4, 16})
+	buf.WriteString("data")
+	binary.Write(&buf, le, uint32(len(data)))
+	buf.Write(data)
+
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenderIsRepeatable(t *testing.T) {
+	dir := t.TempDir()
+	wav := filepath.Join(dir, "in.wav")
+	writeWAV(t, wav, 22050, 2)
+
+	for _, format := range []string{"json", "raw"} {
+		var outputs [2][]byte
+
+		for run := range outputs {
+			cfg := newZeroConfig()
+			cfg.renderFile = wav
+			cfg.renderFormat = format
+			cfg.renderOutput = filepath.Join(dir, format+".out")
+			cfg.rawOutputFeatures = true
+
+			if err := render(&cfg); err != nil {
+				t.Fatalf("%s: %v", format, err)
+			}
+
+			out, err := os.ReadFile(cfg.renderOutput)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(out) == 0 {
+				t.Fatalf("%s: nothing rendered", format)
+			}
+
+			outputs[run] = out
+		}
+
+		if !bytes.Equal(outputs[0], outputs[1]) {
+			t.Errorf("%s: two renders of the same file differ", format)
+		}
+	}
+}
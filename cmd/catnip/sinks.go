@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/noriah/catnip/processor"
+)
+
+// sink is an extra raw output written to a file or a network connection.
+type sink struct {
+	*RawOutput
+	dest   io.WriteCloser
+	policy processor.Policy
+}
+
+// Cleanup flushes the output and closes the destination.
+func (s *sink) Cleanup() error {
+	err := s.RawOutput.Cleanup()
+	if cErr := s.dest.Close(); err == nil {
+		err = cErr
+	}
+	return err
+}
+
+// sinkSpec is a parsed sink spec.
+type sinkSpec struct {
+	dest   string
+	bins   int // zero if not given
+	policy processor.Policy
+}
+
+// parseSinkSpec parses a sink spec of the form "dest[,bins][,policy]". The
+// policy is drop if not given.
+func parseSinkSpec(spec string) (sinkSpec, error) {
+	parsed := sinkSpec{dest: spec, policy: processor.PolicyDrop}
+
+	for opts := 0; opts < 2; opts++ {
+		idx := strings.LastIndexByte(parsed.dest, ',')
+		if idx < 0 {
+			break
+		}
+
+		opt := parsed.dest[idx+1:]
+
+		if bins, err := strconv.Atoi(opt); err == nil {
+			if bins <= 0 {
+				return sinkSpec{}, fmt.Errorf("invalid bin count in sink %q", spec)
+			}
+			parsed.bins = bins
+		} else if policy, err := processor.ParsePolicy(opt); err == nil {
+			parsed.policy = policy
+		} else {
+			return sinkSpec{}, fmt.Errorf("invalid option %q in sink %q (bins or %s)",
+				opt, spec, strings.Join(processor.PolicyNames(), ", "))
+		}
+
+		parsed.dest = parsed.dest[:idx]
+	}
+
+	return parsed, nil
+}
+
+// openFileSink creates or truncates the file at path.
+func openFileSink(path string) (io.WriteCloser, error) {
+	return os.Create(path)
+}
+
+// openNetSink dials a network sink given as "tcp://host:port",
+// "udp://host:port" or "unix:///path".
+func openNetSink(addr string) (io.WriteCloser, error) {
+	network, address, ok := strings.Cut(addr, "://")
+	if !ok {
+		return nil, fmt.Errorf("missing scheme in network sink %q (tcp, udp, unix)", addr)
+	}
+
+	switch network {
+	case "tcp", "udp", "unix":
+	default:
+		return nil, fmt.Errorf("unknown network %q in sink %q (tcp, udp, unix)", network, addr)
+	}
+
+	return net.Dial(network, address)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/noriah/catnip/dsp"
+	"github.com/noriah/catnip/processor"
+)
+
+const (
+	// defaultPeakHold is the number of frames a peak is held.
+	defaultPeakHold = 30
+	// defaultPeakFall is the fraction of a peak lost per frame after the hold.
+	defaultPeakFall = 0.05
+)
+
+// stageNames are the stages that can be given to --stages.
+var stageNames = []string{"noise", "smooth", "peak", "weight"}
+
+// stageSpec is a parsed stage of --stages.
+type stageSpec struct {
+	name  string
+	hold  int
+	fall  float64
+	curve dsp.WeightingCurve
+}
+
+// parseStages parses a comma separated list of stages, each of the form
+// "name[:args]":
+//
+//	noise                  noise floor removal (see --noise-method)
+//	smooth                 smoothing (see --smoothing)
+//	peak[:frames[:fall]]   peak hold
+//	weight:curve           frequency weighting (a, c)
+func parseStages(spec string) ([]stageSpec, error) {
+	var stages []stageSpec
+
+	for _, part := range strings.Split(spec, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+
+		args := strings.Split(part, ":")
+		stage := stageSpec{name: args[0]}
+
+		var err error
+
+		switch stage.name {
+		case "noise", "smooth":
+			if len(args) > 1 {
+				err = fmt.Errorf("stage %q takes no arguments", stage.name)
+			}
+
+		case "peak":
+			stage.hold, stage.fall = defaultPeakHold, defaultPeakFall
+
+			if len(args) > 1 {
+				stage.hold, err = strconv.Atoi(args[1])
+			}
+
+			if err == nil && len(args) > 2 {
+				stage.fall, err = strconv.ParseFloat(args[2], 64)
+			}
+
+			if err == nil && len(args) > 3 {
+				err = fmt.Errorf("too many arguments for stage %q", part)
+			}
+
+		case "weight":
+			if len(args) != 2 {
+				err = fmt.Errorf("stage %q needs a curve (%s)",
+					part, strings.Join(dsp.WeightingNames(), ", "))
+				break
+			}
+			stage.curve, err = dsp.ParseWeighting(args[1])
+
+		default:
+			err = fmt.Errorf("unknown stage %q (%s)", stage.name, strings.Join(stageNames, ", "))
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid stage %q: %w", part, err)
+		}
+
+		stages = append(stages, stage)
+	}
+
+	return stages, nil
+}
+
+// newStages makes the stages of cfg. nf and smth are used for the noise and
+// smooth stages.
+func newStages(cfg *config, nf dsp.NoiseFloor, smth dsp.Smoother) []processor.Stage {
+	// checked by validate.
+	specs, _ := parseStages(cfg.stages)
+
+	stages := make([]processor.Stage, 0, len(specs))
+
+	for _, spec := range specs {
+		switch spec.name {
+		case "noise":
+			stages = append(stages, processor.NoiseFloorStage(nf))
+		case "smooth":
+			stages = append(stages, processor.SmootherStage(smth))
+		case "peak":
+			stages = append(stages, dsp.NewPeakHold(cfg.channelCount, cfg.sampleSize, spec.hold, spec.fall))
+		case "weight":
+			weighting := dsp.NewWeighting(spec.curve)
+			weighting.DontNormalize = cfg.dontNormalize
+			stages = append(stages, weighting)
+		}
+	}
+
+	return stages
+}
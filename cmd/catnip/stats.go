@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/noriah/catnip/processor"
+)
+
+// logStats logs the stats of m to path every second, appending if the file
+// exists. stop logs them a last time and closes the file.
+func logStats(path string, m *processor.Metrics) (stop func(), err error) {
+	var w io.Writer = os.Stderr
+
+	var f *os.File
+	if path != "-" {
+		if f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644); err != nil {
+			return nil, err
+		}
+		w = f
+	}
+
+	logger := log.New(w, "", log.LstdFlags)
+
+	done := make(chan struct{})
+	ended := make(chan struct{})
+
+	go func() {
+		defer close(ended)
+
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				logger.Println(m.Stats())
+				return
+
+			case <-ticker.C:
+				logger.Println(m.Stats())
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-ended
+
+		if f != nil {
+			f.Close()
+		}
+	}, nil
+}
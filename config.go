@@ -5,6 +5,9 @@ import (
 	"fmt"
 
 	"github.com/noriah/catnip/dsp"
+	"github.com/noriah/catnip/dsp/features"
+	"github.com/noriah/catnip/dsp/filter"
+	"github.com/noriah/catnip/dsp/meter"
 	"github.com/noriah/catnip/dsp/window"
 	"github.com/noriah/catnip/processor"
 )
@@ -25,24 +28,55 @@ type Config struct {
 	// Merge multiple channels into a single stream
 	Combine bool
 
-	// testing. leave false
-	// Use threaded processor
+	// Use the threaded processor, which runs the fft and analyzer on a
+	// goroutine per cpu
 	UseThreaded bool
+	// Number of goroutines of the threaded processor (0 for GOMAXPROCS)
+	Workers int
 
 	// Function to call when setting up the pipeline
+	//
+	// Deprecated: implement processor.Lifecycle on the output.
 	SetupFunc SetupFunc
 	// Function to call when starting the pipeline
+	//
+	// Deprecated: implement processor.Lifecycle on the output.
 	StartFunc StartFunc
 	// Function to call when cleaning up the pipeline
+	//
+	// Deprecated: implement processor.Lifecycle on the output.
 	CleanupFunc CleanupFunc
-	// Where to send the data from the audio analysis
+	// Where to send the data from the audio analysis (version 1)
 	Output processor.Output
+	// Where to send frames from the audio analysis, used instead of Output
+	FrameOutput processor.FrameOutput
+	// Number of bins per channel for Pipeline subscribers if there is no
+	// output (0 uses DefaultSubscribeBins)
+	Bins int
 	// Method to run on data before running fft
-	Windower window.Function
+	Windower window.Windower
+	// Filter to run on new samples before windowing
+	Filter *filter.Chain
 	// Analyzer to run analysis on data
 	Analyzer dsp.Analyzer
 	// Smoother to run smoothing on output from Analyzer
 	Smoother dsp.Smoother
+	// NoiseFloor to remove the noise floor from the output of Analyzer
+	NoiseFloor dsp.NoiseFloor
+	// Stages to run on the output of Analyzer, in order. Used instead of
+	// NoiseFloor and Smoother if set
+	Stages []processor.Stage
+	// Meter to measure levels and loudness of the raw samples
+	Meter *meter.Meter
+	// Features to extract spectral descriptors from the fft output
+	Features *features.Extractor
+	// Key to estimate the musical key from the fft output of new samples
+	Key *dsp.KeyTracker
+	// Control to change the config of a running Run with (optional)
+	Control *Control
+	// Metrics to collect timings of the pipeline and input problems in
+	// (optional)
+	Metrics *processor.Metrics
 }
 
 func NewZeroConfig() Config {
@@ -53,6 +87,23 @@ func NewZeroConfig() Config {
 	}
 }
 
+// output returns the output to write frames to.
+func (cfg *Config) output() processor.FrameOutput {
+	if cfg.FrameOutput != nil {
+		return cfg.FrameOutput
+	}
+	return processor.Adapt(cfg.Output)
+}
+
+// stream returns the stream captured with cfg.
+func (cfg *Config) stream() processor.Stream {
+	return processor.Stream{
+		SampleRate:   cfg.SampleRate,
+		SampleSize:   cfg.SampleSize,
+		ChannelCount: cfg.ChannelCount,
+	}
+}
+
 func (cfg *Config) Validate() error {
 	if cfg.SampleRate < float64(cfg.SampleSize) {
 		return errors.New("sample rate lower than sample size")
@@ -73,5 +124,9 @@ func (cfg *Config) Validate() error {
 		return fmt.Errorf("sample size too large (%d max)", MaxSampleSize)
 	}
 
+	if cfg.Output == nil && cfg.FrameOutput == nil {
+		return errors.New("no output")
+	}
+
 	return nil
 }
@@ -0,0 +1,70 @@
+package catnip
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNotRunning is returned by Control.Update if no run is using the control.
+var ErrNotRunning = errors.New("not running")
+
+// Control changes the config of a running Run, see Config.Control.
+type Control struct {
+	mu      sync.Mutex
+	updates chan update
+	done    chan struct{} // closed when the run using it ends
+}
+
+// NewControl creates a Control.
+func NewControl() *Control {
+	return &Control{updates: make(chan update)}
+}
+
+// Update stops capture, changes the config with fn and captures again with the
+// new config, without the output being set up again. The device, backend,
+// sample rate and size, channels and analysis stages can be changed; changes
+// to the output are ignored. fn should replace stateful parts like the
+// analyzer and smoother rather than change the ones in use.
+//
+// If capture cannot be started with the new config, the old one is resumed and
+// the error is returned.
+func (c *Control) Update(fn func(*Config) error) error {
+	c.mu.Lock()
+	done := c.done
+	c.mu.Unlock()
+
+	if done == nil {
+		return ErrNotRunning
+	}
+
+	u := update{fn: fn, done: make(chan error, 1)}
+
+	select {
+	case c.updates <- u:
+	case <-done:
+		return ErrNotRunning
+	}
+
+	return <-u.done
+}
+
+// attach marks c as used by a run until detach is called.
+func (c *Control) attach() (detach func(), err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.done != nil {
+		return nil, errors.New("control is used by another run")
+	}
+
+	done := make(chan struct{})
+	c.done = done
+
+	return func() {
+		c.mu.Lock()
+		c.done = nil
+		c.mu.Unlock()
+
+		close(done)
+	}, nil
+}
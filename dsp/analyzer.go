@@ -15,12 +15,13 @@ import "math"
 type BinMethod func(int, float64, float64) float64
 
 type AnalyzerConfig struct {
-	SampleRate    float64   // audio sample rate
-	SampleSize    int       // number of samples per slice
-	SquashLow     bool      // squash the low end the spectrum
-	SquashLowOld  bool      // squash the low end using the old method
-	DontNormalize bool      // dont run math.Log on output
-	BinMethod     BinMethod // method used for calculating bin value
+	SampleRate    float64    // audio sample rate
+	SampleSize    int        // number of samples per slice
+	SquashLow     bool       // squash the low end the spectrum
+	SquashLowOld  bool       // squash the low end using the old method
+	DontNormalize bool       // dont run math.Log on output
+	BinMethod     BinMethod  // method used for calculating bin value
+	BinReducer    BinReducer // used instead of BinMethod if set
 }
 
 type Analyzer interface {
@@ -29,10 +30,27 @@ type Analyzer interface {
 	Recalculate(int) int
 }
 
+// BandAnalyzer is an Analyzer that knows the frequency band of each bin.
+type BandAnalyzer interface {
+	Analyzer
+	// Bands returns the frequency band of each bin. It is valid until the
+	// next Recalculate.
+	Bands() []Band
+}
+
+// Band is the range of frequencies in Hz that one bin covers.
+type Band struct {
+	Low    float64 // lowest frequency in the bin
+	Center float64 // geometric center of the bin, or High/2 if Low is 0
+	High   float64 // highest frequency in the bin
+}
+
 // analyzer is an audio spectrum in a buffer
 type analyzer struct {
 	cfg      AnalyzerConfig // the analyzer config
+	reduce   BinReducer     // BinReducer, or BinMethod folded over the bin
 	bins     []bin          // bins for processing
+	bands    []Band         // frequency band of each bin
 	binCount int            // number of bins we look at
 	fftSize  int            // number of fft bins
 }
@@ -107,13 +125,26 @@ func MinNonZeroSampleValue() BinMethod {
 }
 
 func NewAnalyzer(cfg AnalyzerConfig) Analyzer {
+	reduce := cfg.BinReducer
+	if reduce == nil {
+		reduce = cfg.BinMethod.Reducer()
+	}
+
 	return &analyzer{
 		cfg:     cfg,
+		reduce:  reduce,
 		bins:    make([]bin, cfg.SampleSize),
+		bands:   make([]Band, cfg.SampleSize),
 		fftSize: cfg.SampleSize/2 + 1,
 	}
 }
 
+// Bands returns the frequency band of each bin. It is valid until the next
+// Recalculate.
+func (az *analyzer) Bands() []Band {
+	return az.bands[:az.binCount]
+}
+
 // BinCount returns the number of bins each stream has
 func (az *analyzer) BinCount() int {
 	return az.binCount
@@ -131,13 +162,7 @@ func (az *analyzer) ProcessBin(idx int, src []complex128) float64 {
 	// 	fftFloor = fftCeil - 1
 	// }
 
-	src = src[fftFloor:fftCeil]
-	mag := 0.0
-	count := len(src)
-	for _, cmplx := range src {
-		power := math.Hypot(real(cmplx), imag(cmplx))
-		mag = az.cfg.BinMethod(count, mag, power)
-	}
+	mag := az.reduce(src[fftFloor:fftCeil])
 
 	if az.cfg.SquashLow {
 		// squash the low low end a bit.
@@ -207,6 +232,7 @@ func (az *analyzer) Recalculate(binCount int) int {
 			az.bins[idx].powVal *= math.Max(0.5, float64(b.ceilFFT)/fBassCut)
 		}
 
+		az.bands[idx] = az.band(az.bins[idx])
 	}
 
 	return binCount
@@ -245,6 +271,22 @@ func (az *analyzer) distribute(bins int) {
 	}
 }
 
+// band returns the frequencies covered by the fft indices of b.
+func (az *analyzer) band(b bin) Band {
+	res := az.cfg.SampleRate / float64(az.cfg.SampleSize)
+
+	low := float64(b.floorFFT) * res
+	high := float64(b.ceilFFT) * res
+
+	// a band from 0Hz has no geometric center, the middle of it stands in.
+	center := high / 2.0
+	if low > 0.0 {
+		center = math.Sqrt(low * high)
+	}
+
+	return Band{Low: low, Center: center, High: high}
+}
+
 type mathFunc func(float64) float64
 
 func (az *analyzer) freqToIdx(freq float64, round mathFunc) int {
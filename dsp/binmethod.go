@@ -0,0 +1,153 @@
+package dsp
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// magnitude returns the magnitude of an fft value.
+func magnitude(v complex128) float64 {
+	return math.Hypot(real(v), imag(v))
+}
+
+// power returns the squared magnitude of an fft value.
+func power(v complex128) float64 {
+	return (real(v) * real(v)) + (imag(v) * imag(v))
+}
+
+// BinReducer reduces the fft values that fall into one bin to a single
+// magnitude. Unlike a BinMethod, it sees all of them at once.
+type BinReducer func([]complex128) float64
+
+// Reducer returns a BinReducer that folds m over the magnitudes of a bin.
+func (m BinMethod) Reducer() BinReducer {
+	return func(src []complex128) float64 {
+		mag := 0.0
+		count := len(src)
+		for _, v := range src {
+			mag = m(count, mag, magnitude(v))
+		}
+		return mag
+	}
+}
+
+// Return the root mean square of the samples. Unlike AverageSamples, this
+// averages power, so a bin with one strong sample among many quiet ones is
+// not pulled down as far.
+func RMSSamples() BinReducer {
+	return func(src []complex128) float64 {
+		if len(src) == 0 {
+			return 0.0
+		}
+
+		sum := 0.0
+		for _, v := range src {
+			sum += power(v)
+		}
+		return math.Sqrt(sum / float64(len(src)))
+	}
+}
+
+// Return the total energy of the samples as a magnitude. Wide bins sum the
+// power of all of their samples, so they read the same as a narrow bin holding
+// the same energy.
+func PowerSumSamples() BinReducer {
+	return func(src []complex128) float64 {
+		sum := 0.0
+		for _, v := range src {
+			sum += power(v)
+		}
+		return math.Sqrt(sum)
+	}
+}
+
+// Return the geometric mean of all the samples that are not zero.
+func GeometricMeanSamples() BinReducer {
+	return func(src []complex128) float64 {
+		sum, count := 0.0, 0
+		for _, v := range src {
+			if m := magnitude(v); m > 0.0 {
+				sum += math.Log(m)
+				count++
+			}
+		}
+
+		if count == 0 {
+			return 0.0
+		}
+		return math.Exp(sum / float64(count))
+	}
+}
+
+// Return the median value of all the samples.
+func MedianSamples() BinReducer {
+	// analyzers may run on multiple channels at once, so scratch space is
+	// pooled instead of shared.
+	pool := sync.Pool{
+		New: func() any {
+			return new([]float64)
+		},
+	}
+
+	return func(src []complex128) float64 {
+		if len(src) == 0 {
+			return 0.0
+		}
+
+		scratch := pool.Get().(*[]float64)
+		defer pool.Put(scratch)
+
+		buf := (*scratch)[:0]
+		for _, v := range src {
+			buf = append(buf, magnitude(v))
+		}
+		*scratch = buf
+
+		sort.Float64s(buf)
+
+		mid := len(buf) / 2
+		if len(buf)%2 == 0 {
+			return (buf[mid-1] + buf[mid]) / 2.0
+		}
+		return buf[mid]
+	}
+}
+
+// binMethods maps names to BinReducer constructors, in the order they are
+// listed.
+var binMethods = []struct {
+	name string
+	make func() BinReducer
+}{
+	{"max", MaxSampleValue().Reducer},
+	{"average", AverageSamples().Reducer},
+	{"sum", SumSamples().Reducer},
+	{"min", MinNonZeroSampleValue().Reducer},
+	{"rms", RMSSamples},
+	{"power", PowerSumSamples},
+	{"geometric", GeometricMeanSamples},
+	{"median", MedianSamples},
+}
+
+// BinMethodNames returns the names of all bin methods.
+func BinMethodNames() []string {
+	names := make([]string, len(binMethods))
+	for idx, m := range binMethods {
+		names[idx] = m.name
+	}
+	return names
+}
+
+// ParseBinMethod returns the reducer of the bin method called name.
+func ParseBinMethod(name string) (BinReducer, error) {
+	for _, m := range binMethods {
+		if m.name == strings.ToLower(name) {
+			return m.make(), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown bin method %q (%s)", name, strings.Join(BinMethodNames(), ", "))
+}
@@ -0,0 +1,77 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+// binValues have the magnitudes 5, 0, 1 and 2.
+var binValues = []complex128{3 + 4i, 0, -1, 2i}
+
+func TestBinMethods(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want float64
+	}{
+		{"max", 5},
+		{"average", 2},
+		{"sum", 8},
+		{"min", 1},
+		{"rms", math.Sqrt(30.0 / 4.0)},
+		{"power", math.Sqrt(30)},
+		{"geometric", math.Cbrt(10)},
+		{"median", 1.5},
+	} {
+		reduce, err := ParseBinMethod(tc.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := reduce(binValues); math.Abs(got-tc.want) > 1e-12 {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+
+		if got := reduce(nil); got != 0 {
+			t.Errorf("%s: got %v for an empty bin, want 0", tc.name, got)
+		}
+
+		if got := reduce(binValues[:1]); math.Abs(got-5) > 1e-12 {
+			t.Errorf("%s: got %v for one value, want it", tc.name, got)
+		}
+	}
+
+	if _, err := ParseBinMethod("mode"); err == nil {
+		t.Error("no error for an unknown bin method")
+	}
+}
+
+func TestBinMethodReducer(t *testing.T) {
+	// the fold a BinMethod was always called with.
+	fold := func(m BinMethod) float64 {
+		mag := 0.0
+		for _, v := range binValues {
+			mag = m(len(binValues), mag, math.Hypot(real(v), imag(v)))
+		}
+		return mag
+	}
+
+	for _, m := range []BinMethod{AverageSamples(), SumSamples(), MaxSampleValue(), MinNonZeroSampleValue()} {
+		if got, want := m.Reducer()(binValues), fold(m); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestMedianKeepsBin(t *testing.T) {
+	reduce := MedianSamples()
+
+	// the median sorts a copy, the bin is left alone.
+	src := []complex128{4, 1, 3}
+	if got := reduce(src); got != 3 {
+		t.Errorf("got %v, want 3", got)
+	}
+
+	if src[0] != 4 {
+		t.Error("median changed the bin")
+	}
+}
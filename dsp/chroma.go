@@ -0,0 +1,135 @@
+package dsp
+
+import "math"
+
+// PitchClasses is the number of pitch classes in an octave.
+const PitchClasses = 12
+
+// PitchClassNames are the names of the pitch classes, starting at C.
+var PitchClassNames = [PitchClasses]string{
+	"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B",
+}
+
+type ChromaConfig struct {
+	SampleRate    float64 // audio sample rate
+	SampleSize    int     // number of samples per slice
+	MinFrequency  float64 // lowest frequency folded into the chroma (0 = 55Hz)
+	MaxFrequency  float64 // highest frequency folded into the chroma (0 = 5kHz)
+	Tuning        float64 // frequency of A4 (0 = 440Hz)
+	DontNormalize bool    // dont run math.Log on output
+}
+
+// chromaAnalyzer folds the spectrum into the 12 pitch classes.
+type chromaAnalyzer struct {
+	cfg     ChromaConfig
+	classes [PitchClasses][]chromaBin // fft bins that belong to each class
+}
+
+// chromaBin is an fft bin and the weight of its energy in its class.
+type chromaBin struct {
+	idx    int
+	weight float64
+}
+
+// NewChromaAnalyzer returns an Analyzer that always has PitchClasses bins.
+// Bin 0 is C, bin 11 is B.
+func NewChromaAnalyzer(cfg ChromaConfig) Analyzer {
+	if cfg.Tuning <= 0.0 {
+		cfg.Tuning = 440.0
+	}
+
+	if cfg.MaxFrequency <= 0.0 {
+		cfg.MaxFrequency = 5000.0
+	}
+
+	cfg.MaxFrequency = math.Min(cfg.MaxFrequency, cfg.SampleRate/2)
+
+	binWidth := cfg.SampleRate / float64(cfg.SampleSize)
+
+	if cfg.MinFrequency <= 0.0 {
+		cfg.MinFrequency = 55.0
+	}
+
+	// Below this, a single fft bin is wider than a semitone and smears its
+	// energy across neighbouring pitch classes.
+	semitone := math.Pow(2, 1.0/12.0) - 1.0
+	resolved := binWidth / semitone
+
+	ca := &chromaAnalyzer{cfg: cfg}
+
+	fftSize := cfg.SampleSize/2 + 1
+	for idx := 1; idx < fftSize; idx++ {
+		freq := float64(idx) * binWidth
+		if freq < cfg.MinFrequency || freq > cfg.MaxFrequency {
+			continue
+		}
+
+		// weight unresolved bins by how much of a semitone they resolve, so the
+		// bass counts without drowning the classes it smears into.
+		weight := math.Min(1.0, freq/resolved)
+
+		class := FrequencyToPitchClass(freq, cfg.Tuning)
+		ca.classes[class] = append(ca.classes[class], chromaBin{idx: idx, weight: weight})
+	}
+
+	return ca
+}
+
+// FrequencyToPitchClass returns the pitch class (0 = C) nearest to freq.
+func FrequencyToPitchClass(freq, tuning float64) int {
+	note := 69.0 + 12.0*math.Log2(freq/tuning)
+	class := int(math.Round(note)) % PitchClasses
+	if class < 0 {
+		class += PitchClasses
+	}
+	return class
+}
+
+// BinCount returns the number of pitch classes.
+func (ca *chromaAnalyzer) BinCount() int {
+	return PitchClasses
+}
+
+func (ca *chromaAnalyzer) ProcessBin(idx int, src []complex128) float64 {
+	energy := 0.0
+	for _, bin := range ca.classes[idx] {
+		cmplx := src[bin.idx]
+		energy += bin.weight * ((real(cmplx) * real(cmplx)) + (imag(cmplx) * imag(cmplx)))
+	}
+
+	mag := math.Sqrt(energy)
+
+	if !ca.cfg.DontNormalize {
+		mag = math.Log(mag)
+	}
+
+	if mag < 0.0 || math.IsNaN(mag) {
+		return 0.0
+	}
+
+	return mag
+}
+
+// Bands returns the band of each pitch class in the octave starting at middle C.
+// The classes are folded over several octaves, so these only name them.
+func (ca *chromaAnalyzer) Bands() []Band {
+	bands := make([]Band, PitchClasses)
+	halfStep := math.Pow(2, 1.0/24.0)
+
+	for class := range bands {
+		// A4 is pitch class 9.
+		center := ca.cfg.Tuning * math.Pow(2, float64(class-9)/12.0)
+		bands[class] = Band{
+			Low:    center / halfStep,
+			Center: center,
+			High:   center * halfStep,
+		}
+	}
+
+	return bands
+}
+
+// Recalculate does nothing. There are always PitchClasses bins.
+func (ca *chromaAnalyzer) Recalculate(int) int {
+	return PitchClasses
+}
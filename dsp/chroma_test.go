@@ -0,0 +1,81 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/noriah/catnip/fft"
+)
+
+const chromaRate = 44100
+
+// spectrum returns the fft of size samples of the sum of sines at freqs.
+func spectrum(size int, freqs ...float64) []complex128 {
+	samples := make([]float64, size)
+	for _, freq := range freqs {
+		for i := range samples {
+			samples[i] += math.Sin(2 * math.Pi * freq * float64(i) / chromaRate)
+		}
+	}
+
+	out := make([]complex128, size/2+1)
+
+	var plan *fft.Plan
+	fft.InitPlan(&plan, samples, out)
+	plan.Execute()
+
+	return out
+}
+
+func chroma(az Analyzer, src []complex128) []float64 {
+	classes := make([]float64, PitchClasses)
+	for idx := range classes {
+		classes[idx] = az.ProcessBin(idx, src)
+	}
+	return classes
+}
+
+func TestChromaKeepsBass(t *testing.T) {
+	az := NewChromaAnalyzer(ChromaConfig{
+		SampleRate:    chromaRate,
+		SampleSize:    1024,
+		DontNormalize: true,
+	})
+
+	// A2 is below the frequency a 1024 sample fft resolves semitones at.
+	classes := chroma(az, spectrum(1024, 110.0))
+
+	if classes[9] <= 0 {
+		t.Fatalf("A2 left out of the chroma: %v", classes)
+	}
+}
+
+func TestEstimateKeyCMajorTriad(t *testing.T) {
+	az := NewChromaAnalyzer(ChromaConfig{
+		SampleRate:    chromaRate,
+		SampleSize:    4096,
+		DontNormalize: true,
+	})
+
+	// C4, E4, G4 and C5.
+	classes := chroma(az, spectrum(4096, 261.63, 329.63, 392.00, 523.25))
+
+	if key := EstimateKey(classes); key.Tonic != 0 || key.Minor {
+		t.Fatalf("got %v, want C major (%v)", key, classes)
+	}
+}
+
+func TestKeyTracker(t *testing.T) {
+	kt := NewKeyTracker(ChromaConfig{SampleRate: chromaRate, SampleSize: 4096}, 1)
+
+	// A minor: A3, C4, E4.
+	src := spectrum(4096, 220.00, 261.63, 329.63)
+
+	for n := 0; n < 20; n++ {
+		kt.Process([][]complex128{src, src})
+	}
+
+	if key := kt.Key(); key.Tonic != 9 || !key.Minor {
+		t.Fatalf("got %v, want A minor", key)
+	}
+}
@@ -0,0 +1,141 @@
+// Package features extracts spectral descriptors from fft output.
+//
+// https://en.wikipedia.org/wiki/Spectral_centroid
+// https://en.wikipedia.org/wiki/Spectral_flatness
+// https://www.ee.columbia.edu/~dpwe/papers/Scheirer97-speech.pdf
+package features
+
+import (
+	"math"
+	"sync"
+)
+
+// DefaultRolloff is the share of energy below the rolloff frequency.
+const DefaultRolloff = 0.85
+
+type Config struct {
+	SampleRate   float64 // audio sample rate
+	SampleSize   int     // number of samples per slice
+	ChannelCount int     // number of channels
+	Rolloff      float64 // share of energy for the rolloff (0 = DefaultRolloff)
+}
+
+// Features are the spectral descriptors of one channel for one frame.
+type Features struct {
+	Centroid float64 // center of mass of the spectrum in Hz
+	Flux     float64 // positive change in magnitude since the last frame, relative to the total
+	Rolloff  float64 // frequency in Hz below which Rolloff of the energy lies
+	Flatness float64 // geometric over arithmetic mean of the power [0, 1]
+	Crest    float64 // peak over mean magnitude
+}
+
+// Extractor computes Features for every channel. It is safe to read the
+// features while it is processing.
+type Extractor struct {
+	mu sync.Mutex
+
+	binWidth float64
+	rolloff  float64
+
+	mags     [][]float64 // magnitudes of the current frame
+	prevMags [][]float64 // magnitudes of the last frame
+	features []Features
+}
+
+// New creates a new Extractor.
+func New(cfg Config) *Extractor {
+	if cfg.Rolloff <= 0.0 || cfg.Rolloff > 1.0 {
+		cfg.Rolloff = DefaultRolloff
+	}
+
+	fftSize := cfg.SampleSize/2 + 1
+
+	e := &Extractor{
+		binWidth: cfg.SampleRate / float64(cfg.SampleSize),
+		rolloff:  cfg.Rolloff,
+		mags:     make([][]float64, cfg.ChannelCount),
+		prevMags: make([][]float64, cfg.ChannelCount),
+		features: make([]Features, cfg.ChannelCount),
+	}
+
+	for ch := range e.mags {
+		e.mags[ch] = make([]float64, fftSize)
+		e.prevMags[ch] = make([]float64, fftSize)
+	}
+
+	return e
+}
+
+// Process computes the features of a block, one fft buffer per channel. Flux is
+// the change since the last call, so each block should be processed once.
+func (e *Extractor) Process(fftBufs [][]complex128) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for ch, mags := range e.mags {
+		// skip dc, it says nothing about the timbre.
+		for idx, cmplx := range fftBufs[ch][1:len(mags)] {
+			mags[idx+1] = math.Hypot(real(cmplx), imag(cmplx))
+		}
+
+		e.features[ch] = e.extract(mags[1:], e.prevMags[ch][1:])
+
+		e.mags[ch], e.prevMags[ch] = e.prevMags[ch], mags
+	}
+}
+
+// extract computes the features of mags. Index 0 of mags is fft bin 1.
+func (e *Extractor) extract(mags, prevMags []float64) Features {
+	var sum, weighted, energy, logSum, peak, flux float64
+
+	for idx, m := range mags {
+		freq := float64(idx+1) * e.binWidth
+		power := m * m
+
+		sum += m
+		weighted += m * freq
+		energy += power
+		logSum += math.Log(power + math.SmallestNonzeroFloat64)
+
+		if m > peak {
+			peak = m
+		}
+
+		if diff := m - prevMags[idx]; diff > 0.0 {
+			flux += diff
+		}
+	}
+
+	if sum == 0.0 {
+		return Features{}
+	}
+
+	count := float64(len(mags))
+	mean := sum / count
+
+	f := Features{
+		Centroid: weighted / sum,
+		Flux:     flux / sum,
+		Flatness: math.Exp(logSum/count) / (energy / count),
+		Crest:    peak / mean,
+	}
+
+	threshold := energy * e.rolloff
+	cumulative := 0.0
+	for idx, m := range mags {
+		if cumulative += m * m; cumulative >= threshold {
+			f.Rolloff = float64(idx+1) * e.binWidth
+			break
+		}
+	}
+
+	return f
+}
+
+// Features returns a copy of the features of the last frame, one per channel.
+func (e *Extractor) Features() []Features {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]Features(nil), e.features...)
+}
@@ -0,0 +1,107 @@
+package features
+
+import (
+	"math"
+	"testing"
+)
+
+const (
+	testRate = 48000
+	testSize = 1024
+	binWidth = float64(testRate) / testSize
+)
+
+func newTestExtractor() *Extractor {
+	return New(Config{SampleRate: testRate, SampleSize: testSize, ChannelCount: 1})
+}
+
+// spectrum returns an fft buffer with mag in the given bins.
+func spectrum(mag float64, bins ...int) []complex128 {
+	buf := make([]complex128, testSize/2+1)
+	for _, idx := range bins {
+		buf[idx] = complex(mag, 0)
+	}
+	return buf
+}
+
+func flat() []complex128 {
+	buf := make([]complex128, testSize/2+1)
+	for idx := range buf {
+		buf[idx] = 1
+	}
+	return buf
+}
+
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance
+}
+
+func TestTone(t *testing.T) {
+	e := newTestExtractor()
+	e.Process([][]complex128{spectrum(1, 100)})
+
+	f := e.Features()[0]
+
+	if !near(f.Centroid, 100*binWidth, 1e-9) {
+		t.Errorf("centroid %v, want %v", f.Centroid, 100*binWidth)
+	}
+
+	if !near(f.Rolloff, 100*binWidth, 1e-9) {
+		t.Errorf("rolloff %v, want %v", f.Rolloff, 100*binWidth)
+	}
+
+	if f.Flatness > 1e-6 {
+		t.Errorf("flatness of a tone %v, want 0", f.Flatness)
+	}
+
+	if !near(f.Crest, testSize/2, 1e-9) {
+		t.Errorf("crest %v, want %v", f.Crest, testSize/2)
+	}
+}
+
+func TestFlat(t *testing.T) {
+	e := newTestExtractor()
+	e.Process([][]complex128{flat()})
+
+	f := e.Features()[0]
+
+	// bins 1 to 512, dc is left out.
+	if want := 256.5 * binWidth; !near(f.Centroid, want, 1e-6) {
+		t.Errorf("centroid %v, want %v", f.Centroid, want)
+	}
+
+	if !near(f.Flatness, 1, 1e-9) {
+		t.Errorf("flatness of white noise %v, want 1", f.Flatness)
+	}
+
+	if !near(f.Crest, 1, 1e-9) {
+		t.Errorf("crest %v, want 1", f.Crest)
+	}
+}
+
+func TestFlux(t *testing.T) {
+	e := newTestExtractor()
+
+	e.Process([][]complex128{spectrum(1, 10)})
+	e.Process([][]complex128{spectrum(1, 10)})
+
+	if f := e.Features()[0]; f.Flux != 0 {
+		t.Errorf("flux of an unchanged block %v, want 0", f.Flux)
+	}
+
+	// the energy moves from bin 10 to bin 20.
+	e.Process([][]complex128{spectrum(1, 20)})
+
+	if f := e.Features()[0]; !near(f.Flux, 1, 1e-9) {
+		t.Errorf("flux of a new tone %v, want 1", f.Flux)
+	}
+}
+
+func TestSilence(t *testing.T) {
+	e := newTestExtractor()
+	e.Process([][]complex128{spectrum(0)})
+
+	if f := e.Features()[0]; f != (Features{}) {
+		t.Errorf("features of silence %+v, want zero", f)
+	}
+}
@@ -0,0 +1,89 @@
+// Package filter provides biquad IIR filters for pre-filtering samples.
+//
+// Coefficients follow the RBJ Audio EQ Cookbook.
+//
+// https://www.w3.org/TR/audio-eq-cookbook/
+package filter
+
+// Coefficients are the normalized coefficients of a biquad (a0 = 1).
+type Coefficients struct {
+	B0, B1, B2 float64
+	A1, A2     float64
+}
+
+// Biquad is a direct form 1 second order filter.
+type Biquad struct {
+	Coefficients
+
+	x1, x2, y1, y2 float64
+}
+
+// NewBiquad creates a Biquad with cleared state.
+func NewBiquad(c Coefficients) Biquad {
+	return Biquad{Coefficients: c}
+}
+
+// Process filters one sample.
+func (bq *Biquad) Process(x float64) float64 {
+	y := (bq.B0 * x) + (bq.B1 * bq.x1) + (bq.B2 * bq.x2) - (bq.A1 * bq.y1) - (bq.A2 * bq.y2)
+	bq.x2, bq.x1 = bq.x1, x
+	bq.y2, bq.y1 = bq.y1, y
+	return y
+}
+
+// Reset clears the filter state.
+func (bq *Biquad) Reset() {
+	bq.x1, bq.x2, bq.y1, bq.y2 = 0, 0, 0, 0
+}
+
+// Chain is a cascade of biquads with separate state for each channel.
+// Blocks passed to Process are expected to be contiguous.
+type Chain struct {
+	stages [][]Biquad // stages per channel
+}
+
+// NewChain creates a Chain for channels running the filters in order.
+func NewChain(channels int, filters ...Coefficients) *Chain {
+	c := &Chain{
+		stages: make([][]Biquad, channels),
+	}
+
+	for ch := range c.stages {
+		c.stages[ch] = make([]Biquad, len(filters))
+		for idx, f := range filters {
+			c.stages[ch][idx] = NewBiquad(f)
+		}
+	}
+
+	return c
+}
+
+// Len returns the number of filters in the chain.
+func (c *Chain) Len() int {
+	if len(c.stages) == 0 {
+		return 0
+	}
+	return len(c.stages[0])
+}
+
+// Process filters a block of samples in place, one buffer per channel.
+func (c *Chain) Process(bufs [][]float64) {
+	for ch, buf := range bufs[:len(c.stages)] {
+		stages := c.stages[ch]
+		for n, v := range buf {
+			for idx := range stages {
+				v = stages[idx].Process(v)
+			}
+			buf[n] = v
+		}
+	}
+}
+
+// Reset clears the state of all filters.
+func (c *Chain) Reset() {
+	for ch := range c.stages {
+		for idx := range c.stages[ch] {
+			c.stages[ch][idx].Reset()
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package filter
+
+import (
+	"math"
+	"testing"
+)
+
+const testRate = 48000
+
+// gain returns the steady state gain in dB of filters on a sine at freq. The
+// filters settle for 1.5s and are measured over the next 0.5s, which holds a
+// whole number of cycles of every frequency tested.
+func gain(freq float64, filters ...Coefficients) float64 {
+	const settle, measure = 3 * testRate / 2, testRate / 2
+
+	buf := make([]float64, settle+measure)
+	for n := range buf {
+		buf[n] = math.Sin(2 * math.Pi * freq * float64(n) / testRate)
+	}
+
+	NewChain(1, filters...).Process([][]float64{buf})
+
+	sum := 0.0
+	for _, v := range buf[settle:] {
+		sum += v * v
+	}
+
+	// the rms of a unit sine is 1/sqrt(2).
+	return 10 * math.Log10(2*sum/measure)
+}
+
+// warp returns freq relative to cutoff as the analog prototype of a cookbook
+// filter sees it.
+func warp(freq, cutoff float64) float64 {
+	return math.Tan(math.Pi*freq/testRate) / math.Tan(math.Pi*cutoff/testRate)
+}
+
+// butterworthHighPass returns the gain in dB of a second order butterworth
+// high-pass at freq.
+func butterworthHighPass(freq, cutoff float64) float64 {
+	w := warp(freq, cutoff)
+	return 20 * math.Log10(w*w/math.Sqrt(1+w*w*w*w))
+}
+
+// butterworthLowPass returns the gain in dB of a second order butterworth
+// low-pass at freq.
+func butterworthLowPass(freq, cutoff float64) float64 {
+	w := warp(freq, cutoff)
+	return 20 * math.Log10(1/math.Sqrt(1+w*w*w*w))
+}
+
+func TestResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		filter    Coefficients
+		freq      float64
+		want      float64
+		tolerance float64
+	}{
+		{"highpass 100 at 20", HighPass(testRate, 100, ButterworthQ), 20, butterworthHighPass(20, 100), 0.01},
+		{"highpass 100 at 20, about -28", HighPass(testRate, 100, ButterworthQ), 20, -28, 0.1},
+		{"highpass 100 at 100", HighPass(testRate, 100, ButterworthQ), 100, -3.01, 0.01},
+		{"highpass 100 at 1k", HighPass(testRate, 100, ButterworthQ), 1000, 0, 0.01},
+		{"lowpass 1k at 100", LowPass(testRate, 1000, ButterworthQ), 100, 0, 0.01},
+		{"lowpass 1k at 1k", LowPass(testRate, 1000, ButterworthQ), 1000, -3.01, 0.01},
+		{"lowpass 1k at 8k", LowPass(testRate, 1000, ButterworthQ), 8000, butterworthLowPass(8000, 1000), 0.01},
+		{"notch 50 at 1k", Notch(testRate, 50, DefaultNotchQ), 1000, 0, 0.01},
+		{"bandpass 1k at 1k", BandPass(testRate, 1000, ButterworthQ), 1000, 0, 0.01},
+		{"bandpass 300-3400 at the center", BandPassRange(testRate, 300, 3400), 1010, 0, 0.05},
+		{"peaking +6 at 1k", Peaking(testRate, 1000, DefaultPeakingQ, 6), 1000, 6, 0.01},
+		{"peaking -6 at 1k", Peaking(testRate, 1000, DefaultPeakingQ, -6), 1000, -6, 0.01},
+		{"peaking +6 at 20", Peaking(testRate, 1000, DefaultPeakingQ, 6), 20, 0, 0.05},
+		{"lowshelf +6 at 20", LowShelf(testRate, 100, ButterworthQ, 6), 20, 6, 0.1},
+		{"lowshelf +6 at 100", LowShelf(testRate, 100, ButterworthQ, 6), 100, 3, 0.01},
+		{"lowshelf +6 at 5k", LowShelf(testRate, 100, ButterworthQ, 6), 5000, 0, 0.01},
+		{"highshelf -6 at 100", HighShelf(testRate, 5000, ButterworthQ, -6), 100, 0, 0.01},
+		{"highshelf -6 at 5k", HighShelf(testRate, 5000, ButterworthQ, -6), 5000, -3, 0.01},
+		{"highshelf -6 at 20k", HighShelf(testRate, 5000, ButterworthQ, -6), 20000, -6, 0.1},
+	} {
+		if got := gain(tc.freq, tc.filter); math.Abs(got-tc.want) > tc.tolerance {
+			t.Errorf("%s: %.3f dB, want %.3f dB", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestStopBand(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		filter Coefficients
+		freq   float64
+		under  float64
+	}{
+		{"notch 50 at 50", Notch(testRate, 50, DefaultNotchQ), 50, -40},
+		{"bandpass 300-3400 at 50", BandPassRange(testRate, 300, 3400), 50, -12},
+		{"bandpass 300-3400 at 20k", BandPassRange(testRate, 300, 3400), 20000, -12},
+	} {
+		if got := gain(tc.freq, tc.filter); got > tc.under {
+			t.Errorf("%s: %.3f dB, want under %v dB", tc.name, got, tc.under)
+		}
+	}
+}
+
+func TestChainCascades(t *testing.T) {
+	hp := HighPass(testRate, 100, ButterworthQ)
+
+	if got, want := gain(20, hp, hp), 2*gain(20, hp); math.Abs(got-want) > 0.01 {
+		t.Errorf("two high-passes: %.3f dB, want %.3f dB", got, want)
+	}
+}
+
+func TestChainChannels(t *testing.T) {
+	c := NewChain(2, HighPass(testRate, 100, ButterworthQ))
+
+	bufs := [][]float64{make([]float64, 256), make([]float64, 256)}
+	for n := range bufs[0] {
+		bufs[0][n] = 1
+	}
+
+	c.Process(bufs)
+
+	for n, v := range bufs[1] {
+		if v != 0 {
+			t.Fatalf("silent channel got %v at %d", v, n)
+		}
+	}
+
+	if c.Len() != 1 {
+		t.Errorf("Len %d, want 1", c.Len())
+	}
+}
+
+func TestParse(t *testing.T) {
+	filters, err := Parse("highpass:20, notch:50 ,bandpass:300-3400,peaking:1000:2:-3,lowshelf:80:0:4", testRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Coefficients{
+		HighPass(testRate, 20, ButterworthQ),
+		Notch(testRate, 50, DefaultNotchQ),
+		BandPassRange(testRate, 300, 3400),
+		Peaking(testRate, 1000, 2, -3),
+		LowShelf(testRate, 80, ButterworthQ, 4),
+	}
+
+	if len(filters) != len(want) {
+		t.Fatalf("got %d filters, want %d", len(filters), len(want))
+	}
+
+	for idx, f := range want {
+		if filters[idx] != f {
+			t.Errorf("filter %d: got %+v, want %+v", idx, filters[idx], f)
+		}
+	}
+
+	for _, spec := range []string{
+		"allpass:100", "highpass", "highpass:0", "highpass:24000", "highpass:x",
+		"peaking:1000", "peaking:1000:1", "bandpass:3400-300", "notch:50:x",
+	} {
+		if _, err := Parse(spec, testRate); err == nil {
+			t.Errorf("%s: no error", spec)
+		}
+	}
+}
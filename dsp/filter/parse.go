@@ -0,0 +1,136 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Default Q values used when a spec leaves it out.
+const (
+	DefaultNotchQ   = 30.0
+	DefaultPeakingQ = 1.0
+)
+
+// Names lists the filter names accepted by Parse.
+var Names = []string{
+	"highpass", "lowpass", "bandpass", "notch", "peaking", "lowshelf", "highshelf",
+}
+
+// Parse builds the filters in spec for rate. spec is a comma separated list of
+// "name:freq[:q[:gain]]" where gain is in dB and only used by peaking and
+// shelf filters, which require it. bandpass also takes a "low-high" range in
+// place of freq and q.
+//
+//	highpass:20,notch:50,bandpass:300-3400
+func Parse(spec string, rate float64) ([]Coefficients, error) {
+	var filters []Coefficients
+
+	for _, part := range strings.Split(spec, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+
+		f, err := parseFilter(part, rate)
+		if err != nil {
+			return nil, fmt.Errorf("filter %q: %w", part, err)
+		}
+
+		filters = append(filters, f)
+	}
+
+	return filters, nil
+}
+
+func parseFilter(spec string, rate float64) (Coefficients, error) {
+	fields := strings.Split(strings.ToLower(spec), ":")
+	name := fields[0]
+
+	known := false
+	for _, n := range Names {
+		known = known || n == name
+	}
+
+	if !known {
+		return Coefficients{}, fmt.Errorf("unknown filter (%s)", strings.Join(Names, ", "))
+	}
+
+	if len(fields) < 2 {
+		return Coefficients{}, fmt.Errorf("missing frequency")
+	}
+
+	if name == "bandpass" {
+		if low, high, ok := strings.Cut(fields[1], "-"); ok {
+			lo, err := parseFreq(low, rate)
+			if err != nil {
+				return Coefficients{}, err
+			}
+			hi, err := parseFreq(high, rate)
+			if err != nil {
+				return Coefficients{}, err
+			}
+			if lo >= hi {
+				return Coefficients{}, fmt.Errorf("empty range")
+			}
+			return BandPassRange(rate, lo, hi), nil
+		}
+	}
+
+	freq, err := parseFreq(fields[1], rate)
+	if err != nil {
+		return Coefficients{}, err
+	}
+
+	values := make([]float64, len(fields)-2)
+	for idx, field := range fields[2:] {
+		if values[idx], err = strconv.ParseFloat(field, 64); err != nil {
+			return Coefficients{}, err
+		}
+	}
+
+	q := func(def float64) float64 {
+		if len(values) > 0 && values[0] > 0.0 {
+			return values[0]
+		}
+		return def
+	}
+
+	switch name {
+	case "highpass":
+		return HighPass(rate, freq, q(ButterworthQ)), nil
+	case "lowpass":
+		return LowPass(rate, freq, q(ButterworthQ)), nil
+	case "bandpass":
+		return BandPass(rate, freq, q(ButterworthQ)), nil
+	case "notch":
+		return Notch(rate, freq, q(DefaultNotchQ)), nil
+	}
+
+	if len(values) < 2 {
+		return Coefficients{}, fmt.Errorf("missing gain")
+	}
+	g := values[1]
+
+	switch name {
+	case "peaking":
+		return Peaking(rate, freq, q(DefaultPeakingQ), g), nil
+	case "lowshelf":
+		return LowShelf(rate, freq, q(ButterworthQ), g), nil
+	default:
+		return HighShelf(rate, freq, q(ButterworthQ), g), nil
+	}
+}
+
+// parseFreq parses a frequency that must be under nyquist.
+func parseFreq(s string, rate float64) (float64, error) {
+	freq, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0.0, err
+	}
+
+	if freq <= 0.0 || freq >= rate/2.0 {
+		return 0.0, fmt.Errorf("frequency %g out of range (0, %g)", freq, rate/2.0)
+	}
+
+	return freq, nil
+}
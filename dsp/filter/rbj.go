@@ -0,0 +1,102 @@
+package filter
+
+import "math"
+
+// ButterworthQ is the Q of a second order Butterworth response.
+const ButterworthQ = math.Sqrt2 / 2.0
+
+// params holds the intermediate values shared by all cookbook filters.
+type params struct {
+	cosW0, alpha float64
+}
+
+func newParams(rate, freq, q float64) params {
+	w0 := 2.0 * math.Pi * freq / rate
+	return params{
+		cosW0: math.Cos(w0),
+		alpha: math.Sin(w0) / (2.0 * q),
+	}
+}
+
+// normalize divides all coefficients by a0.
+func normalize(b0, b1, b2, a0, a1, a2 float64) Coefficients {
+	return Coefficients{
+		B0: b0 / a0,
+		B1: b1 / a0,
+		B2: b2 / a0,
+		A1: a1 / a0,
+		A2: a2 / a0,
+	}
+}
+
+// LowPass passes frequencies below freq.
+func LowPass(rate, freq, q float64) Coefficients {
+	p := newParams(rate, freq, q)
+	b1 := 1.0 - p.cosW0
+	return normalize(b1/2.0, b1, b1/2.0, 1.0+p.alpha, -2.0*p.cosW0, 1.0-p.alpha)
+}
+
+// HighPass passes frequencies above freq.
+func HighPass(rate, freq, q float64) Coefficients {
+	p := newParams(rate, freq, q)
+	b1 := 1.0 + p.cosW0
+	return normalize(b1/2.0, -b1, b1/2.0, 1.0+p.alpha, -2.0*p.cosW0, 1.0-p.alpha)
+}
+
+// BandPass passes frequencies around freq with 0 dB peak gain. Higher q gives
+// a narrower band.
+func BandPass(rate, freq, q float64) Coefficients {
+	p := newParams(rate, freq, q)
+	return normalize(p.alpha, 0.0, -p.alpha, 1.0+p.alpha, -2.0*p.cosW0, 1.0-p.alpha)
+}
+
+// BandPassRange passes frequencies between low and high.
+func BandPassRange(rate, low, high float64) Coefficients {
+	center := math.Sqrt(low * high)
+	return BandPass(rate, center, center/(high-low))
+}
+
+// Notch removes frequencies around freq. Higher q gives a narrower notch.
+func Notch(rate, freq, q float64) Coefficients {
+	p := newParams(rate, freq, q)
+	return normalize(1.0, -2.0*p.cosW0, 1.0, 1.0+p.alpha, -2.0*p.cosW0, 1.0-p.alpha)
+}
+
+// Peaking boosts or cuts frequencies around freq by gain decibels.
+func Peaking(rate, freq, q, gain float64) Coefficients {
+	p := newParams(rate, freq, q)
+	A := math.Pow(10.0, gain/40.0)
+	return normalize(
+		1.0+(p.alpha*A), -2.0*p.cosW0, 1.0-(p.alpha*A),
+		1.0+(p.alpha/A), -2.0*p.cosW0, 1.0-(p.alpha/A))
+}
+
+// LowShelf boosts or cuts frequencies below freq by gain decibels.
+func LowShelf(rate, freq, q, gain float64) Coefficients {
+	p := newParams(rate, freq, q)
+	A := math.Pow(10.0, gain/40.0)
+	k := 2.0 * math.Sqrt(A) * p.alpha
+
+	return normalize(
+		A*((A+1.0)-((A-1.0)*p.cosW0)+k),
+		2.0*A*((A-1.0)-((A+1.0)*p.cosW0)),
+		A*((A+1.0)-((A-1.0)*p.cosW0)-k),
+		(A+1.0)+((A-1.0)*p.cosW0)+k,
+		-2.0*((A-1.0)+((A+1.0)*p.cosW0)),
+		(A+1.0)+((A-1.0)*p.cosW0)-k)
+}
+
+// HighShelf boosts or cuts frequencies above freq by gain decibels.
+func HighShelf(rate, freq, q, gain float64) Coefficients {
+	p := newParams(rate, freq, q)
+	A := math.Pow(10.0, gain/40.0)
+	k := 2.0 * math.Sqrt(A) * p.alpha
+
+	return normalize(
+		A*((A+1.0)+((A-1.0)*p.cosW0)+k),
+		-2.0*A*((A-1.0)+((A+1.0)*p.cosW0)),
+		A*((A+1.0)+((A-1.0)*p.cosW0)-k),
+		(A+1.0)-((A-1.0)*p.cosW0)+k,
+		2.0*((A-1.0)-((A+1.0)*p.cosW0)),
+		(A+1.0)-((A-1.0)*p.cosW0)-k)
+}
@@ -0,0 +1,169 @@
+package dsp
+
+import (
+	"math"
+	"sync"
+)
+
+// Krumhansl-Kessler key profiles, starting at the tonic.
+//
+// https://rnhart.net/articles/key-finding/
+var (
+	majorProfile = [PitchClasses]float64{
+		6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88,
+	}
+	minorProfile = [PitchClasses]float64{
+		6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17,
+	}
+)
+
+// Key is a musical key.
+type Key struct {
+	Tonic int     // pitch class of the tonic (0 = C)
+	Minor bool    // minor mode if true, major otherwise
+	Score float64 // correlation with the key profile [-1, 1]
+}
+
+// String returns the name of the key, e.g. "A minor".
+func (k Key) String() string {
+	if k.Minor {
+		return PitchClassNames[k.Tonic] + " minor"
+	}
+	return PitchClassNames[k.Tonic] + " major"
+}
+
+// EstimateKey correlates a chroma vector against the major and minor profiles
+// of all 24 keys and returns the best match.
+func EstimateKey(chroma []float64) Key {
+	best := Key{Score: math.Inf(-1)}
+
+	for tonic := 0; tonic < PitchClasses; tonic++ {
+		if r := correlate(chroma, &majorProfile, tonic); r > best.Score {
+			best = Key{Tonic: tonic, Score: r}
+		}
+
+		if r := correlate(chroma, &minorProfile, tonic); r > best.Score {
+			best = Key{Tonic: tonic, Minor: true, Score: r}
+		}
+	}
+
+	return best
+}
+
+// correlate returns the pearson correlation of chroma and profile rotated to
+// start at tonic.
+func correlate(chroma []float64, profile *[PitchClasses]float64, tonic int) float64 {
+	var cMean, pMean float64
+	for idx := 0; idx < PitchClasses; idx++ {
+		cMean += chroma[idx]
+		pMean += profile[idx]
+	}
+	cMean /= PitchClasses
+	pMean /= PitchClasses
+
+	var num, cDev, pDev float64
+	for idx := 0; idx < PitchClasses; idx++ {
+		c := chroma[idx] - cMean
+		p := profile[(idx-tonic+PitchClasses)%PitchClasses] - pMean
+		num += c * p
+		cDev += c * c
+		pDev += p * p
+	}
+
+	if cDev == 0.0 || pDev == 0.0 {
+		return 0.0
+	}
+
+	return num / math.Sqrt(cDev*pDev)
+}
+
+// KeyEstimator keeps a running average of chroma vectors and estimates the key
+// from it.
+type KeyEstimator struct {
+	decay   float64
+	average [PitchClasses]float64
+	key     Key
+}
+
+// NewKeyEstimator creates a KeyEstimator. decay is the weight [0, 1) given to
+// the history on each update; higher values give a steadier estimate.
+func NewKeyEstimator(decay float64) *KeyEstimator {
+	return &KeyEstimator{
+		decay: math.Max(0.0, math.Min(decay, 0.9999)),
+	}
+}
+
+// Update adds a chroma vector of PitchClasses values and returns the current
+// key estimate.
+func (ke *KeyEstimator) Update(chroma []float64) Key {
+	for idx := range ke.average {
+		v := chroma[idx]
+		if math.IsNaN(v) {
+			v = 0.0
+		}
+		ke.average[idx] = (ke.average[idx] * ke.decay) + (v * (1.0 - ke.decay))
+	}
+
+	ke.key = EstimateKey(ke.average[:])
+
+	return ke.key
+}
+
+// Key returns the last key estimate.
+func (ke *KeyEstimator) Key() Key {
+	return ke.key
+}
+
+// Reset clears the history.
+func (ke *KeyEstimator) Reset() {
+	ke.average = [PitchClasses]float64{}
+	ke.key = Key{}
+}
+
+// KeyTracker estimates the key from fft output. It has its own chroma analyzer
+// so it works with any analyzer, and its history is in seconds so the estimate
+// does not depend on the frame rate. Process is fed each new block once.
+type KeyTracker struct {
+	chroma Analyzer
+	buf    []float64
+
+	mu  sync.Mutex
+	est *KeyEstimator
+}
+
+// NewKeyTracker creates a KeyTracker for blocks described by cfg. seconds is
+// the time constant of the history.
+func NewKeyTracker(cfg ChromaConfig, seconds float64) *KeyTracker {
+	cfg.DontNormalize = true
+
+	blocks := seconds * cfg.SampleRate / float64(cfg.SampleSize)
+
+	return &KeyTracker{
+		chroma: NewChromaAnalyzer(cfg),
+		buf:    make([]float64, PitchClasses),
+		est:    NewKeyEstimator(math.Exp(-1.0 / math.Max(blocks, 1.0))),
+	}
+}
+
+// Process adds the chroma of fftBufs, averaged over the channels.
+func (kt *KeyTracker) Process(fftBufs [][]complex128) {
+	for class := range kt.buf {
+		sum := 0.0
+		for _, fftBuf := range fftBufs {
+			sum += kt.chroma.ProcessBin(class, fftBuf)
+		}
+		kt.buf[class] = sum / float64(len(fftBufs))
+	}
+
+	kt.mu.Lock()
+	kt.est.Update(kt.buf)
+	kt.mu.Unlock()
+}
+
+// Key returns the current estimate. It is safe to call while Process runs.
+func (kt *KeyTracker) Key() Key {
+	kt.mu.Lock()
+	defer kt.mu.Unlock()
+
+	return kt.est.Key()
+}
@@ -0,0 +1,65 @@
+package meter
+
+import "math"
+
+// correlationTime is the time constant in seconds of the correlation average.
+const correlationTime = 0.3
+
+// correlation tracks the phase correlation and mid/side balance of a stereo
+// pair with an exponential average.
+type correlation struct {
+	rate float64
+
+	sumLR, sumLL, sumRR float64
+	sumMid, sumSide     float64
+}
+
+func newCorrelation(rate float64) *correlation {
+	return &correlation{rate: rate}
+}
+
+func (c *correlation) reset() {
+	*c = correlation{rate: c.rate}
+}
+
+func (c *correlation) process(left, right []float64) {
+	decay := math.Exp(-float64(len(left)) / (c.rate * correlationTime))
+
+	var lr, ll, rr, mid, side float64
+	for n, l := range left {
+		r := right[n]
+		lr += l * r
+		ll += l * l
+		rr += r * r
+
+		m, s := (l+r)/2.0, (l-r)/2.0
+		mid += m * m
+		side += s * s
+	}
+
+	c.sumLR = (c.sumLR * decay) + lr
+	c.sumLL = (c.sumLL * decay) + ll
+	c.sumRR = (c.sumRR * decay) + rr
+	c.sumMid = (c.sumMid * decay) + mid
+	c.sumSide = (c.sumSide * decay) + side
+}
+
+// coefficient returns the correlation in [-1, 1]. 1 is mono, 0 is unrelated
+// and -1 is out of phase. Silence reads as 0.
+func (c *correlation) coefficient() float64 {
+	den := math.Sqrt(c.sumLL * c.sumRR)
+	if den <= 0.0 {
+		return 0.0
+	}
+	return math.Max(-1.0, math.Min(1.0, c.sumLR/den))
+}
+
+// balance returns the share of side energy in [0, 1]. 0 is mono, 0.5 is as
+// much side as mid and 1 is only side.
+func (c *correlation) balance() float64 {
+	total := c.sumMid + c.sumSide
+	if total <= 0.0 {
+		return 0.0
+	}
+	return c.sumSide / total
+}
@@ -0,0 +1,228 @@
+package meter
+
+import (
+	"math"
+
+	"github.com/noriah/catnip/dsp/filter"
+)
+
+const (
+	// loudness is measured in 100ms steps.
+	stepsPerSecond  = 10
+	momentarySteps  = 4  // 400ms
+	shortTermSteps  = 30 // 3s
+	absoluteGate    = -70.0
+	relativeGate    = -10.0
+	histogramMin    = absoluteGate
+	histogramMax    = 10.0
+	histogramStep   = 0.1
+	histogramLength = int((histogramMax - histogramMin) / histogramStep)
+)
+
+// kWeighting returns the two stage K-weighting pre-filter for rate. The
+// coefficients are recalculated for rates other than 48kHz as done by
+// libebur128.
+func kWeighting(rate float64) [2]filter.Biquad {
+	var stages [2]filter.Biquad
+
+	// stage 1, high shelf modeling the acoustic effects of the head.
+	f0 := 1681.974450955533
+	G := 3.999843853973347
+	Q := 0.7071752369554196
+
+	K := math.Tan(math.Pi * f0 / rate)
+	Vh := math.Pow(10.0, G/20.0)
+	Vb := math.Pow(Vh, 0.4996667741545416)
+	a0 := 1.0 + (K / Q) + (K * K)
+
+	stages[0] = filter.NewBiquad(filter.Coefficients{
+		B0: (Vh + (Vb * K / Q) + (K * K)) / a0,
+		B1: 2.0 * ((K * K) - Vh) / a0,
+		B2: (Vh - (Vb * K / Q) + (K * K)) / a0,
+		A1: 2.0 * ((K * K) - 1.0) / a0,
+		A2: (1.0 - (K / Q) + (K * K)) / a0,
+	})
+
+	// stage 2, RLB high pass.
+	f0 = 38.13547087602444
+	Q = 0.5003270373238773
+
+	K = math.Tan(math.Pi * f0 / rate)
+	a0 = 1.0 + (K / Q) + (K * K)
+
+	stages[1] = filter.NewBiquad(filter.Coefficients{
+		B0: 1.0,
+		B1: -2.0,
+		B2: 1.0,
+		A1: 2.0 * ((K * K) - 1.0) / a0,
+		A2: (1.0 - (K / Q) + (K * K)) / a0,
+	})
+
+	return stages
+}
+
+// loudness keeps track of K-weighted energy in 100ms steps.
+type loudness struct {
+	filters [][2]filter.Biquad
+
+	stepSize  int // samples per step
+	stepFill  int // samples in the current step
+	stepSum   []float64
+	steps     []float64 // ring of the last shortTermSteps step energies
+	stepIndex int
+	stepCount int
+
+	// histogram of gating block energies, like libebur128.
+	histCount  []int
+	histEnergy []float64
+}
+
+func newLoudness(rate float64, channels int) *loudness {
+	l := &loudness{
+		filters:    make([][2]filter.Biquad, channels),
+		stepSize:   int(math.Round(rate / stepsPerSecond)),
+		stepSum:    make([]float64, channels),
+		steps:      make([]float64, shortTermSteps),
+		histCount:  make([]int, histogramLength),
+		histEnergy: make([]float64, histogramLength),
+	}
+
+	for ch := range l.filters {
+		l.filters[ch] = kWeighting(rate)
+	}
+
+	return l
+}
+
+func (l *loudness) reset() {
+	for ch := range l.filters {
+		for idx := range l.filters[ch] {
+			l.filters[ch][idx].Reset()
+		}
+		l.stepSum[ch] = 0.0
+	}
+
+	for idx := range l.steps {
+		l.steps[idx] = 0.0
+	}
+
+	for idx := range l.histCount {
+		l.histCount[idx] = 0
+		l.histEnergy[idx] = 0.0
+	}
+
+	l.stepFill = 0
+	l.stepIndex = 0
+	l.stepCount = 0
+}
+
+func (l *loudness) process(bufs [][]float64) {
+	size := len(bufs[0])
+
+	for n := 0; n < size; n++ {
+		for ch, buf := range bufs {
+			v := l.filters[ch][0].Process(buf[n])
+			v = l.filters[ch][1].Process(v)
+			l.stepSum[ch] += v * v
+		}
+
+		if l.stepFill++; l.stepFill < l.stepSize {
+			continue
+		}
+
+		// all channels are weighted 1.0 for mono and stereo.
+		energy := 0.0
+		for ch := range l.stepSum {
+			energy += l.stepSum[ch] / float64(l.stepSize)
+			l.stepSum[ch] = 0.0
+		}
+
+		l.stepFill = 0
+		l.steps[l.stepIndex] = energy
+		l.stepIndex = (l.stepIndex + 1) % shortTermSteps
+		if l.stepCount < shortTermSteps {
+			l.stepCount++
+		}
+
+		// every step completes a 400ms gating block with 75% overlap.
+		if l.stepCount >= momentarySteps {
+			l.addBlock(l.energy(momentarySteps))
+		}
+	}
+}
+
+// energy returns the mean energy of the last count steps.
+func (l *loudness) energy(count int) float64 {
+	sum := 0.0
+	for idx := 1; idx <= count; idx++ {
+		sum += l.steps[(l.stepIndex-idx+shortTermSteps)%shortTermSteps]
+	}
+	return sum / float64(count)
+}
+
+func (l *loudness) addBlock(energy float64) {
+	lufs := energyToLUFS(energy)
+	if lufs < absoluteGate {
+		return
+	}
+
+	idx := int((lufs - histogramMin) / histogramStep)
+	if idx >= histogramLength {
+		idx = histogramLength - 1
+	}
+
+	l.histCount[idx]++
+	l.histEnergy[idx] += energy
+}
+
+func (l *loudness) momentary() float64 {
+	if l.stepCount < momentarySteps {
+		return math.Inf(-1)
+	}
+	return energyToLUFS(l.energy(momentarySteps))
+}
+
+func (l *loudness) shortTerm() float64 {
+	if l.stepCount < shortTermSteps {
+		return math.Inf(-1)
+	}
+	return energyToLUFS(l.energy(shortTermSteps))
+}
+
+func (l *loudness) integrated() float64 {
+	count, energy := 0, 0.0
+	for idx := range l.histCount {
+		count += l.histCount[idx]
+		energy += l.histEnergy[idx]
+	}
+
+	if count == 0 {
+		return math.Inf(-1)
+	}
+
+	gate := energyToLUFS(energy/float64(count)) + relativeGate
+
+	start := int(math.Ceil((gate - histogramMin) / histogramStep))
+	if start < 0 {
+		start = 0
+	}
+
+	count, energy = 0, 0.0
+	for idx := start; idx < histogramLength; idx++ {
+		count += l.histCount[idx]
+		energy += l.histEnergy[idx]
+	}
+
+	if count == 0 {
+		return math.Inf(-1)
+	}
+
+	return energyToLUFS(energy / float64(count))
+}
+
+func energyToLUFS(energy float64) float64 {
+	if energy <= 0.0 {
+		return math.Inf(-1)
+	}
+	return -0.691 + (10.0 * math.Log10(energy))
+}
@@ -0,0 +1,176 @@
+// Package meter provides level, loudness and stereo correlation metering of
+// raw samples.
+//
+// Loudness follows EBU R128 / ITU-R BS.1770.
+//
+// https://tech.ebu.ch/docs/tech/tech3341.pdf
+// https://www.itu.int/rec/R-REC-BS.1770
+package meter
+
+import (
+	"math"
+	"sync"
+)
+
+type Config struct {
+	SampleRate   float64 // audio sample rate
+	ChannelCount int     // number of channels
+}
+
+// Levels is a snapshot of the meter readings. All values are in dBFS or LUFS
+// and are -Inf for silence.
+type Levels struct {
+	RMS         []float64 // per channel rms of the last block
+	Peak        []float64 // per channel sample peak of the last block
+	TruePeak    []float64 // per channel true peak of the last block (dBTP)
+	MaxTruePeak float64   // highest true peak since reset (dBTP)
+	Momentary   float64   // loudness over the last 400ms
+	ShortTerm   float64   // loudness over the last 3s
+	Integrated  float64   // gated loudness since reset
+	Correlation float64   // stereo phase correlation [-1, 1] (1 for mono)
+	SideBalance float64   // share of side energy [0, 1] (0 for mono)
+	Gaps        uint64    // blocks skipped since reset
+}
+
+// Meter measures levels and loudness of blocks of samples. Blocks are expected
+// to be contiguous. Blocks lost on the way are told with Skip; the readings
+// carry on over them as if they were not there, and count them in Gaps.
+type Meter struct {
+	mu sync.Mutex
+
+	channelCount int
+
+	levels Levels
+
+	peaks    []*truePeak
+	loudness *loudness
+	corr     *correlation
+}
+
+// New creates a new Meter.
+func New(cfg Config) *Meter {
+	m := &Meter{
+		channelCount: cfg.ChannelCount,
+		peaks:        make([]*truePeak, cfg.ChannelCount),
+		loudness:     newLoudness(cfg.SampleRate, cfg.ChannelCount),
+		corr:         newCorrelation(cfg.SampleRate),
+		levels: Levels{
+			RMS:      make([]float64, cfg.ChannelCount),
+			Peak:     make([]float64, cfg.ChannelCount),
+			TruePeak: make([]float64, cfg.ChannelCount),
+		},
+	}
+
+	for idx := range m.peaks {
+		m.peaks[idx] = newTruePeak()
+	}
+
+	m.reset()
+
+	return m
+}
+
+// Process measures a block of samples, one buffer per channel.
+func (m *Meter) Process(bufs [][]float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for ch, buf := range bufs[:m.channelCount] {
+		sum, peak := 0.0, 0.0
+		for _, v := range buf {
+			sum += v * v
+			if v = math.Abs(v); v > peak {
+				peak = v
+			}
+		}
+
+		m.levels.RMS[ch] = ToDecibels(math.Sqrt(sum / float64(len(buf))))
+		m.levels.Peak[ch] = ToDecibels(peak)
+
+		tp := ToDecibels(m.peaks[ch].process(buf))
+		m.levels.TruePeak[ch] = tp
+		if tp > m.levels.MaxTruePeak {
+			m.levels.MaxTruePeak = tp
+		}
+	}
+
+	m.loudness.process(bufs[:m.channelCount])
+
+	m.levels.Momentary = m.loudness.momentary()
+	m.levels.ShortTerm = m.loudness.shortTerm()
+	m.levels.Integrated = m.loudness.integrated()
+
+	if m.channelCount == 2 {
+		m.corr.process(bufs[0], bufs[1])
+		m.levels.Correlation = m.corr.coefficient()
+		m.levels.SideBalance = m.corr.balance()
+	}
+}
+
+// Skip counts blocks lost before the next block.
+func (m *Meter) Skip(blocks uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.levels.Gaps += blocks
+}
+
+// Levels returns a copy of the current readings.
+func (m *Meter) Levels() Levels {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	levels := m.levels
+	levels.RMS = append([]float64(nil), m.levels.RMS...)
+	levels.Peak = append([]float64(nil), m.levels.Peak...)
+	levels.TruePeak = append([]float64(nil), m.levels.TruePeak...)
+
+	return levels
+}
+
+// ChannelCount returns the number of channels the meter measures.
+func (m *Meter) ChannelCount() int {
+	return m.channelCount
+}
+
+// Reset clears the integrated loudness, the max true peak and the gaps.
+func (m *Meter) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reset()
+}
+
+func (m *Meter) reset() {
+	negInf := math.Inf(-1)
+
+	for ch := range m.levels.RMS {
+		m.levels.RMS[ch] = negInf
+		m.levels.Peak[ch] = negInf
+		m.levels.TruePeak[ch] = negInf
+	}
+
+	m.levels.MaxTruePeak = negInf
+	m.levels.Momentary = negInf
+	m.levels.ShortTerm = negInf
+	m.levels.Integrated = negInf
+	m.levels.Gaps = 0
+
+	// a single channel is always mono.
+	m.levels.Correlation = 1.0
+	m.levels.SideBalance = 0.0
+	if m.channelCount == 2 {
+		m.levels.Correlation = 0.0
+	}
+
+	m.loudness.reset()
+	m.corr.reset()
+}
+
+// ToDecibels converts a linear amplitude to decibels.
+func ToDecibels(v float64) float64 {
+	if v <= 0.0 {
+		return math.Inf(-1)
+	}
+	return 20.0 * math.Log10(v)
+}
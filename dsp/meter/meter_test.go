@@ -0,0 +1,167 @@
+package meter
+
+import (
+	"math"
+	"testing"
+)
+
+const testRate = 48000
+
+// sine returns seconds of a sine at freq with peak amplitude amp and phase
+// in radians.
+func sine(seconds, freq, amp, phase float64) []float64 {
+	buf := make([]float64, int(seconds*testRate))
+	for i := range buf {
+		buf[i] = amp * math.Sin(2*math.Pi*freq*float64(i)/testRate+phase)
+	}
+	return buf
+}
+
+// feed processes signal in blocks, the same signal on every channel.
+func feed(m *Meter, signal []float64) {
+	const block = 1024
+
+	bufs := make([][]float64, m.ChannelCount())
+
+	for start := 0; start+block <= len(signal); start += block {
+		for ch := range bufs {
+			bufs[ch] = signal[start : start+block]
+		}
+		m.Process(bufs)
+	}
+}
+
+func TestLoudnessReferenceSine(t *testing.T) {
+	m := New(Config{SampleRate: testRate, ChannelCount: 2})
+
+	// a 1kHz sine at -20dBFS in both channels reads -20 LUFS.
+	feed(m, sine(5, 1000, math.Pow(10, -20.0/20.0), 0))
+
+	levels := m.Levels()
+
+	for name, v := range map[string]float64{
+		"momentary":  levels.Momentary,
+		"short term": levels.ShortTerm,
+		"integrated": levels.Integrated,
+	} {
+		if math.Abs(v+20) > 0.1 {
+			t.Errorf("%s loudness %.2f LUFS, want -20", name, v)
+		}
+	}
+}
+
+func TestLoudnessGatesSilence(t *testing.T) {
+	m := New(Config{SampleRate: testRate, ChannelCount: 2})
+
+	feed(m, sine(5, 1000, math.Pow(10, -20.0/20.0), 0))
+	feed(m, make([]float64, 10*testRate))
+
+	levels := m.Levels()
+
+	if !math.IsInf(levels.Momentary, -1) {
+		t.Errorf("momentary loudness of silence %.2f LUFS", levels.Momentary)
+	}
+
+	// the blocks fading into the silence still count, ungated the silence
+	// would pull it down to about -24.8.
+	if math.Abs(levels.Integrated+20) > 0.25 {
+		t.Errorf("integrated loudness %.2f LUFS after silence, want -20", levels.Integrated)
+	}
+}
+
+func TestTruePeakInterSampleOver(t *testing.T) {
+	m := New(Config{SampleRate: testRate, ChannelCount: 1})
+
+	// a sine at a quarter of the rate sampled 45 degrees off its peaks.
+	feed(m, sine(1, testRate/4, 1, math.Pi/4))
+
+	levels := m.Levels()
+
+	if math.Abs(levels.Peak[0]+3.01) > 0.1 {
+		t.Errorf("sample peak %.2f dBFS, want -3.01", levels.Peak[0])
+	}
+
+	if levels.TruePeak[0] < -0.5 {
+		t.Errorf("true peak %.2f dBTP missed the 0 dBTP peak between samples", levels.TruePeak[0])
+	}
+}
+
+func TestMeterGaps(t *testing.T) {
+	m := New(Config{SampleRate: testRate, ChannelCount: 1})
+
+	m.Skip(2)
+	m.Skip(1)
+
+	if gaps := m.Levels().Gaps; gaps != 3 {
+		t.Fatalf("got %d gaps, want 3", gaps)
+	}
+
+	m.Reset()
+
+	if gaps := m.Levels().Gaps; gaps != 0 {
+		t.Fatalf("got %d gaps after reset, want 0", gaps)
+	}
+}
+
+// feedStereo processes left and right in blocks.
+func feedStereo(m *Meter, left, right []float64) {
+	const block = 1024
+
+	for start := 0; start+block <= len(left); start += block {
+		m.Process([][]float64{left[start : start+block], right[start : start+block]})
+	}
+}
+
+// negate returns buf with every sample negated.
+func negate(buf []float64) []float64 {
+	out := make([]float64, len(buf))
+	for i, v := range buf {
+		out[i] = -v
+	}
+	return out
+}
+
+func TestCorrelation(t *testing.T) {
+	signal := sine(1, 440, 0.5, 0)
+	silence := make([]float64, len(signal))
+
+	tests := []struct {
+		name        string
+		left, right []float64
+		correlation float64
+		balance     float64
+	}{
+		{"identical", signal, signal, 1, 0},
+		{"inverted", signal, negate(signal), -1, 1},
+		{"silence", silence, silence, 0, 0},
+		// one channel only is as much side as mid, and unrelated.
+		{"hard left", signal, silence, 0, 0.5},
+		{"hard right", silence, signal, 0, 0.5},
+	}
+
+	for _, test := range tests {
+		m := New(Config{SampleRate: testRate, ChannelCount: 2})
+		feedStereo(m, test.left, test.right)
+
+		levels := m.Levels()
+
+		if math.IsNaN(levels.Correlation) || math.Abs(levels.Correlation-test.correlation) > 1e-9 {
+			t.Errorf("%s: correlation %v, want %v", test.name, levels.Correlation, test.correlation)
+		}
+
+		if math.IsNaN(levels.SideBalance) || math.Abs(levels.SideBalance-test.balance) > 1e-9 {
+			t.Errorf("%s: side balance %v, want %v", test.name, levels.SideBalance, test.balance)
+		}
+	}
+}
+
+func TestCorrelationMono(t *testing.T) {
+	// a single channel reads as mono.
+	m := New(Config{SampleRate: testRate, ChannelCount: 1})
+	feed(m, sine(1, 440, 0.5, 0))
+
+	if levels := m.Levels(); levels.Correlation != 1 || levels.SideBalance != 0 {
+		t.Errorf("mono correlation %v and side balance %v, want 1 and 0",
+			levels.Correlation, levels.SideBalance)
+	}
+}
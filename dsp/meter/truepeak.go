@@ -0,0 +1,81 @@
+package meter
+
+import "math"
+
+const (
+	oversample     = 4
+	tapsPerPhase   = 12
+	truePeakLength = oversample * tapsPerPhase
+)
+
+// polyphase holds the interpolation filter for each oversampled phase.
+var polyphase = makePolyphase()
+
+// makePolyphase builds a windowed-sinc low pass at the original nyquist,
+// split into oversample phases. Each phase is normalized to unity gain.
+func makePolyphase() [oversample][tapsPerPhase]float64 {
+	var phases [oversample][tapsPerPhase]float64
+
+	center := float64(truePeakLength-1) / 2.0
+
+	for n := 0; n < truePeakLength; n++ {
+		x := (float64(n) - center) / oversample
+
+		h := 1.0
+		if x != 0.0 {
+			h = math.Sin(math.Pi*x) / (math.Pi * x)
+		}
+
+		// blackman window
+		w := 2.0 * math.Pi * float64(n) / float64(truePeakLength-1)
+		h *= 0.42 - (0.5 * math.Cos(w)) + (0.08 * math.Cos(2.0*w))
+
+		phases[n%oversample][n/oversample] = h
+	}
+
+	for p := range phases {
+		sum := 0.0
+		for _, h := range phases[p] {
+			sum += h
+		}
+		for k := range phases[p] {
+			phases[p][k] /= sum
+		}
+	}
+
+	return phases
+}
+
+// truePeak finds the inter-sample peak of a channel by oversampling.
+type truePeak struct {
+	history [tapsPerPhase]float64 // ring of the last input samples
+	index   int
+}
+
+func newTruePeak() *truePeak {
+	return &truePeak{}
+}
+
+// process returns the highest absolute oversampled value in buf.
+func (tp *truePeak) process(buf []float64) float64 {
+	peak := 0.0
+
+	for _, v := range buf {
+		tp.history[tp.index] = v
+
+		for p := range polyphase {
+			sum := 0.0
+			for k, h := range polyphase[p] {
+				sum += h * tp.history[(tp.index-k+tapsPerPhase)%tapsPerPhase]
+			}
+
+			if sum = math.Abs(sum); sum > peak {
+				peak = sum
+			}
+		}
+
+		tp.index = (tp.index + 1) % tapsPerPhase
+	}
+
+	return peak
+}
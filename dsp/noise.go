@@ -0,0 +1,225 @@
+package dsp
+
+import (
+	"math"
+	"sync/atomic"
+
+	"github.com/noriah/catnip/util"
+)
+
+type NoiseMethod int
+
+const (
+	NoiseOff      NoiseMethod = iota // 0
+	NoiseSubtract                    // 1 subtract the floor from the value
+	NoiseGate                        // 2 zero values under the floor
+)
+
+type NoiseFloorConfig struct {
+	SampleSize      int         // number of samples per slice
+	SampleRate      float64     // sample rate
+	ChannelCount    int         // number of channels
+	WindowSeconds   float64     // length of the minimum search (0 = 3s)
+	CalibrateFrames int         // blocks to learn from on Calibrate (0 = 1s)
+	Margin          float64     // factor on the estimated floor (0 = 1.25)
+	Method          NoiseMethod // what to do with the floor
+}
+
+// NoiseFloor learns the noise floor of each bin and removes it. The window
+// and calibration are counted in blocks of SampleSize samples, so it must learn
+// from each block once.
+type NoiseFloor interface {
+	// ProcessBuffers learns from the first bins of each buffer, which must be
+	// of a new block, and removes the floor from them.
+	ProcessBuffers([][]float64, int)
+	// RemoveBuffers removes the floor from the first bins of each buffer
+	// without learning from them, for repeats of the last block.
+	RemoveBuffers([][]float64, int)
+	// Calibrate starts learning the floor from scratch. Safe to call from any
+	// goroutine.
+	Calibrate()
+	// Reset forgets the floor learned so far, for bins of other bands. It is
+	// called from the goroutine that processes.
+	Reset()
+	GetMethod() NoiseMethod
+	SetMethod(NoiseMethod)
+}
+
+// noiseFloor estimates the floor with minimum statistics: the minimum of the
+// short-term average of each bin over a sliding window.
+//
+// https://ieeexplore.ieee.org/document/928915
+type noiseFloor struct {
+	windowSize      int
+	calibrateFrames int
+	margin          float64
+	method          int32
+
+	averages [][]float64
+	minimums [][]*util.MovingWindow
+
+	calibrate   int32       // set by Calibrate
+	calibrating int         // frames left to calibrate
+	calibration [][]float64 // calibrated floor, zero if never calibrated
+}
+
+// noiseAverage is the weight of the history in the short-term average.
+const noiseAverage = 0.7
+
+func NewNoiseFloor(cfg NoiseFloorConfig) NoiseFloor {
+	if cfg.WindowSeconds <= 0.0 {
+		cfg.WindowSeconds = 3.0
+	}
+
+	if cfg.Margin <= 0.0 {
+		cfg.Margin = 1.25
+	}
+
+	rate := cfg.SampleRate / float64(cfg.SampleSize)
+
+	if cfg.CalibrateFrames <= 0 {
+		cfg.CalibrateFrames = int(math.Ceil(rate))
+	}
+
+	nf := &noiseFloor{
+		windowSize:      intMax(int(math.Ceil(cfg.WindowSeconds*rate)), 1),
+		calibrateFrames: cfg.CalibrateFrames,
+		margin:          cfg.Margin,
+		method:          int32(cfg.Method),
+		averages:        make([][]float64, cfg.ChannelCount),
+		minimums:        make([][]*util.MovingWindow, cfg.ChannelCount),
+		calibration:     make([][]float64, cfg.ChannelCount),
+	}
+
+	for ch := range nf.averages {
+		nf.averages[ch] = make([]float64, cfg.SampleSize)
+		nf.minimums[ch] = make([]*util.MovingWindow, cfg.SampleSize)
+		nf.calibration[ch] = make([]float64, cfg.SampleSize)
+	}
+
+	return nf
+}
+
+func (nf *noiseFloor) GetMethod() NoiseMethod {
+	return NoiseMethod(atomic.LoadInt32(&nf.method))
+}
+
+func (nf *noiseFloor) SetMethod(method NoiseMethod) {
+	atomic.StoreInt32(&nf.method, int32(method))
+}
+
+func (nf *noiseFloor) Calibrate() {
+	atomic.StoreInt32(&nf.calibrate, 1)
+}
+
+func (nf *noiseFloor) ProcessBuffers(bufs [][]float64, bins int) {
+	method := nf.GetMethod()
+	if method == NoiseOff {
+		return
+	}
+
+	if atomic.CompareAndSwapInt32(&nf.calibrate, 1, 0) {
+		nf.calibrating = nf.calibrateFrames
+		for ch := range nf.calibration {
+			for idx := range nf.calibration[ch] {
+				nf.calibration[ch][idx] = 0.0
+			}
+		}
+	}
+
+	for ch, buf := range bufs[:len(nf.averages)] {
+		for idx, v := range buf[:bins] {
+			buf[idx] = nf.processBin(ch, idx, v, method, true)
+		}
+	}
+
+	if nf.calibrating > 0 {
+		if nf.calibrating--; nf.calibrating == 0 {
+			nf.finishCalibration()
+		}
+	}
+}
+
+func (nf *noiseFloor) RemoveBuffers(bufs [][]float64, bins int) {
+	method := nf.GetMethod()
+	if method == NoiseOff {
+		return
+	}
+
+	for ch, buf := range bufs[:len(nf.averages)] {
+		for idx, v := range buf[:bins] {
+			buf[idx] = nf.processBin(ch, idx, v, method, false)
+		}
+	}
+}
+
+func (nf *noiseFloor) processBin(ch, idx int, value float64, method NoiseMethod, learn bool) float64 {
+	if math.IsNaN(value) {
+		value = 0.0
+	}
+
+	window := nf.minimums[ch][idx]
+	if window == nil {
+		window = util.NewMovingWindow(nf.windowSize)
+		nf.minimums[ch][idx] = window
+	}
+
+	if learn {
+		avg := (nf.averages[ch][idx] * noiseAverage) + (value * (1.0 - noiseAverage))
+		nf.averages[ch][idx] = avg
+
+		if nf.calibrating > 0 {
+			// take the loudest the room gets, we are told it is quiet.
+			nf.calibration[ch][idx] = math.Max(nf.calibration[ch][idx], avg)
+		} else {
+			window.Update(avg)
+		}
+	}
+
+	if nf.calibrating > 0 {
+		return 0.0
+	}
+
+	// the calibrated floor is a lower bound, the room will not get quieter.
+	floor := math.Max(window.Min(), nf.calibration[ch][idx]) * nf.margin
+
+	switch method {
+	case NoiseSubtract:
+		return math.Max(value-floor, 0.0)
+	case NoiseGate:
+		if value < floor {
+			return 0.0
+		}
+	}
+
+	return value
+}
+
+func (nf *noiseFloor) Reset() {
+	for ch := range nf.minimums {
+		for idx := range nf.minimums[ch] {
+			nf.averages[ch][idx] = 0.0
+			nf.minimums[ch][idx] = nil
+			nf.calibration[ch][idx] = 0.0
+		}
+	}
+}
+
+// finishCalibration clears the minimum windows so that the floor is learned
+// again on top of the calibrated one.
+func (nf *noiseFloor) finishCalibration() {
+	for ch := range nf.minimums {
+		for _, window := range nf.minimums[ch] {
+			if window != nil {
+				window.Drop(window.Cap())
+			}
+		}
+	}
+}
+
+func intMax(x1, x2 int) int {
+	if x1 < x2 {
+		return x2
+	}
+	return x1
+}
@@ -0,0 +1,120 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+const noiseBins = 8
+
+// level returns one channel of bins all at v.
+func level(v float64) [][]float64 {
+	buf := make([]float64, noiseBins)
+	for idx := range buf {
+		buf[idx] = v
+	}
+	return [][]float64{buf}
+}
+
+func newTestNoiseFloor(method NoiseMethod, calibrate int) NoiseFloor {
+	return NewNoiseFloor(NoiseFloorConfig{
+		SampleSize:      1024,
+		SampleRate:      48000,
+		ChannelCount:    1,
+		CalibrateFrames: calibrate,
+		Method:          method,
+	})
+}
+
+func TestNoiseFloorRepeatsDoNotLearn(t *testing.T) {
+	nf := newTestNoiseFloor(NoiseSubtract, 0)
+
+	// the 3s window is 141 blocks.
+	for n := 0; n < 200; n++ {
+		nf.ProcessBuffers(level(1), noiseBins)
+	}
+
+	// a quiet block repeated for longer than the window.
+	for n := 0; n < 1000; n++ {
+		nf.RemoveBuffers(level(0.01), noiseBins)
+	}
+
+	bufs := level(2)
+	nf.RemoveBuffers(bufs, noiseBins)
+
+	if v := bufs[0][0]; math.Abs(v-0.75) > 1e-6 {
+		t.Errorf("got %v, want 0.75 above a floor of 1.25", v)
+	}
+}
+
+func TestNoiseFloorCalibrateCountsBlocks(t *testing.T) {
+	nf := newTestNoiseFloor(NoiseGate, 4)
+	nf.Calibrate()
+
+	for n := 0; n < 3; n++ {
+		nf.ProcessBuffers(level(1), noiseBins)
+	}
+
+	for n := 0; n < 10; n++ {
+		bufs := level(5)
+		nf.RemoveBuffers(bufs, noiseBins)
+
+		if bufs[0][0] != 0 {
+			t.Fatalf("repeat %d ended the calibration", n)
+		}
+	}
+
+	bufs := level(1)
+	nf.ProcessBuffers(bufs, noiseBins)
+
+	if bufs[0][0] != 0 {
+		t.Fatal("calibration ended early")
+	}
+
+	bufs = level(10)
+	nf.ProcessBuffers(bufs, noiseBins)
+
+	if bufs[0][0] != 10 {
+		t.Errorf("got %v after the calibration, want 10", bufs[0][0])
+	}
+}
+
+func TestNoiseFloorGate(t *testing.T) {
+	nf := newTestNoiseFloor(NoiseGate, 0)
+
+	for n := 0; n < 200; n++ {
+		nf.ProcessBuffers(level(1), noiseBins)
+	}
+
+	bufs := level(1.1)
+	nf.RemoveBuffers(bufs, noiseBins)
+
+	if bufs[0][0] != 0 {
+		t.Errorf("got %v under the floor, want 0", bufs[0][0])
+	}
+
+	bufs = level(1.5)
+	nf.RemoveBuffers(bufs, noiseBins)
+
+	if bufs[0][0] != 1.5 {
+		t.Errorf("got %v over the floor, want 1.5", bufs[0][0])
+	}
+}
+
+func TestNoiseFloorReset(t *testing.T) {
+	nf := newTestNoiseFloor(NoiseSubtract, 0)
+
+	for n := 0; n < 200; n++ {
+		nf.ProcessBuffers(level(1), noiseBins)
+	}
+
+	nf.Reset()
+
+	// the floor of the old bins is gone, the first new block is its own.
+	bufs := level(0.5)
+	nf.ProcessBuffers(bufs, noiseBins)
+
+	if v := bufs[0][0]; math.Abs(v-(0.5-0.15*1.25)) > 1e-6 {
+		t.Errorf("got %v after a reset, want 0.3125 above the new floor", v)
+	}
+}
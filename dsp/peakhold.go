@@ -0,0 +1,69 @@
+package dsp
+
+import "math"
+
+// PeakHold holds the highest value of each bin for a number of frames before
+// letting it fall.
+type PeakHold struct {
+	hold  int     // frames to hold a peak
+	fall  float64 // fraction of the peak lost per frame after the hold
+	peaks [][]float64
+	ages  [][]int
+}
+
+// NewPeakHold creates a PeakHold for channelCount buffers of size bins. hold is
+// the number of frames a peak stays, fall [0, 1] the fraction of it lost on
+// every frame after.
+func NewPeakHold(channelCount, size, hold int, fall float64) *PeakHold {
+	ph := &PeakHold{
+		hold:  intMax(hold, 0),
+		fall:  math.Max(0.0, math.Min(fall, 1.0)),
+		peaks: make([][]float64, channelCount),
+		ages:  make([][]int, channelCount),
+	}
+
+	for ch := range ph.peaks {
+		ph.peaks[ch] = make([]float64, size)
+		ph.ages[ch] = make([]int, size)
+	}
+
+	return ph
+}
+
+// Process replaces the first bins values of each buffer with their held peak.
+func (ph *PeakHold) Process(bufs [][]float64, bins int) {
+	for ch, buf := range bufs[:len(ph.peaks)] {
+		peaks, ages := ph.peaks[ch], ph.ages[ch]
+
+		for idx, v := range buf[:bins] {
+			if v >= peaks[idx] {
+				peaks[idx] = v
+				ages[idx] = 0
+				continue
+			}
+
+			if ages[idx] < ph.hold {
+				ages[idx]++
+			} else if peaks[idx] *= 1.0 - ph.fall; peaks[idx] < v {
+				peaks[idx] = v
+			}
+
+			buf[idx] = peaks[idx]
+		}
+	}
+}
+
+// SetBands drops the held peaks, as they are of other bins.
+func (ph *PeakHold) SetBands([]Band) {
+	ph.Reset()
+}
+
+// Reset drops the held peaks.
+func (ph *PeakHold) Reset() {
+	for ch := range ph.peaks {
+		for idx := range ph.peaks[ch] {
+			ph.peaks[ch][idx] = 0.0
+			ph.ages[ch][idx] = 0
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Package scale provides auto-scaling of analyzer output for display.
+package scale
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Scale Constants
+const (
+	// ScalingWindow in seconds
+	ScalingWindow = 1.5
+	// PeakThreshold is the threshold to not draw if the peak is less.
+	PeakThreshold = 0.001
+	// DefaultPercentile is the percentile used by MethodPercentile.
+	DefaultPercentile = 0.95
+)
+
+// Method is a scaling strategy.
+type Method int
+
+const (
+	MethodStat       Method = iota // mean + 2 standard deviations of recent peaks
+	MethodPeak                     // highest recent peak, decaying over the window
+	MethodPercentile               // a percentile of recent peaks
+	MethodFixed                    // fixed gain
+)
+
+// MethodNames are the names of the methods, in order.
+var MethodNames = []string{"stat", "peak", "percentile", "fixed"}
+
+type Config struct {
+	SampleRate float64 // audio sample rate
+	SampleSize int     // number of samples per slice
+	Method     Method  // scaling strategy
+	PerChannel bool    // scale each channel on its own instead of all together
+	Window     float64 // seconds of history for stat, peak and percentile (0 = ScalingWindow)
+	Percentile float64 // percentile [0, 1] for MethodPercentile (0 = DefaultPercentile)
+	Gain       float64 // gain for MethodFixed (0 = 1)
+}
+
+// ParseConfig parses spec, "name[:param]", into a Config. param is the window
+// in seconds for stat and peak, the percentile for percentile and the gain for
+// fixed.
+func ParseConfig(spec string) (Config, error) {
+	var cfg Config
+
+	name, paramStr, hasParam := strings.Cut(strings.ToLower(spec), ":")
+
+	found := false
+	for idx, n := range MethodNames {
+		if n == name {
+			cfg.Method = Method(idx)
+			found = true
+		}
+	}
+
+	if !found {
+		return cfg, fmt.Errorf("unknown scale method %q (%s)", name, strings.Join(MethodNames, ", "))
+	}
+
+	if !hasParam {
+		return cfg, nil
+	}
+
+	param, err := strconv.ParseFloat(paramStr, 64)
+	if err != nil || param <= 0.0 {
+		return cfg, fmt.Errorf("invalid parameter for scale method %q", name)
+	}
+
+	switch cfg.Method {
+	case MethodPercentile:
+		if param > 1.0 {
+			// allow 95 as well as 0.95.
+			param /= 100.0
+		}
+		cfg.Percentile = param
+	case MethodFixed:
+		cfg.Gain = param
+	default:
+		cfg.Window = param
+	}
+
+	return cfg, nil
+}
+
+// tracker follows the peaks of one channel, or of all channels when linked.
+type tracker interface {
+	// update adds a peak and returns the value to divide by.
+	update(peak float64) float64
+	reset()
+}
+
+// Scaler works out how much to scale bins by so they fit the output.
+// It is safe for concurrent use.
+type Scaler struct {
+	mu sync.Mutex
+
+	cfg      Config
+	size     int // number of updates in the window
+	trackers []tracker
+	scales   []float64
+}
+
+// New creates a Scaler.
+func New(cfg Config) *Scaler {
+	if cfg.Window <= 0.0 {
+		cfg.Window = ScalingWindow
+	}
+
+	if cfg.Percentile <= 0.0 || cfg.Percentile > 1.0 {
+		cfg.Percentile = DefaultPercentile
+	}
+
+	if cfg.Gain <= 0.0 {
+		cfg.Gain = 1.0
+	}
+
+	return &Scaler{
+		cfg:  cfg,
+		size: windowSize(cfg),
+	}
+}
+
+// windowSize returns the number of updates in the window of cfg.
+func windowSize(cfg Config) int {
+	size := ((int(cfg.Window * cfg.SampleRate)) / cfg.SampleSize) * 2
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// SetStream changes the sample rate and size the window is measured in, and
+// drops the history of all channels.
+func (s *Scaler) SetStream(sampleRate float64, sampleSize int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg.SampleRate = sampleRate
+	s.cfg.SampleSize = sampleSize
+	s.size = windowSize(s.cfg)
+	s.trackers = nil
+}
+
+func (s *Scaler) newTracker() tracker {
+	switch s.cfg.Method {
+	case MethodPeak:
+		return newPeakTracker(s.size)
+	case MethodPercentile:
+		return newPercentileTracker(s.size, s.cfg.Percentile)
+	case MethodFixed:
+		return fixedTracker(1.0 / s.cfg.Gain)
+	default:
+		return newStatTracker(s.size)
+	}
+}
+
+// Update feeds the peak of the first bins values of each buffer and returns
+// the value to divide each channel by. The returned slice is reused.
+func (s *Scaler) Update(bufs [][]float64, bins int) []float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 1
+	if s.cfg.PerChannel {
+		count = len(bufs)
+	}
+
+	for len(s.trackers) < count {
+		s.trackers = append(s.trackers, s.newTracker())
+	}
+
+	if cap(s.scales) < len(bufs) {
+		s.scales = make([]float64, len(bufs))
+	}
+	s.scales = s.scales[:len(bufs)]
+
+	if !s.cfg.PerChannel {
+		peak := 0.0
+		for _, buf := range bufs {
+			peak = math.Max(peak, bufPeak(buf[:bins]))
+		}
+
+		v := s.trackers[0].update(peak)
+		for ch := range s.scales {
+			s.scales[ch] = v
+		}
+
+		return s.scales
+	}
+
+	for ch, buf := range bufs {
+		s.scales[ch] = s.trackers[ch].update(bufPeak(buf[:bins]))
+	}
+
+	return s.scales
+}
+
+// Scale updates the scaler with src and writes the scaled values of the first
+// bins values of each buffer to dst, growing it as needed. Values after bins
+// are left as they were. It returns dst.
+func (s *Scaler) Scale(dst, src [][]float64, bins int) [][]float64 {
+	scales := s.Update(src, bins)
+
+	for len(dst) < len(src) {
+		dst = append(dst, nil)
+	}
+	dst = dst[:len(src)]
+
+	for ch, buf := range src {
+		// keep the length of src, outputs may look at it.
+		if cap(dst[ch]) < len(buf) {
+			dst[ch] = make([]float64, len(buf))
+		}
+		dst[ch] = dst[ch][:len(buf)]
+
+		for idx, v := range buf[:bins] {
+			dst[ch][idx] = v / scales[ch]
+		}
+	}
+
+	return dst
+}
+
+// Reset drops the history of all channels.
+func (s *Scaler) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.trackers {
+		t.reset()
+	}
+}
+
+func bufPeak(buf []float64) float64 {
+	peak := 0.0
+	for _, v := range buf {
+		if v > peak {
+			peak = v
+		}
+	}
+	return peak
+}
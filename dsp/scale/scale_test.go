@@ -0,0 +1,100 @@
+package scale
+
+import (
+	"math"
+	"testing"
+)
+
+// testConfig has a window of 6 updates.
+func testConfig(method Method) Config {
+	return Config{
+		SampleRate: 100,
+		SampleSize: 50,
+		Method:     method,
+	}
+}
+
+// update feeds each peak to s as one channel of one bin, and returns the last
+// scale.
+func update(s *Scaler, peaks ...float64) float64 {
+	v := 0.0
+	for _, peak := range peaks {
+		v = s.Update([][]float64{{peak}}, 1)[0]
+	}
+	return v
+}
+
+func TestMethods(t *testing.T) {
+	tests := []struct {
+		spec  string
+		peaks []float64
+		want  float64
+	}{
+		{"stat", []float64{10, 10, 10}, 10},
+		{"stat", []float64{0.5, 0.5}, 1},
+		{"stat", []float64{8, 12}, 14},                                      // 10 + 2*2
+		{"stat", []float64{100, 10, 10, 10, 10, 10, 10, 0, 0, 0, 0, 0}, 10}, // recalculated when quiet
+		{"peak", []float64{2, 8}, 8},
+		{"peak", []float64{8, 0, 0, 0, 0, 0, 0}, 4},
+		{"peak", []float64{0.5}, 1},
+		{"percentile", []float64{10, 2, 8, 4, 6, 100}, 100},
+		{"percentile", []float64{100, 2, 2, 2, 2, 2, 2}, 2},
+		{"percentile", []float64{0, 0}, 1},
+		{"percentile:50", []float64{10, 2, 8, 4, 6}, 6},
+		{"fixed", []float64{100, 0}, 1},
+		{"fixed:2", []float64{100}, 0.5},
+	}
+
+	for _, test := range tests {
+		cfg, err := ParseConfig(test.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		base := testConfig(cfg.Method)
+		cfg.SampleRate, cfg.SampleSize = base.SampleRate, base.SampleSize
+
+		got := update(New(cfg), test.peaks...)
+
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s %v: scale %v, want %v", test.spec, test.peaks, got, test.want)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	for idx, name := range MethodNames {
+		s := New(testConfig(Method(idx)))
+		update(s, 10, 10, 10)
+
+		s.Reset()
+
+		if got := update(s, 0.5); got != 1 {
+			t.Errorf("%s: scale %v after reset, want 1", name, got)
+		}
+	}
+}
+
+func TestPerChannel(t *testing.T) {
+	bufs := [][]float64{{10, 5}, {2, 1}}
+
+	for _, test := range []struct {
+		perChannel bool
+		want       []float64
+	}{
+		{false, []float64{10, 10}},
+		{true, []float64{10, 2}},
+	} {
+		cfg := testConfig(MethodPeak)
+		cfg.PerChannel = test.perChannel
+
+		dst := New(cfg).Scale(nil, bufs, 2)
+
+		for ch, want := range test.want {
+			if got := dst[ch][0]; got != bufs[ch][0]/want {
+				t.Errorf("per channel %v: channel %d scaled to %v, want %v",
+					test.perChannel, ch, got, bufs[ch][0]/want)
+			}
+		}
+	}
+}
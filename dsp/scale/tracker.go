@@ -0,0 +1,118 @@
+package scale
+
+import (
+	"math"
+	"sort"
+
+	"github.com/noriah/catnip/util"
+)
+
+// statTracker scales to the mean plus two standard deviations of the recent
+// peaks above PeakThreshold.
+type statTracker struct {
+	window    *util.MovingWindow
+	trackZero int
+}
+
+func newStatTracker(size int) *statTracker {
+	return &statTracker{window: util.NewMovingWindow(size)}
+}
+
+func (t *statTracker) update(peak float64) float64 {
+	if peak >= PeakThreshold {
+		t.trackZero = 0
+
+		// do some scaling if we are above the PeakThreshold
+		t.window.Update(peak)
+
+	} else {
+		if t.trackZero++; t.trackZero == 5 {
+			t.window.Recalculate()
+		}
+	}
+
+	vMean, vSD := t.window.Stats()
+
+	if v := vMean + (2.0 * vSD); v > 1.0 {
+		return v
+	}
+
+	return 1.0
+}
+
+func (t *statTracker) reset() {
+	t.window.Drop(t.window.Cap())
+}
+
+// peakTracker scales to the highest peak, which halves over the window once
+// the signal falls.
+type peakTracker struct {
+	decay float64
+	peak  float64
+}
+
+func newPeakTracker(size int) *peakTracker {
+	return &peakTracker{decay: math.Pow(0.5, 1.0/float64(size))}
+}
+
+func (t *peakTracker) update(peak float64) float64 {
+	t.peak = math.Max(peak, t.peak*t.decay)
+	return math.Max(t.peak, 1.0)
+}
+
+func (t *peakTracker) reset() {
+	t.peak = 0.0
+}
+
+// percentileTracker scales to a percentile of the recent peaks above
+// PeakThreshold, so a few loud transients don't shrink everything.
+type percentileTracker struct {
+	percentile float64
+	peaks      []float64 // ring of recent peaks
+	index      int
+	length     int
+	sorted     []float64
+}
+
+func newPercentileTracker(size int, percentile float64) *percentileTracker {
+	return &percentileTracker{
+		percentile: percentile,
+		peaks:      make([]float64, size),
+		sorted:     make([]float64, 0, size),
+	}
+}
+
+func (t *percentileTracker) update(peak float64) float64 {
+	if peak >= PeakThreshold {
+		t.peaks[t.index] = peak
+		t.index = (t.index + 1) % len(t.peaks)
+		if t.length < len(t.peaks) {
+			t.length++
+		}
+	}
+
+	if t.length == 0 {
+		return 1.0
+	}
+
+	t.sorted = append(t.sorted[:0], t.peaks[:t.length]...)
+	sort.Float64s(t.sorted)
+
+	idx := int(math.Round(t.percentile * float64(t.length-1)))
+
+	return math.Max(t.sorted[idx], 1.0)
+}
+
+func (t *percentileTracker) reset() {
+	t.index = 0
+	t.length = 0
+}
+
+// fixedTracker always scales by the same value.
+type fixedTracker float64
+
+func (t fixedTracker) update(float64) float64 {
+	return float64(t)
+}
+
+func (fixedTracker) reset() {}
@@ -0,0 +1,66 @@
+package dsp
+
+import "math"
+
+// TriggerHysteresis is the share of the peak a signal has to fall below
+// before a rising zero crossing counts as a trigger.
+const TriggerHysteresis = 0.1
+
+// FindTrigger returns the index of the first rising zero crossing in
+// buf[:limit]. The signal has to have been below -TriggerHysteresis times the
+// peak of buf first, so noise around zero does not trigger. Returns -1 if there
+// is no trigger.
+func FindTrigger(buf []float64, limit int) int {
+	if limit > len(buf) {
+		limit = len(buf)
+	}
+
+	peak := 0.0
+	for _, v := range buf {
+		peak = math.Max(peak, math.Abs(v))
+	}
+
+	if peak == 0.0 {
+		return -1
+	}
+
+	low := -peak * TriggerHysteresis
+	armed := false
+
+	for idx, v := range buf[:limit] {
+		switch {
+		case v < low:
+			armed = true
+		case armed && v >= 0.0:
+			return idx
+		}
+	}
+
+	return -1
+}
+
+// Decimate fills dst with src reduced to len(dst) points. Each point keeps the
+// sample with the largest magnitude in its span so that peaks are not lost.
+// If src is not longer than dst, it is copied.
+func Decimate(dst, src []float64) {
+	if len(src) <= len(dst) {
+		copy(dst, src)
+		return
+	}
+
+	step := float64(len(src)) / float64(len(dst))
+
+	for idx := range dst {
+		start := int(float64(idx) * step)
+		stop := int(float64(idx+1) * step)
+
+		v := src[start]
+		for _, s := range src[start+1 : stop] {
+			if math.Abs(s) > math.Abs(v) {
+				v = s
+			}
+		}
+
+		dst[idx] = v
+	}
+}
@@ -0,0 +1,68 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFindTrigger(t *testing.T) {
+	period := make([]float64, 12)
+	for idx := range period {
+		period[idx] = math.Sin(2 * math.Pi * (float64(idx) + 0.5) / 8)
+	}
+
+	tests := []struct {
+		name  string
+		buf   []float64
+		limit int
+		want  int
+	}{
+		// the sine starts rising, it only triggers after it has been below zero.
+		{"sine", period, len(period), 8},
+		{"noise", []float64{1, 0.05, -0.05, 0.05, -0.05, 0.05, -0.5, 0.2}, 8, 7},
+		{"no crossing", []float64{0.1, 0.5, 1, 0.5}, 4, -1},
+		{"falling only", []float64{1, 0, -1, -0.5}, 4, -1},
+		{"silence", make([]float64, 8), 8, -1},
+		{"at the end", []float64{1, 0.5, 0, -0.5, -1, 0.5}, 6, 5},
+		{"past the limit", []float64{1, 0.5, 0, -0.5, -1, 0.5}, 5, -1},
+		{"limit past the end", []float64{1, 0.5, 0, -0.5, -1, 0.5}, 100, 5},
+	}
+
+	for _, test := range tests {
+		if got := FindTrigger(test.buf, test.limit); got != test.want {
+			t.Errorf("%s: trigger at %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDecimate(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    []float64
+		points int
+		want   []float64
+	}{
+		{"peaks", []float64{0.1, 0.9, 0.2, -0.3, -0.8, 0.4, 0, 0.5}, 4, []float64{0.9, -0.3, -0.8, 0.5}},
+		{"uneven", []float64{1, 2, 3, 4, 5, 6, 7}, 3, []float64{2, 4, 7}},
+		{"same length", []float64{1, -2, 3}, 3, []float64{1, -2, 3}},
+		// the points past src are left as they were.
+		{"more points", []float64{1, -2}, 4, []float64{1, -2, 9, 9}},
+		{"no points", []float64{1, 2, 3}, 0, []float64{}},
+	}
+
+	for _, test := range tests {
+		dst := make([]float64, test.points)
+		for idx := range dst {
+			dst[idx] = 9
+		}
+
+		Decimate(dst, test.src)
+
+		for idx, want := range test.want {
+			if dst[idx] != want {
+				t.Errorf("%s: got %v, want %v", test.name, dst, test.want)
+				break
+			}
+		}
+	}
+}
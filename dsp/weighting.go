@@ -0,0 +1,116 @@
+package dsp
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// WeightingCurve is a frequency weighting curve of IEC 61672.
+type WeightingCurve int
+
+const (
+	WeightingA WeightingCurve = iota // A-weighting, the ear at low levels
+	WeightingC                       // C-weighting, the ear at high levels
+)
+
+var weightingNames = [...]string{
+	WeightingA: "a",
+	WeightingC: "c",
+}
+
+// WeightingNames returns the names of all weighting curves.
+func WeightingNames() []string {
+	return weightingNames[:]
+}
+
+// ParseWeighting returns the weighting curve with the given name.
+func ParseWeighting(name string) (WeightingCurve, error) {
+	for c, n := range weightingNames {
+		if strings.EqualFold(n, name) {
+			return WeightingCurve(c), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown weighting %q (%s)", name, strings.Join(weightingNames[:], ", "))
+}
+
+// Gain returns the amplitude gain of the curve at freq Hz, 1 at 1kHz.
+func (c WeightingCurve) Gain(freq float64) float64 {
+	f2 := freq * freq
+
+	// squared pole frequencies
+	const (
+		p1 = 20.598997 * 20.598997
+		p2 = 107.65265 * 107.65265
+		p3 = 737.86223 * 737.86223
+		p4 = 12194.217 * 12194.217
+	)
+
+	switch c {
+	case WeightingC:
+		// +0.06dB normalizes to 1kHz
+		return 1.0072 * p4 * f2 / ((f2 + p1) * (f2 + p4))
+
+	default:
+		// +2.00dB normalizes to 1kHz
+		return 1.2589 * p4 * f2 * f2 /
+			((f2 + p1) * math.Sqrt((f2+p2)*(f2+p3)) * (f2 + p4))
+	}
+}
+
+// Weighting weights each bin by a curve at its center frequency. The bins are
+// taken to be the log of the magnitude, as the analyzer gives them, so the log
+// of the gain is added. If DontNormalize is set they are linear magnitudes and
+// are multiplied by the gain.
+type Weighting struct {
+	DontNormalize bool
+
+	curve WeightingCurve
+	gains []float64
+	logs  []float64 // log of each gain
+}
+
+// NewWeighting creates a Weighting with curve. It has no effect until the
+// bands are set.
+func NewWeighting(curve WeightingCurve) *Weighting {
+	return &Weighting{curve: curve}
+}
+
+// SetBands calculates the gain of each bin.
+func (w *Weighting) SetBands(bands []Band) {
+	if cap(w.gains) < len(bands) {
+		w.gains = make([]float64, len(bands))
+		w.logs = make([]float64, len(bands))
+	}
+	w.gains = w.gains[:len(bands)]
+	w.logs = w.logs[:len(bands)]
+
+	for idx, band := range bands {
+		w.gains[idx] = w.curve.Gain(band.Center)
+		w.logs[idx] = math.Log(w.gains[idx])
+	}
+}
+
+// Process weights the first bins values of each buffer.
+func (w *Weighting) Process(bufs [][]float64, bins int) {
+	if bins > len(w.gains) {
+		bins = len(w.gains)
+	}
+
+	if w.DontNormalize {
+		for _, buf := range bufs {
+			for idx, gain := range w.gains[:bins] {
+				buf[idx] *= gain
+			}
+		}
+		return
+	}
+
+	for _, buf := range bufs {
+		for idx, gain := range w.logs[:bins] {
+			// the analyzer gives 0 for everything under a magnitude of 1.
+			buf[idx] = math.Max(buf[idx]+gain, 0.0)
+		}
+	}
+}
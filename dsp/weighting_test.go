@@ -0,0 +1,66 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+// weigh runs bins of value v at 1kHz and 100Hz through a Weighting.
+func weigh(w *Weighting, v float64) (float64, float64) {
+	w.SetBands([]Band{{Center: 1000}, {Center: 100}})
+
+	bufs := [][]float64{{v, v}}
+	w.Process(bufs, 2)
+
+	return bufs[0][0], bufs[0][1]
+}
+
+func TestWeightingLog(t *testing.T) {
+	// a magnitude of 1e6, as the analyzer gives it.
+	v := math.Log(1e6)
+
+	at1k, at100 := weigh(NewWeighting(WeightingA), v)
+
+	if math.Abs(at1k-v) > 1e-3 {
+		t.Errorf("1kHz changed from %v to %v", v, at1k)
+	}
+
+	// back to decibels of magnitude.
+	if db := (at100 - v) * 20 / math.Ln10; math.Abs(db+19.1) > 0.1 {
+		t.Errorf("100Hz changed by %.2f dB, want -19.1 dB", db)
+	}
+
+	// quiet bins stay at the floor of the analyzer.
+	if _, at100 := weigh(NewWeighting(WeightingA), 1); at100 != 0 {
+		t.Errorf("quiet 100Hz bin went to %v, want 0", at100)
+	}
+}
+
+func TestWeightingLinear(t *testing.T) {
+	w := NewWeighting(WeightingA)
+	w.DontNormalize = true
+
+	at1k, at100 := weigh(w, 2)
+
+	if math.Abs(at1k-2) > 1e-3 {
+		t.Errorf("1kHz changed from 2 to %v", at1k)
+	}
+
+	if db := 20 * math.Log10(at100/2); math.Abs(db+19.1) > 0.1 {
+		t.Errorf("100Hz changed by %.2f dB, want -19.1 dB", db)
+	}
+}
+
+func TestWeightingC(t *testing.T) {
+	for _, tc := range []struct {
+		freq, db float64
+	}{
+		{31.5, -3.0},
+		{1000, 0},
+		{8000, -3.0},
+	} {
+		if db := 20 * math.Log10(WeightingC.Gain(tc.freq)); math.Abs(db-tc.db) > 0.1 {
+			t.Errorf("%vHz: %.2f dB, want %.1f dB", tc.freq, db, tc.db)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package window
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// entry is a named window with its default parameter.
+type entry struct {
+	name  string
+	param float64
+	make  func(param float64) Coefficients
+	check func(param float64) error // nil if the window takes no parameter
+}
+
+func noParam(fn func() Coefficients) func(float64) Coefficients {
+	return func(float64) Coefficients { return fn() }
+}
+
+// catalogue holds all windows that can be selected by name.
+var catalogue = []entry{
+	{"rectangle", 0, noParam(RectangleCoefficients), nil},
+	{"hann", 0, noParam(HannCoefficients), nil},
+	{"hamming", 0, noParam(HammingCoefficients), nil},
+	{"bartlett", 0, noParam(BartlettCoefficients), nil},
+	{"blackman", 0, noParam(BlackmanCoefficients), nil},
+	{"lanczos", 0, noParam(LanczosCoefficients), nil},
+	{"blackman-harris", 0, noParam(BlackmanHarrisCoefficients), nil},
+	{"nuttall", 0, noParam(NuttallCoefficients), nil},
+	{"flat-top", 0, noParam(FlatTopCoefficients), nil},
+	{"kaiser", 8.6, KaiserCoefficients, atLeast(0)},
+	{"tukey", 0.5, TukeyCoefficients, between(0, 1)},
+	{"gaussian", 0.4, GaussianCoefficients, above(0)},
+	{"dolph-chebyshev", 80.0, DolphChebyshevCoefficients, above(0)},
+	{"planck-taper", 0.1, PlanckTaperCoefficients, between(0, 0.5)},
+}
+
+func atLeast(lo float64) func(float64) error {
+	return func(p float64) error {
+		if !(p >= lo) {
+			return fmt.Errorf("%g is under %g", p, lo)
+		}
+		return nil
+	}
+}
+
+func above(lo float64) func(float64) error {
+	return func(p float64) error {
+		if !(p > lo) {
+			return fmt.Errorf("%g is not above %g", p, lo)
+		}
+		return nil
+	}
+}
+
+func between(lo, hi float64) func(float64) error {
+	return func(p float64) error {
+		if !(p >= lo && p <= hi) {
+			return fmt.Errorf("%g is not between %g and %g", p, lo, hi)
+		}
+		return nil
+	}
+}
+
+// Names returns the names of all windows in the catalogue.
+func Names() []string {
+	names := make([]string, len(catalogue))
+	for idx, e := range catalogue {
+		names[idx] = e.name
+	}
+	return names
+}
+
+// parseSpec splits "name[:param]" and finds the entry.
+func parseSpec(spec string) (int, float64, error) {
+	name, paramStr, hasParam := strings.Cut(strings.ToLower(spec), ":")
+
+	for idx, e := range catalogue {
+		if e.name != name {
+			continue
+		}
+
+		param := e.param
+		if hasParam {
+			if e.check == nil {
+				return 0, 0, fmt.Errorf("window %q takes no parameter", name)
+			}
+
+			p, err := strconv.ParseFloat(paramStr, 64)
+			if err == nil && math.IsInf(p, 0) {
+				err = fmt.Errorf("%g is not finite", p)
+			}
+			if err == nil {
+				err = e.check(p)
+			}
+			if err != nil {
+				return 0, 0, fmt.Errorf("invalid parameter for window %q: %w", name, err)
+			}
+			param = p
+		}
+
+		return idx, param, nil
+	}
+
+	return 0, 0, fmt.Errorf("unknown window %q (%s)", name, strings.Join(Names(), ", "))
+}
+
+// Parse returns the window function for spec, which is a name from Names optionally
+// followed by ":param" for windows that take one (e.g. "kaiser:6").
+func Parse(spec string) (Coefficients, error) {
+	idx, param, err := parseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return catalogue[idx].make(param), nil
+}
+
+// Selector is a Windower that can be switched between all windows in the
+// catalogue at runtime. It is safe to switch from another goroutine.
+type Selector struct {
+	index  int32
+	caches []*Cache
+}
+
+// NewSelector creates a Selector starting at the window for spec (see Parse).
+// The parameter in spec is kept for that window.
+func NewSelector(spec string) (*Selector, error) {
+	start, param, err := parseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	sel := &Selector{
+		index:  int32(start),
+		caches: make([]*Cache, len(catalogue)),
+	}
+
+	for idx, e := range catalogue {
+		if idx == start {
+			sel.caches[idx] = New(e.make(param))
+		} else {
+			sel.caches[idx] = New(e.make(e.param))
+		}
+	}
+
+	return sel, nil
+}
+
+// Apply multiplies buf by the current window.
+func (s *Selector) Apply(buf []float64) {
+	s.caches[atomic.LoadInt32(&s.index)].Apply(buf)
+}
+
+// Table returns the table of the current window for size points.
+func (s *Selector) Table(size int) *Table {
+	return s.caches[atomic.LoadInt32(&s.index)].Table(size)
+}
+
+// Name returns the name of the current window.
+func (s *Selector) Name() string {
+	return catalogue[atomic.LoadInt32(&s.index)].name
+}
+
+// Next switches to the next window in the catalogue.
+func (s *Selector) Next() {
+	s.move(1)
+}
+
+// Prev switches to the previous window in the catalogue.
+func (s *Selector) Prev() {
+	s.move(-1)
+}
+
+func (s *Selector) move(delta int32) {
+	count := int32(len(s.caches))
+	idx := (atomic.LoadInt32(&s.index) + delta + count) % count
+	atomic.StoreInt32(&s.index, idx)
+}
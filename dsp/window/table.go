@@ -0,0 +1,83 @@
+package window
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// Windower applies a window to a buffer of samples.
+type Windower interface {
+	Apply(buf []float64)
+}
+
+// Table is a window materialized for one buffer length.
+type Table struct {
+	Coefficients []float64 // window coefficients
+	Amplitude    float64   // amplitude correction, N / sum(w)
+	Energy       float64   // energy correction, sqrt(N / sum(w^2))
+}
+
+// NewTable computes the coefficients of fn for size points.
+func NewTable(fn Coefficients, size int) *Table {
+	t := &Table{
+		Coefficients: make([]float64, size),
+		Amplitude:    1.0,
+		Energy:       1.0,
+	}
+
+	fn(t.Coefficients)
+
+	var sum, sumSq float64
+	for _, w := range t.Coefficients {
+		sum += w
+		sumSq += w * w
+	}
+
+	if sum != 0.0 {
+		t.Amplitude = float64(size) / sum
+	}
+
+	if sumSq != 0.0 {
+		t.Energy = math.Sqrt(float64(size) / sumSq)
+	}
+
+	return t
+}
+
+// Apply multiplies buf by the window. buf must not be longer than the table.
+func (t *Table) Apply(buf []float64) {
+	coef := t.Coefficients[:len(buf)]
+	for n := range buf {
+		buf[n] *= coef[n]
+	}
+}
+
+// Cache is a Windower that builds a Table for fn the first time it sees a
+// buffer length and reuses it after. It is safe for concurrent use.
+type Cache struct {
+	fn    Coefficients
+	table atomic.Value // *Table
+}
+
+// New creates a Cache for fn.
+func New(fn Coefficients) *Cache {
+	return &Cache{fn: fn}
+}
+
+// Table returns the table for size points.
+func (c *Cache) Table(size int) *Table {
+	if t, ok := c.table.Load().(*Table); ok && len(t.Coefficients) == size {
+		return t
+	}
+
+	// concurrent callers may both build the table, but they build the same one.
+	t := NewTable(c.fn, size)
+	c.table.Store(t)
+
+	return t
+}
+
+// Apply multiplies buf by the window.
+func (c *Cache) Apply(buf []float64) {
+	c.Table(len(buf)).Apply(buf)
+}
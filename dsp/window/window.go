@@ -3,26 +3,61 @@
 // See https://wikipedia.org/wiki/Window_function
 package window
 
-import "math"
+import (
+	"math"
+	"math/cmplx"
+)
 
-// Function is a function that will do window things for you on a slice
+// Function is a function that will do window things for you on a slice, it
+// multiplies buf by a window in place. The Functions of this package compute
+// their window once per buffer length.
 type Function func(buf []float64)
 
-// Rectangle is just do nothing
+// Apply calls f on buf, so a Function is a Windower.
+func (f Function) Apply(buf []float64) {
+	f(buf)
+}
+
+// Coefficients is a function that writes the coefficients of a window with
+// len(buf) points into buf.
+//
+// Coefficients are expensive to compute, use a Table or Cache to apply them
+// to samples.
+type Coefficients func(buf []float64)
+
+// Function returns a Function that multiplies by the window of c. The window
+// is computed once per buffer length.
+func (c Coefficients) Function() Function {
+	return New(c).Apply
+}
+
+// Rectangle multiplies buf in place by RectangleCoefficients.
 func Rectangle() Function {
+	return RectangleCoefficients().Function()
+}
+
+// RectangleCoefficients are all 1, the window that changes nothing
+func RectangleCoefficients() Coefficients {
 	return func(buf []float64) {
-		// do nothing
+		for n := range buf {
+			buf[n] = 1.0
+		}
 	}
 }
 
-// CosSum modifies the buffer to conform to a cosine sum window following a0
+// CosSum multiplies buf in place by CosSumCoefficients.
 func CosSum(a0 float64) Function {
+	return CosSumCoefficients(a0).Function()
+}
+
+// CosSumCoefficients is a cosine sum window following a0
+func CosSumCoefficients(a0 float64) Coefficients {
 	return func(buf []float64) {
 		size := len(buf)
 		a1 := 1.0 - a0
 		coef := 2.0 * math.Pi / float64(size)
 		for n := 0; n < size; n++ {
-			buf[n] *= (a0 - (a1 * math.Cos(coef*float64(n))))
+			buf[n] = a0 - (a1 * math.Cos(coef*float64(n)))
 		}
 	}
 }
@@ -30,13 +65,18 @@ func CosSum(a0 float64) Function {
 // sinc(x) = sin(pi * x) / (pi * x)
 func sinc(x float64) float64 {
 	if x == 0.0 {
-		return 0.0
+		return 1.0
 	}
 	piX := math.Pi * x
 	return math.Sin(piX) / piX
 }
 
-// Lanczos modifies the buffer to a Lanczos window
+// Lanczos multiplies buf in place by LanczosCoefficients.
+func Lanczos() Function {
+	return LanczosCoefficients().Function()
+}
+
+// LanczosCoefficients is a Lanczos window
 //
 // w[n] = sinc((2n / N) - 1)
 //
@@ -47,11 +87,11 @@ func sinc(x float64) float64 {
 // buf[n] = sinc(kn - 1)
 //
 // https://www.wikiwand.com/en/Window_function#/Other_windows
-func Lanczos() Function {
+func LanczosCoefficients() Coefficients {
 	return func(buf []float64) {
 		k := 2.0 / float64(len(buf))
 		for n := range buf {
-			buf[n] *= sinc((k * float64(n)) - 1.0)
+			buf[n] = sinc((k * float64(n)) - 1.0)
 		}
 	}
 }
@@ -59,27 +99,47 @@ func Lanczos() Function {
 // HammingConst is the hamming window constant
 const HammingConst = 25.0 / 46.0
 
-// Hamming modifies the buffer to a Hamming window
+// Hamming multiplies buf in place by HammingCoefficients.
 func Hamming() Function {
-	return CosSum(HammingConst)
+	return HammingCoefficients().Function()
+}
+
+// HammingCoefficients is a Hamming window
+func HammingCoefficients() Coefficients {
+	return CosSumCoefficients(HammingConst)
 }
 
-// Hann modifies the buffer to a Hann window
+// Hann multiplies buf in place by HannCoefficients.
 func Hann() Function {
-	return CosSum(0.5)
+	return HannCoefficients().Function()
+}
+
+// HannCoefficients is a Hann window
+func HannCoefficients() Coefficients {
+	return CosSumCoefficients(0.5)
 }
 
-// Bartlett modifies the buffer to a Bartlett window
+// Bartlett multiplies buf in place by BartlettCoefficients.
 func Bartlett() Function {
+	return BartlettCoefficients().Function()
+}
+
+// BartlettCoefficients is a Bartlett window
+func BartlettCoefficients() Coefficients {
 	return func(buf []float64) {
 		N := float64(len(buf))
 		for n := range buf {
-			buf[n] *= (1.0 - (math.Abs(((2.0 * float64(n)) - N) / N)))
+			buf[n] = 1.0 - math.Abs(((2.0*float64(n))-N)/N)
 		}
 	}
 }
 
-// Blackman modifies the buffer to a Blackman window
+// Blackman multiplies buf in place by BlackmanCoefficients.
+func Blackman() Function {
+	return BlackmanCoefficients().Function()
+}
+
+// BlackmanCoefficients is a Blackman window
 //
 // N = size
 // n = element
@@ -88,33 +148,273 @@ func Bartlett() Function {
 // a_1 = 1 / 2
 // a_2 = a / 2
 // w[n] = a_0 - a_1 * cos((2 * pi * n) / N) + a_2 * cos((4 * pi * n) / N)
-func Blackman() Function {
+func BlackmanCoefficients() Coefficients {
 	return func(buf []float64) {
 		N := float64(len(buf))
 		twoPi := 2.0 * math.Pi
 
 		for n := range buf {
 			twoPiX := twoPi * (float64(n) / N)
-			buf[n] *= 0.42 - (0.5 * math.Cos(twoPiX)) + (0.08 * math.Cos(2.0*twoPiX))
+			buf[n] = 0.42 - (0.5 * math.Cos(twoPiX)) + (0.08 * math.Cos(2.0*twoPiX))
 		}
 	}
 }
 
-// PlanckTaper modifies the buffer to a Planck-taper window
-//
-// not sure how i got this
+// PlanckTaper multiplies buf in place by PlanckTaperCoefficients.
 func PlanckTaper(e float64) Function {
+	return PlanckTaperCoefficients(e).Function()
+}
+
+// PlanckTaperCoefficients is a Planck-taper window. e is the share of each
+// half of the window inside the taper.
+//
+// w[0] = 0
+// w[n] = 1 / (1 + exp(eN / n - eN / (eN - n)))  for 0 < n < eN
+// w[n] = 1                                        for eN <= n <= N / 2
+// w[N - n] = w[n]
+func PlanckTaperCoefficients(e float64) Coefficients {
 	return func(buf []float64) {
 		size := len(buf)
 		eN := e * float64(size)
 
-		buf[0] *= 0
-		for n := 1; n < int(eN); n++ {
-			buf[n] *= 1.0 / (1.0 + math.Exp((eN/float64(n))-(eN/(eN-float64(n)))))
+		for n := 0; n <= size/2 && n < size; n++ {
+			x := float64(n)
+			switch {
+			case n == 0:
+				buf[n] = 0.0
+			case x < eN:
+				buf[n] = 1.0 / (1.0 + math.Exp((eN/x)-(eN/(eN-x))))
+			default:
+				buf[n] = 1.0
+			}
 		}
 
-		for n := 1; n <= size/2; n++ {
-			buf[size-n] *= buf[n-1]
+		for n := 1; n < (size+1)/2; n++ {
+			buf[size-n] = buf[n]
+		}
+	}
+}
+
+// cosineSum returns a generalized cosine window with the coefficients a,
+// alternating in sign.
+//
+// w[n] = a_0 - a_1 * cos(2 * pi * n / N) + a_2 * cos(4 * pi * n / N) - ...
+func cosineSum(a ...float64) Coefficients {
+	return func(buf []float64) {
+		N := float64(len(buf))
+		twoPi := 2.0 * math.Pi
+
+		for n := range buf {
+			x := twoPi * (float64(n) / N)
+			w, sign := 0.0, 1.0
+			for k, ak := range a {
+				w += sign * ak * math.Cos(float64(k)*x)
+				sign = -sign
+			}
+			buf[n] = w
+		}
+	}
+}
+
+// BlackmanHarris multiplies buf in place by BlackmanHarrisCoefficients.
+func BlackmanHarris() Function {
+	return BlackmanHarrisCoefficients().Function()
+}
+
+// BlackmanHarrisCoefficients is a 4-term Blackman-Harris window
+func BlackmanHarrisCoefficients() Coefficients {
+	return cosineSum(0.35875, 0.48829, 0.14128, 0.01168)
+}
+
+// Nuttall multiplies buf in place by NuttallCoefficients.
+func Nuttall() Function {
+	return NuttallCoefficients().Function()
+}
+
+// NuttallCoefficients is a 4-term Nuttall window
+func NuttallCoefficients() Coefficients {
+	return cosineSum(0.355768, 0.487396, 0.144232, 0.012604)
+}
+
+// FlatTop multiplies buf in place by FlatTopCoefficients.
+func FlatTop() Function {
+	return FlatTopCoefficients().Function()
+}
+
+// FlatTopCoefficients is a flat-top window. It has very little
+// scalloping loss, so peaks read the same no matter where they fall between
+// bins.
+func FlatTopCoefficients() Coefficients {
+	return cosineSum(0.21557895, 0.41663158, 0.277263158, 0.083578947, 0.006947368)
+}
+
+// Tukey multiplies buf in place by TukeyCoefficients.
+func Tukey(alpha float64) Function {
+	return TukeyCoefficients(alpha).Function()
+}
+
+// TukeyCoefficients is a Tukey (tapered cosine) window. alpha is the
+// share of the window inside the cosine tapers. 0 is a rectangle, 1 is Hann.
+func TukeyCoefficients(alpha float64) Coefficients {
+	alpha = math.Max(0.0, math.Min(alpha, 1.0))
+
+	return func(buf []float64) {
+		N := float64(len(buf))
+		edge := alpha * N / 2.0
+
+		for n := range buf {
+			x := float64(n)
+			if x > N/2.0 {
+				x = N - x
+			}
+
+			buf[n] = 1.0
+			if x < edge {
+				buf[n] = 0.5 * (1.0 - math.Cos(math.Pi*x/edge))
+			}
+		}
+	}
+}
+
+// Gaussian multiplies buf in place by GaussianCoefficients.
+func Gaussian(sigma float64) Function {
+	return GaussianCoefficients(sigma).Function()
+}
+
+// GaussianCoefficients is a Gaussian window. sigma is the standard
+// deviation relative to half the window, it should be 0.5 or less.
+func GaussianCoefficients(sigma float64) Coefficients {
+	return func(buf []float64) {
+		half := float64(len(buf)) / 2.0
+
+		for n := range buf {
+			x := (float64(n) - half) / (sigma * half)
+			buf[n] = math.Exp(-0.5 * x * x)
+		}
+	}
+}
+
+// Kaiser multiplies buf in place by KaiserCoefficients.
+func Kaiser(beta float64) Function {
+	return KaiserCoefficients(beta).Function()
+}
+
+// KaiserCoefficients is a Kaiser window. beta trades main lobe width
+// for side lobe level, 8.6 is about the same as Blackman.
+//
+// w[n] = I_0(beta * sqrt(1 - (2n / N - 1)^2)) / I_0(beta)
+func KaiserCoefficients(beta float64) Coefficients {
+	return func(buf []float64) {
+		N := float64(len(buf))
+		den := besselI0(beta)
+
+		for n := range buf {
+			x := (2.0 * float64(n) / N) - 1.0
+			buf[n] = besselI0(beta*math.Sqrt(1.0-(x*x))) / den
+		}
+	}
+}
+
+// besselI0 is the zeroth order modified bessel function of the first kind.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	halfX := x / 2.0
+
+	for k := 1.0; term > sum*1e-12; k++ {
+		term *= (halfX / k) * (halfX / k)
+		sum += term
+	}
+
+	return sum
+}
+
+// DolphChebyshev multiplies buf in place by DolphChebyshevCoefficients.
+func DolphChebyshev(attenuation float64) Function {
+	return DolphChebyshevCoefficients(attenuation).Function()
+}
+
+// DolphChebyshevCoefficients is a Dolph-Chebyshev window with all side
+// lobes attenuation decibels under the main lobe.
+//
+// The window is built from its frequency response with an inverse DFT, the
+// same way as scipy.signal.windows.chebwin.
+func DolphChebyshevCoefficients(attenuation float64) Coefficients {
+	return func(buf []float64) {
+		M := len(buf)
+		if M < 2 {
+			for n := range buf {
+				buf[n] = 1.0
+			}
+			return
+		}
+
+		order := float64(M - 1)
+		r := math.Pow(10.0, math.Abs(attenuation)/20.0)
+		x0 := math.Cosh(math.Acosh(r) / order)
+
+		// frequency response, with a half sample shift for even lengths.
+		p := make([]complex128, M)
+		for k := range p {
+			x := x0 * math.Cos(math.Pi*float64(k)/float64(M))
+
+			var t float64
+			switch {
+			case x > 1.0:
+				t = math.Cosh(order * math.Acosh(x))
+			case x < -1.0:
+				t = math.Cosh(order * math.Acosh(-x))
+				if (M-1)%2 == 1 {
+					t = -t
+				}
+			default:
+				t = math.Cos(order * math.Acos(x))
+			}
+
+			p[k] = complex(t, 0)
+			if M%2 == 0 {
+				p[k] *= cmplx.Exp(complex(0, math.Pi*float64(k)/float64(M)))
+			}
+		}
+
+		w := make([]float64, M)
+		for n := range w {
+			sum := 0.0
+			for k, pk := range p {
+				angle := -2.0 * math.Pi * float64(k*n) / float64(M)
+				sum += real(pk * cmplx.Exp(complex(0, angle)))
+			}
+			w[n] = sum
+		}
+
+		half := (M + 1) / 2
+		if M%2 == 0 {
+			half = M/2 + 1
+		}
+
+		// mirror the first half around the center.
+		idx := 0
+		for n := half - 1; n > 0; n-- {
+			buf[idx] = w[n]
+			idx++
+		}
+
+		start := 0
+		if M%2 == 0 {
+			start = 1
+		}
+
+		for n := start; n < half && idx < M; n++ {
+			buf[idx] = w[n]
+			idx++
+		}
+
+		max := 0.0
+		for _, v := range buf {
+			max = math.Max(max, v)
+		}
+
+		for n := range buf {
+			buf[n] /= max
 		}
 	}
 }
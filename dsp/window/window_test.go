@@ -0,0 +1,266 @@
+package window
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+const benchSize = 1024
+
+// benchCompute computes the window on every frame, the way windows used to
+// be applied.
+func benchCompute(b *testing.B, fn Coefficients) {
+	buf := make([]float64, benchSize)
+	coef := make([]float64, benchSize)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		fn(coef)
+		for n := range buf {
+			buf[n] *= coef[n]
+		}
+	}
+}
+
+// benchTable applies a precomputed table on every frame.
+func benchTable(b *testing.B, fn Coefficients) {
+	buf := make([]float64, benchSize)
+	cache := New(fn)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		cache.Apply(buf)
+	}
+}
+
+func BenchmarkComputeLanczos(b *testing.B)  { benchCompute(b, LanczosCoefficients()) }
+func BenchmarkComputeBlackman(b *testing.B) { benchCompute(b, BlackmanCoefficients()) }
+func BenchmarkComputeHann(b *testing.B)     { benchCompute(b, HannCoefficients()) }
+func BenchmarkComputeKaiser(b *testing.B)   { benchCompute(b, KaiserCoefficients(8.6)) }
+
+func BenchmarkTableLanczos(b *testing.B)  { benchTable(b, LanczosCoefficients()) }
+func BenchmarkTableBlackman(b *testing.B) { benchTable(b, BlackmanCoefficients()) }
+func BenchmarkTableHann(b *testing.B)     { benchTable(b, HannCoefficients()) }
+func BenchmarkTableKaiser(b *testing.B)   { benchTable(b, KaiserCoefficients(8.6)) }
+
+func BenchmarkSelector(b *testing.B) {
+	buf := make([]float64, benchSize)
+	sel, err := NewSelector("lanczos")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		sel.Apply(buf)
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	for _, spec := range []string{"kaiser", "kaiser:0", "tukey:1", "gaussian:0.5", "dolph-chebyshev:60", "planck-taper:0"} {
+		if _, err := Parse(spec); err != nil {
+			t.Errorf("%s: %v", spec, err)
+		}
+	}
+
+	for _, spec := range []string{
+		"gaussian:0", "gaussian:-1", "kaiser:-1", "kaiser:nan", "kaiser:inf",
+		"tukey:1.5", "dolph-chebyshev:0", "planck-taper:0.6", "hann:2", "kaiser:x",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%s: no error", spec)
+		}
+
+		if _, err := NewSelector(spec); err == nil {
+			t.Errorf("%s: no error from NewSelector", spec)
+		}
+	}
+}
+
+// coefficients returns the coefficients of c for size points.
+func coefficients(c Coefficients, size int) []float64 {
+	buf := make([]float64, size)
+	c(buf)
+	return buf
+}
+
+func TestCatalogueShape(t *testing.T) {
+	const size = 64
+
+	for _, e := range catalogue {
+		w := coefficients(e.make(e.param), size)
+
+		peak := 0.0
+		for _, v := range w {
+			peak = math.Max(peak, v)
+		}
+
+		if math.Abs(peak-1.0) > 1e-6 {
+			t.Errorf("%s: peak %v, want 1", e.name, peak)
+		}
+
+		// all windows are periodic, but for Dolph-Chebyshev which is symmetric.
+		for n := 1; n < size; n++ {
+			m := size - n
+			if e.name == "dolph-chebyshev" {
+				m = size - 1 - n
+			}
+
+			if math.Abs(w[n]-w[m]) > 1e-9 {
+				t.Errorf("%s: w[%d] = %v, w[%d] = %v", e.name, n, w[n], m, w[m])
+				break
+			}
+		}
+	}
+}
+
+func TestKnownCoefficients(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		c    Coefficients
+		want []float64
+	}{
+		{"hann", HannCoefficients(), []float64{0, 0.1464466094, 0.5, 0.8535533906, 1, 0.8535533906, 0.5, 0.1464466094}},
+		{"kaiser 8.6", KaiserCoefficients(8.6), []float64{
+			0.001332514, 0.0674720792, 0.3403936224, 0.773829381,
+			1, 0.773829381, 0.3403936224, 0.0674720792,
+		}},
+		{"kaiser 0", KaiserCoefficients(0), []float64{1, 1, 1, 1, 1, 1, 1, 1}},
+		{"gaussian 0.4", GaussianCoefficients(0.4), []float64{
+			0.0439369336, 0.1724216239, 0.4578333618, 0.8225775624,
+			1, 0.8225775624, 0.4578333618, 0.1724216239,
+		}},
+		{"tukey 0", TukeyCoefficients(0), []float64{1, 1, 1, 1, 1, 1, 1, 1}},
+		{"tukey 1", TukeyCoefficients(1), []float64{0, 0.1464466094, 0.5, 0.8535533906, 1, 0.8535533906, 0.5, 0.1464466094}},
+		{"planck-taper 0.25", PlanckTaperCoefficients(0.25), []float64{0, 0.5, 1, 1, 1, 1, 1, 0.5}},
+	} {
+		got := coefficients(tc.c, len(tc.want))
+
+		for n, want := range tc.want {
+			if math.Abs(got[n]-want) > 1e-9 {
+				t.Errorf("%s: w[%d] = %v, want %v", tc.name, n, got[n], want)
+			}
+		}
+	}
+}
+
+// response returns the magnitude in dB of the window w at freq cycles per
+// sample, relative to its dc gain.
+func response(w []float64, freq float64) float64 {
+	var sum, dc complex128
+	for n, v := range w {
+		sum += complex(v, 0) * cmplx.Exp(complex(0, -2*math.Pi*freq*float64(n)))
+		dc += complex(v, 0)
+	}
+
+	return 20 * math.Log10(cmplx.Abs(sum)/cmplx.Abs(dc))
+}
+
+func TestDolphChebyshevSideLobes(t *testing.T) {
+	const size, steps = 31, 4096
+
+	for _, attenuation := range []float64{50, 80, 100} {
+		w := coefficients(DolphChebyshevCoefficients(attenuation), size)
+
+		// walk down the main lobe to its first null, every lobe after is at
+		// the same level.
+		step := 0.5 / steps
+		freq, last := step, 0.0
+		for ; freq < 0.5; freq += step {
+			db := response(w, freq)
+			if db > last {
+				break
+			}
+			last = db
+		}
+
+		highest := math.Inf(-1)
+		for ; freq <= 0.5; freq += step {
+			highest = math.Max(highest, response(w, freq))
+		}
+
+		if math.Abs(highest+attenuation) > 0.1 {
+			t.Errorf("attenuation %v: side lobes at %.2f dB", attenuation, highest)
+		}
+	}
+}
+
+func TestCorrections(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		c      Coefficients
+		amp    float64 // coherent gain
+		energy float64 // power gain
+	}{
+		{"rectangle", RectangleCoefficients(), 1, 1},
+		{"hann", HannCoefficients(), 0.5, 0.375},
+		{"hamming", HammingCoefficients(), HammingConst, HammingConst*HammingConst + (1-HammingConst)*(1-HammingConst)/2},
+		{"blackman", BlackmanCoefficients(), 0.42, 0.42*0.42 + 0.5*0.5/2 + 0.08*0.08/2},
+	} {
+		table := NewTable(tc.c, 1024)
+
+		if want := 1 / tc.amp; math.Abs(table.Amplitude-want) > 1e-9 {
+			t.Errorf("%s: amplitude correction %v, want %v", tc.name, table.Amplitude, want)
+		}
+
+		if want := math.Sqrt(1 / tc.energy); math.Abs(table.Energy-want) > 1e-9 {
+			t.Errorf("%s: energy correction %v, want %v", tc.name, table.Energy, want)
+		}
+	}
+}
+
+func TestFunctionMultiplies(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		fn   Function
+		c    Coefficients
+	}{
+		{"rectangle", Rectangle(), RectangleCoefficients()},
+		{"hann", Hann(), HannCoefficients()},
+		{"lanczos", Lanczos(), LanczosCoefficients()},
+		{"planck-taper", PlanckTaper(0.1), PlanckTaperCoefficients(0.1)},
+		{"kaiser", Kaiser(8.6), KaiserCoefficients(8.6)},
+		{"from coefficients", HannCoefficients().Function(), HannCoefficients()},
+	} {
+		buf := make([]float64, 16)
+		for n := range buf {
+			buf[n] = 2
+		}
+
+		// twice, so the window is not written over the samples either time.
+		want := coefficients(tc.c, len(buf))
+		for run := 0; run < 2; run++ {
+			tc.fn(buf)
+
+			for n := range buf {
+				if math.Abs(buf[n]-2*want[n]) > 1e-12 {
+					t.Fatalf("%s: w[%d] = %v, want %v", tc.name, n, buf[n], 2*want[n])
+				}
+			}
+
+			for n := range buf {
+				buf[n] = 2
+			}
+		}
+	}
+}
+
+func TestLanczosCenter(t *testing.T) {
+	// sinc(0) is 1, the middle of the window keeps the sample. It used to be
+	// 0, which dropped the middle sample of every frame.
+	w := coefficients(LanczosCoefficients(), 8)
+
+	if w[4] != 1 {
+		t.Errorf("center is %v, want 1", w[4])
+	}
+
+	for n := 1; n < 4; n++ {
+		if w[n] <= w[n-1] || w[n] >= w[4] {
+			t.Errorf("w[%d] = %v does not rise from %v to the center", n, w[n], w[n-1])
+		}
+	}
+}
@@ -1,6 +1,95 @@
 // Package fft provides generic abstractions around fourier transformers.
 package fft
 
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// Planner is how hard FFTW looks for a fast plan. It is ignored by the pure Go
+// fft.
+type Planner int
+
+const (
+	PlanEstimate Planner = iota // pick a plan without measuring
+	PlanMeasure                 // measure a few plans (default)
+	PlanPatient                 // measure many plans, slow without wisdom
+)
+
+// PlannerNames are the names of the planners, in order.
+var PlannerNames = []string{"estimate", "measure", "patient"}
+
+// ParsePlanner returns the planner called name.
+func ParsePlanner(name string) (Planner, error) {
+	for idx, n := range PlannerNames {
+		if n == strings.ToLower(name) {
+			return Planner(idx), nil
+		}
+	}
+
+	return PlanMeasure, fmt.Errorf("unknown fft planner %q (%s)", name, strings.Join(PlannerNames, ", "))
+}
+
+var (
+	planner    = PlanMeasure
+	wisdomFile string
+
+	// wisdomNew is set when a plan may have added to the wisdom.
+	wisdomNew atomic.Bool
+)
+
+// SetPlanner sets the planner used by plans created after.
+func SetPlanner(p Planner) {
+	planner = p
+}
+
+// DefaultWisdomFile returns the path FFTW wisdom is kept at by default.
+func DefaultWisdomFile() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "catnip", "fftw-wisdom"), nil
+}
+
+// SetWisdomFile loads FFTW wisdom from path, if it exists, for SaveWisdom to
+// save to. An empty path disables wisdom. It does nothing for the pure Go fft.
+func SetWisdomFile(path string) error {
+	wisdomFile = path
+
+	if path == "" || !FFTW {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return importWisdom(path)
+}
+
+// SaveWisdom saves the FFTW wisdom to the file set by SetWisdomFile, if plans
+// were made since it was loaded. Call it once the plans are made, at the latest
+// on shutdown. It does nothing for the pure Go fft.
+func SaveWisdom() error {
+	if wisdomFile == "" || !FFTW || !wisdomNew.Swap(false) {
+		return nil
+	}
+
+	return exportWisdom(wisdomFile)
+}
+
 func InitPlan(pointer **Plan, input []float64, output []complex128) {
 	(*pointer) = &Plan{
 		input:  input,
@@ -1,20 +1,25 @@
 package fft
 
-import "testing"
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"gonum.org/v1/gonum/dsp/fourier"
+)
 
 func Benchmark(b *testing.B) {
 	if FFTW {
 		b.Log("Benchmarking FFTW.")
 	} else {
-		b.Log("Benchmarking gonum (built without cgo).")
+		b.Log("Benchmarking pure Go (built without cgo).")
 	}
 
 	reals := generateReals()
 	cmplx := make([]complex128, len(reals)/2+1)
-	fftpl := Plan{
-		input:  reals,
-		output: cmplx,
-	}
+
+	var fftpl *Plan
+	InitPlan(&fftpl, reals, cmplx)
 
 	b.ResetTimer()
 
@@ -23,6 +28,87 @@ func Benchmark(b *testing.B) {
 	}
 }
 
+func BenchmarkGonum(b *testing.B) {
+	reals := generateReals()
+	cmplx := make([]complex128, len(reals)/2+1)
+	fft := fourier.NewFFT(len(reals))
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		fft.Coefficients(cmplx, reals)
+	}
+}
+
+// benchmarkSize benchmarks the sizes catnip uses against gonum.
+func benchmarkSize(b *testing.B, size int, gonum bool) {
+	reals := generateReals()[:size]
+	cmplx := make([]complex128, size/2+1)
+
+	if gonum {
+		fft := fourier.NewFFT(size)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			fft.Coefficients(cmplx, reals)
+		}
+		return
+	}
+
+	fft := newRealFFT(size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fft.transform(cmplx, reals)
+	}
+}
+
+func BenchmarkPureGo1024(b *testing.B) { benchmarkSize(b, 1024, false) }
+func BenchmarkGonum1024(b *testing.B)  { benchmarkSize(b, 1024, true) }
+func BenchmarkPureGo2048(b *testing.B) { benchmarkSize(b, 2048, false) }
+func BenchmarkGonum2048(b *testing.B)  { benchmarkSize(b, 2048, true) }
+
+func TestRealFFT(t *testing.T) {
+	reals := generateReals()
+
+	// powers of two, mixed radix, odd and prime sizes.
+	for _, size := range []int{1, 2, 3, 4, 5, 6, 7, 8, 12, 15, 16, 30, 49, 64, 100, 128, 210, 256, 343, 512, 1000, 1024, 2048, 4096, 44100} {
+		input := make([]float64, size)
+		for idx := range input {
+			input[idx] = reals[idx] / 1e6
+		}
+
+		want := fourier.NewFFT(size).Coefficients(nil, input)
+
+		got := make([]complex128, size/2+1)
+		newRealFFT(size).transform(got, input)
+
+		maxWant := 0.0
+		for _, v := range want {
+			maxWant = math.Max(maxWant, cmplx.Abs(v))
+		}
+
+		for k := range want {
+			if diff := cmplx.Abs(got[k] - want[k]); diff > 1e-9*math.Max(maxWant, 1.0) {
+				t.Fatalf("size %d: coefficient %d is %v, want %v", size, k, got[k], want[k])
+			}
+		}
+	}
+}
+
+func TestPlanKeepsInput(t *testing.T) {
+	input := generateReals()[:1024]
+	orig := append([]float64(nil), input...)
+
+	var plan *Plan
+	InitPlan(&plan, input, make([]complex128, len(input)/2+1))
+	plan.Execute()
+
+	for idx := range input {
+		if input[idx] != orig[idx] {
+			t.Fatalf("input changed at %d", idx)
+		}
+	}
+}
+
 // Adapted from https://github.com/project-gemmi/benchmarking-fft/blob/master/1d-r.cpp
 
 const numReals = 44100
@@ -38,3 +124,30 @@ func generateReals() []float64 {
 
 	return input
 }
+
+func TestSplitFFT(t *testing.T) {
+	reals := generateReals()
+
+	for size := 4; size <= 1024; size *= 2 {
+		buf := make([]complex128, size)
+		for idx := range buf {
+			buf[idx] = complex(reals[2*idx], reals[2*idx+1]) / 1e6
+		}
+
+		// the dft, straight from its definition.
+		want := make([]complex128, size)
+		for k := range want {
+			for n, v := range buf {
+				want[k] += v * twiddle(k*n%size, size)
+			}
+		}
+
+		newSplitFFT(size).transform(buf)
+
+		for k := range want {
+			if diff := cmplx.Abs(buf[k] - want[k]); diff > 1e-9*float64(size) {
+				t.Fatalf("size %d: coefficient %d is %v, want %v", size, k, buf[k], want[k])
+			}
+		}
+	}
+}
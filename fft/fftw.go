@@ -8,10 +8,12 @@ package fft
 // implement here.
 
 // #cgo pkg-config: fftw3
+// #include <stdlib.h>
 // #include <fftw3.h>
 import "C"
 
 import (
+	"errors"
 	"runtime"
 	"unsafe"
 )
@@ -24,28 +26,87 @@ type Plan struct {
 	input  []float64
 	output []complex128
 	cPlan  C.fftw_plan
+
+	// used when fftw fails to make a plan.
+	fallback *realFFT
+}
+
+// plannerFlags maps a Planner to FFTW planner flags.
+var plannerFlags = [...]C.uint{
+	PlanEstimate: C.FFTW_ESTIMATE,
+	PlanMeasure:  C.FFTW_MEASURE,
+	PlanPatient:  C.FFTW_PATIENT,
 }
 
 // Init sets up the plan so we dont run checks during execute
 func (p *Plan) init() {
+	if p.cPlan != nil || p.fallback != nil {
+		return
+	}
+
+	// measuring plans overwrites the arrays, the caller may have filled input.
+	saved := append([]float64(nil), p.input...)
+
+	p.cPlan = C.fftw_plan_dft_r2c_1d(
+		C.int(len(p.input)),
+		(*C.double)(unsafe.Pointer(&p.input[0])),
+		(*C.fftw_complex)(unsafe.Pointer(&p.output[0])),
+		plannerFlags[planner],
+	)
+
+	copy(p.input, saved)
+
 	if p.cPlan == nil {
-		p.cPlan = C.fftw_plan_dft_r2c_1d(
-			C.int(len(p.input)),
-			(*C.double)(unsafe.Pointer(&p.input[0])),
-			(*C.fftw_complex)(unsafe.Pointer(&p.output[0])),
-			C.FFTW_MEASURE,
-		)
-
-		runtime.SetFinalizer(p, (*Plan).destroy)
+		p.fallback = newRealFFT(len(p.input))
+		return
+	}
+
+	runtime.SetFinalizer(p, (*Plan).destroy)
+
+	if planner != PlanEstimate {
+		wisdomNew.Store(true)
 	}
 }
 
 // Execute runs the plan
 func (p *Plan) Execute() {
+	if p.fallback != nil {
+		p.fallback.transform(p.output, p.input)
+		return
+	}
+
 	C.fftw_execute(p.cPlan)
 }
 
+// Fallback reports whether the plan uses the pure Go fft because FFTW could
+// not make a plan.
+func (p *Plan) Fallback() bool {
+	return p.fallback != nil
+}
+
 // destroy releases resources
 func (p *Plan) destroy() {
 	C.fftw_destroy_plan(p.cPlan)
 }
+
+func importWisdom(path string) error {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	if C.fftw_import_wisdom_from_filename(cPath) == 0 {
+		return errors.New("failed to import fftw wisdom from " + path)
+	}
+
+	return nil
+}
+
+func exportWisdom(path string) error {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	if C.fftw_export_wisdom_to_filename(cPath) == 0 {
+		return errors.New("failed to export fftw wisdom to " + path)
+	}
+
+	return nil
+}
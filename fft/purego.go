@@ -0,0 +1,39 @@
+//go:build !cgo || (!withfftw && !fftw)
+
+package fft
+
+// FFTW is false if Catnip is not built with cgo. It will use the pure Go fft
+// instead.
+const FFTW = false
+
+// Plan holds a pure Go FFT plan.
+type Plan struct {
+	input  []float64
+	output []complex128
+	fft    *realFFT
+}
+
+// Init sets up the plan so we dont run checks during execute
+func (p *Plan) init() {
+	if p.fft == nil {
+		p.fft = newRealFFT(len(p.input))
+	}
+}
+
+// Execute executes the pure Go plan.
+func (p *Plan) Execute() {
+	p.fft.transform(p.output, p.input)
+}
+
+// Fallback is always true, there is nothing to fall back from.
+func (p *Plan) Fallback() bool {
+	return true
+}
+
+func importWisdom(string) error {
+	return nil
+}
+
+func exportWisdom(string) error {
+	return nil
+}
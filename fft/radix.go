@@ -0,0 +1,373 @@
+package fft
+
+import "math"
+
+// complexFFT is a complex fft with precomputed twiddles. Powers of two use the
+// split-radix fft, other sizes a mixed radix one.
+//
+// Each mixed radix pass is a self-sorting (Stockham) butterfly like FFTPACK's
+// passf, so no bit reversal is needed. Sizes are split into factors of 4, 2, 3
+// and 5 first, other primes use a generic pass.
+type complexFFT struct {
+	n       int
+	split   *splitFFT // set for powers of two
+	passes  []pass
+	scratch []complex128
+}
+
+// pass is one stage of the transform.
+type pass struct {
+	radix int
+	l1    int          // product of the radices of the previous passes
+	ido   int          // n / (l1 * radix)
+	tw    []complex128 // twiddles, (radix - 1) * ido of them
+
+	// for the generic pass.
+	cos, sin    []float64 // cos and sin of 2 * pi * k / radix
+	sums, diffs []complex128
+}
+
+func newComplexFFT(n int) *complexFFT {
+	if n >= 4 && isPowerOfTwo(n) {
+		return &complexFFT{n: n, split: newSplitFFT(n)}
+	}
+
+	c := &complexFFT{
+		n:       n,
+		scratch: make([]complex128, n),
+	}
+
+	l1 := 1
+	for _, radix := range factorize(n) {
+		p := pass{
+			radix: radix,
+			l1:    l1,
+			ido:   n / (l1 * radix),
+		}
+
+		p.tw = make([]complex128, (radix-1)*p.ido)
+		for j := 1; j < radix; j++ {
+			for i := 0; i < p.ido; i++ {
+				p.tw[(j-1)*p.ido+i] = twiddle(i*j*l1, n)
+			}
+		}
+
+		if radix > 5 {
+			p.cos = make([]float64, radix)
+			p.sin = make([]float64, radix)
+			for j := range p.cos {
+				p.sin[j], p.cos[j] = math.Sincos(2.0 * math.Pi * float64(j) / float64(radix))
+			}
+
+			p.sums = make([]complex128, radix/2+1)
+			p.diffs = make([]complex128, radix/2+1)
+		}
+
+		c.passes = append(c.passes, p)
+		l1 *= radix
+	}
+
+	return c
+}
+
+// factorize splits n into radices, 4 first.
+func factorize(n int) []int {
+	var factors []int
+
+	for _, radix := range []int{4, 2, 3, 5} {
+		for n%radix == 0 {
+			factors = append(factors, radix)
+			n /= radix
+		}
+	}
+
+	for radix := 7; n > 1; radix += 2 {
+		for n%radix == 0 {
+			factors = append(factors, radix)
+			n /= radix
+		}
+	}
+
+	return factors
+}
+
+// twiddle returns exp(-2 * pi * i * k / n).
+func twiddle(k, n int) complex128 {
+	sin, cos := math.Sincos(-2.0 * math.Pi * float64(k) / float64(n))
+	return complex(cos, sin)
+}
+
+// transform computes the forward transform of buf in place.
+func (c *complexFFT) transform(buf []complex128) {
+	if c.split != nil {
+		c.split.transform(buf)
+		return
+	}
+
+	src, dst := buf, c.scratch
+
+	for idx := range c.passes {
+		p := &c.passes[idx]
+
+		switch p.radix {
+		case 2:
+			p.pass2(dst, src)
+		case 3:
+			p.pass3(dst, src)
+		case 4:
+			p.pass4(dst, src)
+		case 5:
+			p.pass5(dst, src)
+		default:
+			p.passN(dst, src)
+		}
+
+		src, dst = dst, src
+	}
+
+	if len(c.passes)%2 == 1 {
+		copy(buf, src)
+	}
+}
+
+// In all passes, the input is read as cc[k][j][i] and the output is written
+// as ch[j][k][i], with k < l1, j < radix and i < ido.
+
+func (p *pass) pass2(ch, cc []complex128) {
+	ido, l1 := p.ido, p.l1
+	tw := p.tw
+
+	for k := 0; k < l1; k++ {
+		in := cc[k*2*ido:]
+		a, b := in[:ido], in[ido:2*ido]
+		out0 := ch[k*ido : k*ido+ido]
+		out1 := ch[(l1+k)*ido : (l1+k)*ido+ido]
+
+		for i := range out0 {
+			out0[i] = a[i] + b[i]
+			out1[i] = (a[i] - b[i]) * tw[i]
+		}
+	}
+}
+
+func (p *pass) pass3(ch, cc []complex128) {
+	const sin60 = 0.8660254037844386
+
+	ido, l1 := p.ido, p.l1
+	tw1, tw2 := p.tw[:ido], p.tw[ido:2*ido]
+
+	for k := 0; k < l1; k++ {
+		in := cc[k*3*ido:]
+		a, b, c := in[:ido], in[ido:2*ido], in[2*ido:3*ido]
+
+		for i := 0; i < ido; i++ {
+			s := b[i] + c[i]
+			d := b[i] - c[i]
+
+			m := a[i] - (0.5 * s)
+			// -i * sin60 * d
+			r := complex(sin60*imag(d), -sin60*real(d))
+
+			ch[k*ido+i] = a[i] + s
+			ch[(l1+k)*ido+i] = (m + r) * tw1[i]
+			ch[(2*l1+k)*ido+i] = (m - r) * tw2[i]
+		}
+	}
+}
+
+func (p *pass) pass4(ch, cc []complex128) {
+	ido, l1 := p.ido, p.l1
+	tw1, tw2, tw3 := p.tw[:ido], p.tw[ido:2*ido], p.tw[2*ido:3*ido]
+
+	for k := 0; k < l1; k++ {
+		in := cc[k*4*ido:]
+		a, b, c, d := in[:ido], in[ido:2*ido], in[2*ido:3*ido], in[3*ido:4*ido]
+
+		out0 := ch[k*ido : k*ido+ido]
+		out1 := ch[(l1+k)*ido : (l1+k)*ido+ido]
+		out2 := ch[(2*l1+k)*ido : (2*l1+k)*ido+ido]
+		out3 := ch[(3*l1+k)*ido : (3*l1+k)*ido+ido]
+
+		if ido == 1 {
+			// last pass, all twiddles are 1.
+			s0, s1 := a[0]+c[0], a[0]-c[0]
+			s2, s3 := b[0]+d[0], b[0]-d[0]
+			s3 = complex(imag(s3), -real(s3)) // -i * s3
+
+			out0[0] = s0 + s2
+			out1[0] = s1 + s3
+			out2[0] = s0 - s2
+			out3[0] = s1 - s3
+			continue
+		}
+
+		for i := range out0 {
+			s0, s1 := a[i]+c[i], a[i]-c[i]
+			s2, s3 := b[i]+d[i], b[i]-d[i]
+			s3 = complex(imag(s3), -real(s3)) // -i * s3
+
+			out0[i] = s0 + s2
+			out1[i] = (s1 + s3) * tw1[i]
+			out2[i] = (s0 - s2) * tw2[i]
+			out3[i] = (s1 - s3) * tw3[i]
+		}
+	}
+}
+
+func (p *pass) pass5(ch, cc []complex128) {
+	const (
+		cos72 = 0.30901699437494745
+		sin72 = 0.9510565162951535
+		cos36 = 0.8090169943749475
+		sin36 = 0.5877852522924731
+	)
+
+	ido, l1 := p.ido, p.l1
+	tw := p.tw
+
+	for k := 0; k < l1; k++ {
+		in := cc[k*5*ido:]
+
+		for i := 0; i < ido; i++ {
+			a := in[i]
+			b, c, d, e := in[ido+i], in[2*ido+i], in[3*ido+i], in[4*ido+i]
+
+			s1, d1 := b+e, b-e
+			s2, d2 := c+d, c-d
+
+			m1 := a + (complex(cos72, 0) * s1) - (complex(cos36, 0) * s2)
+			m2 := a - (complex(cos36, 0) * s1) + (complex(cos72, 0) * s2)
+
+			// -i * (sin72 * d1 + sin36 * d2) and -i * (sin36 * d1 - sin72 * d2)
+			t1 := (complex(sin72, 0) * d1) + (complex(sin36, 0) * d2)
+			t2 := (complex(sin36, 0) * d1) - (complex(sin72, 0) * d2)
+			r1 := complex(imag(t1), -real(t1))
+			r2 := complex(imag(t2), -real(t2))
+
+			ch[k*ido+i] = a + s1 + s2
+			ch[(l1+k)*ido+i] = (m1 + r1) * tw[i]
+			ch[(2*l1+k)*ido+i] = (m2 + r2) * tw[ido+i]
+			ch[(3*l1+k)*ido+i] = (m2 - r2) * tw[2*ido+i]
+			ch[(4*l1+k)*ido+i] = (m1 - r1) * tw[3*ido+i]
+		}
+	}
+}
+
+// passN is a dft of an odd radix for each butterfly. Inputs q and radix-q are
+// paired up so the cosine and sine parts are only computed once.
+func (p *pass) passN(ch, cc []complex128) {
+	ido, l1, radix := p.ido, p.l1, p.radix
+	half := (radix - 1) / 2
+	sums, diffs := p.sums, p.diffs
+
+	for k := 0; k < l1; k++ {
+		in := cc[k*radix*ido:]
+
+		for i := 0; i < ido; i++ {
+			x0 := in[i]
+			y0 := x0
+
+			for q := 1; q <= half; q++ {
+				a, b := in[q*ido+i], in[(radix-q)*ido+i]
+				sums[q] = a + b
+				diffs[q] = a - b
+				y0 += sums[q]
+			}
+
+			ch[k*ido+i] = y0
+
+			for j := 1; j <= half; j++ {
+				re, im := x0, complex(0, 0)
+
+				r := 0
+				for q := 1; q <= half; q++ {
+					if r += j; r >= radix {
+						r -= radix
+					}
+					re += complex(p.cos[r], 0) * sums[q]
+					im += complex(p.sin[r], 0) * diffs[q]
+				}
+
+				// -i * im
+				im = complex(imag(im), -real(im))
+
+				ch[(j*l1+k)*ido+i] = (re + im) * p.tw[(j-1)*ido+i]
+				ch[((radix-j)*l1+k)*ido+i] = (re - im) * p.tw[(radix-j-1)*ido+i]
+			}
+		}
+	}
+}
+
+// realFFT computes the positive half of the spectrum of real input.
+//
+// Even sizes pack the input into a complex fft of half the size and split the
+// result, odd sizes run a full size complex fft.
+type realFFT struct {
+	n      int
+	cfft   *complexFFT
+	buf    []complex128
+	splits []complex128 // exp(-2 * pi * i * k / n) for k < n / 2
+}
+
+func newRealFFT(n int) *realFFT {
+	r := &realFFT{n: n}
+
+	if n%2 == 1 {
+		r.cfft = newComplexFFT(n)
+		r.buf = make([]complex128, n)
+		return r
+	}
+
+	half := n / 2
+	r.cfft = newComplexFFT(half)
+	r.buf = make([]complex128, half)
+	r.splits = make([]complex128, half)
+	for k := range r.splits {
+		r.splits[k] = twiddle(k, n)
+	}
+
+	return r
+}
+
+// transform writes the n/2+1 coefficients of src to dst.
+func (r *realFFT) transform(dst []complex128, src []float64) {
+	if r.n%2 == 1 {
+		for idx, v := range src[:r.n] {
+			r.buf[idx] = complex(v, 0)
+		}
+
+		r.cfft.transform(r.buf)
+		copy(dst, r.buf[:r.n/2+1])
+		return
+	}
+
+	half := r.n / 2
+	for idx := range r.buf {
+		r.buf[idx] = complex(src[2*idx], src[2*idx+1])
+	}
+
+	r.cfft.transform(r.buf)
+
+	// X[k] = E[k] + w^k * O[k] where E and O are the transforms of the even
+	// and odd samples, recovered from the packed transform Z:
+	//
+	// E[k] = (Z[k] + conj(Z[half - k])) / 2
+	// O[k] = (Z[k] - conj(Z[half - k])) / 2i
+	z0 := r.buf[0]
+	dst[0] = complex(real(z0)+imag(z0), 0)
+	dst[half] = complex(real(z0)-imag(z0), 0)
+
+	for k := 1; k < half; k++ {
+		z, zc := r.buf[k], r.buf[half-k]
+
+		eRe := (real(z) + real(zc)) * 0.5
+		eIm := (imag(z) - imag(zc)) * 0.5
+		oRe := (imag(z) + imag(zc)) * 0.5
+		oIm := (real(zc) - real(z)) * 0.5
+
+		w := r.splits[k]
+		dst[k] = complex(
+			eRe+(real(w)*oRe)-(imag(w)*oIm),
+			eIm+(real(w)*oIm)+(imag(w)*oRe))
+	}
+}
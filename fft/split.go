@@ -0,0 +1,98 @@
+package fft
+
+// splitFFT is an in-place split-radix complex fft for powers of two.
+//
+// Each stage splits a transform of length n2 into one of length n2/2 over the
+// even outputs and two of length n2/4 over the odd ones, so it takes fewer
+// multiplications than radix 2 or 4. The stages are decimation in frequency,
+// so the output is put back in order with a bit reversal at the end.
+//
+// https://doi.org/10.1109/TASSP.1986.1164811
+type splitFFT struct {
+	n    int
+	tw   []complex128 // exp(-2 * pi * i * k / n) for k < n
+	swap [][2]int     // index pairs of the bit reversal
+}
+
+func newSplitFFT(n int) *splitFFT {
+	s := &splitFFT{
+		n:  n,
+		tw: make([]complex128, n),
+	}
+
+	for k := range s.tw {
+		s.tw[k] = twiddle(k, n)
+	}
+
+	bits := 0
+	for 1<<bits < n {
+		bits++
+	}
+
+	for i := 0; i < n; i++ {
+		j := 0
+		for b := 0; b < bits; b++ {
+			j |= ((i >> b) & 1) << (bits - 1 - b)
+		}
+
+		if i < j {
+			s.swap = append(s.swap, [2]int{i, j})
+		}
+	}
+
+	return s
+}
+
+// isPowerOfTwo returns true if n is a power of two.
+func isPowerOfTwo(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
+
+// transform computes the forward transform of buf in place.
+func (s *splitFFT) transform(buf []complex128) {
+	n := s.n
+	x := buf[:n]
+
+	// the L shaped butterflies, on the blocks of each length n2 that are
+	// left after the stages before.
+	for n2 := n; n2 > 2; n2 /= 2 {
+		n4 := n2 / 4
+		step := n / n2
+
+		for j := 0; j < n4; j++ {
+			w1, w3 := s.tw[j*step], s.tw[3*j*step]
+
+			for is, id := j, 2*n2; is < n-1; is, id = 2*id-n2+j, 4*id {
+				for i0 := is; i0 < n-1; i0 += id {
+					i1 := i0 + n4
+					i2 := i1 + n4
+					i3 := i2 + n4
+
+					a, b, c, d := x[i0], x[i1], x[i2], x[i3]
+
+					x[i0] = a + c
+					x[i1] = b + d
+
+					t1, t2 := a-c, b-d
+					t2 = complex(imag(t2), -real(t2)) // -i * t2
+
+					x[i2] = (t1 + t2) * w1
+					x[i3] = (t1 - t2) * w3
+				}
+			}
+		}
+	}
+
+	// length 2 butterflies on what is left.
+	for is, id := 0, 4; is < n-1; is, id = 2*id-2, 4*id {
+		for i0 := is; i0 < n-1; i0 += id {
+			a, b := x[i0], x[i0+1]
+			x[i0] = a + b
+			x[i0+1] = a - b
+		}
+	}
+
+	for _, p := range s.swap {
+		x[p[0]], x[p[1]] = x[p[1]], x[p[0]]
+	}
+}
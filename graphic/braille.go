@@ -0,0 +1,97 @@
+package graphic
+
+import "github.com/nsf/termbox-go"
+
+// BrailleBase is the empty braille pattern. Each cell holds a 2x4 grid of
+// dots.
+const BrailleBase = '⠀'
+
+// brailleBits maps a dot at [x][y] within a cell to its bit in the pattern.
+var brailleBits = [2][4]rune{
+	{0x01, 0x02, 0x04, 0x40},
+	{0x08, 0x10, 0x20, 0x80},
+}
+
+// brailleCanvas is a dot canvas drawn with braille characters, giving twice
+// the horizontal and four times the vertical resolution of the terminal.
+type brailleCanvas struct {
+	width  int // in cells
+	height int // in cells
+	cells  []rune
+}
+
+// resize sets the canvas size in cells and clears it.
+func (c *brailleCanvas) resize(width, height int) {
+	width, height = intMax(width, 0), intMax(height, 0)
+
+	if cap(c.cells) < width*height {
+		c.cells = make([]rune, width*height)
+	}
+
+	c.width, c.height = width, height
+	c.cells = c.cells[:width*height]
+	c.clear()
+}
+
+func (c *brailleCanvas) clear() {
+	for idx := range c.cells {
+		c.cells[idx] = 0
+	}
+}
+
+// dots returns the size of the canvas in dots.
+func (c *brailleCanvas) dots() (int, int) {
+	return c.width * 2, c.height * 4
+}
+
+// set turns on the dot at x, y. Dots off the canvas are ignored.
+func (c *brailleCanvas) set(x, y int) {
+	if x < 0 || y < 0 || x >= c.width*2 || y >= c.height*4 {
+		return
+	}
+
+	c.cells[((y/4)*c.width)+(x/2)] |= brailleBits[x%2][y%4]
+}
+
+// line turns on the dots on the line from x0, y0 to x1, y1.
+func (c *brailleCanvas) line(x0, y0, x1, y1 int) {
+	dx, dy := x1-x0, y1-y0
+	sx, sy := 1, 1
+
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+
+	if dy < 0 {
+		dy, sy = -dy, -1
+	}
+
+	// bresenham
+	err := dx - dy
+	for {
+		c.set(x0, y0)
+
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+
+		if e2 := 2 * err; e2 > -dy {
+			err -= dy
+			x0 += sx
+		} else {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// draw puts the canvas on the screen with its top left corner at xOff, yOff.
+func (c *brailleCanvas) draw(xOff, yOff int, fg, bg termbox.Attribute) {
+	for idx, bits := range c.cells {
+		if bits == 0 {
+			continue
+		}
+
+		termbox.SetCell(xOff+(idx%c.width), yOff+(idx/c.width), BrailleBase+bits, fg, bg)
+	}
+}
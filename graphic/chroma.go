@@ -0,0 +1,63 @@
+package graphic
+
+import (
+	"fmt"
+
+	"github.com/noriah/catnip/dsp"
+
+	"github.com/nsf/termbox-go"
+)
+
+// drawChroma draws one labeled bar per pitch class with the key estimate of
+// Key on the top row.
+func (d *Display) drawChroma(bins [][]float64, channelCount int, scale float64) {
+	for class := range d.chromaBuf {
+		sum := 0.0
+		for ch := 0; ch < channelCount; ch++ {
+			sum += bins[ch][class]
+		}
+		d.chromaBuf[class] = sum / float64(channelCount)
+	}
+
+	if d.Key != nil {
+		if key := d.Key.Key(); key.Score > 0.0 {
+			d.drawString(0, 0, fmt.Sprintf("key: %s (%.2f)", key, key.Score))
+		}
+	}
+
+	// leave the top row for the key and the bottom row for the labels.
+	labelRow := d.termHeight - 1
+	barSpace := intMax(labelRow-1, 0)
+	scale = float64(barSpace) / scale
+
+	colWidth := d.termWidth / dsp.PitchClasses
+	barWidth := intMax(colWidth-d.spaceSize, 1)
+	edgeOffset := (d.termWidth - (colWidth * dsp.PitchClasses)) / 2
+
+	for xBar := 0; xBar < dsp.PitchClasses; xBar++ {
+		class := xBar
+		if d.invertDraw {
+			class = dsp.PitchClasses - 1 - class
+		}
+
+		start, bCap := sizeAndCap(d.chromaBuf[class]*scale, barSpace, true, BarRuneV)
+		start++
+
+		xCol := (xBar * colWidth) + edgeOffset
+		lCol := intMin(xCol+barWidth, d.termWidth)
+
+		d.drawString(xCol+((barWidth-len(dsp.PitchClassNames[class]))/2),
+			labelRow, dsp.PitchClassNames[class])
+
+		for ; xCol < lCol; xCol++ {
+
+			if bCap > BarRuneV {
+				termbox.SetCell(xCol, start-1, bCap, d.styles.Foreground, d.styles.Background)
+			}
+
+			for xRow := start; xRow < labelRow; xRow++ {
+				termbox.SetCell(xCol, xRow, BarRune, d.styleBuffer[xRow], d.styles.Background)
+			}
+		}
+	}
+}
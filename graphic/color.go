@@ -0,0 +1,100 @@
+package graphic
+
+import (
+	"math"
+
+	"github.com/nsf/termbox-go"
+)
+
+// colorMask is the color part of an attribute in 256-color mode.
+const colorMask = 0x1FF
+
+// ansiColors are the usual rgb values of the first 16 colors.
+var ansiColors = [16][3]uint8{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// cubeLevels are the channel levels of the 6x6x6 color cube.
+var cubeLevels = [6]uint8{0, 95, 135, 175, 215, 255}
+
+// gradientStops are the colors of the spectrogram gradient from quiet to loud.
+var gradientStops = [][3]float64{
+	{0, 0, 0},
+	{40, 10, 90},
+	{140, 30, 120},
+	{220, 60, 60},
+	{250, 160, 30},
+	{255, 250, 180},
+}
+
+// paletteToRGB returns the rgb value of a 256-color palette index.
+func paletteToRGB(idx int) (uint8, uint8, uint8) {
+	switch {
+	case idx < 16:
+		c := ansiColors[idx]
+		return c[0], c[1], c[2]
+
+	case idx < 232:
+		idx -= 16
+		return cubeLevels[idx/36], cubeLevels[(idx/6)%6], cubeLevels[idx%6]
+
+	default:
+		v := uint8(8 + ((idx - 232) * 10))
+		return v, v, v
+	}
+}
+
+// toRGBAttribute converts a 256-color attribute to a true color attribute,
+// keeping the style bits.
+func toRGBAttribute(attr termbox.Attribute) termbox.Attribute {
+	color := attr & colorMask
+	if color == termbox.ColorDefault {
+		return attr
+	}
+
+	r, g, b := paletteToRGB(int(color-1) % 256)
+
+	return termbox.RGBToAttribute(r, g, b) | (attr &^ colorMask)
+}
+
+// nearestCubeLevel returns the index of the cube level closest to v.
+func nearestCubeLevel(v float64) int {
+	best := 0
+	for idx, level := range cubeLevels {
+		if math.Abs(float64(level)-v) < math.Abs(float64(cubeLevels[best])-v) {
+			best = idx
+		}
+	}
+	return best
+}
+
+// makeGradient builds steps attributes along gradientStops, in true color or
+// in the nearest colors of the 256-color cube.
+func makeGradient(steps int, trueColor bool) []termbox.Attribute {
+	gradient := make([]termbox.Attribute, steps)
+
+	for idx := range gradient {
+		pos := float64(idx) / float64(intMax(steps-1, 1)) * float64(len(gradientStops)-1)
+		stop := intMin(int(pos), len(gradientStops)-2)
+		frac := pos - float64(stop)
+
+		var rgb [3]float64
+		for c := range rgb {
+			lo, hi := gradientStops[stop][c], gradientStops[stop+1][c]
+			rgb[c] = lo + ((hi - lo) * frac)
+		}
+
+		if trueColor {
+			gradient[idx] = termbox.RGBToAttribute(uint8(rgb[0]), uint8(rgb[1]), uint8(rgb[2]))
+			continue
+		}
+
+		cube := 16 + (36 * nearestCubeLevel(rgb[0])) + (6 * nearestCubeLevel(rgb[1])) + nearestCubeLevel(rgb[2])
+		gradient[idx] = termbox.Attribute(cube + 1)
+	}
+
+	return gradient
+}
@@ -5,8 +5,11 @@ import (
 	"sync/atomic"
 
 	"github.com/noriah/catnip/dsp"
+	"github.com/noriah/catnip/dsp/features"
+	"github.com/noriah/catnip/dsp/meter"
+	"github.com/noriah/catnip/dsp/scale"
+	"github.com/noriah/catnip/dsp/window"
 	"github.com/noriah/catnip/processor"
-	"github.com/noriah/catnip/util"
 
 	"github.com/nsf/termbox-go"
 )
@@ -26,11 +29,6 @@ const (
 
 	// NumRunes number of runes for sub step bars
 	NumRunes = 8
-
-	// ScalingWindow in seconds
-	ScalingWindow = 1.5
-	// PeakThreshold is the threshold to not draw if the peak is less.
-	PeakThreshold = 0.001
 )
 
 // DrawType is the type.
@@ -48,6 +46,10 @@ const (
 	DrawUpDownSplit
 	DrawLeftRightSplit
 	DrawUpDownSplitVert
+	DrawChroma
+	DrawGoniometer
+	DrawScope
+	DrawSpectrogram
 	DrawMax
 
 	// DrawDefault is the default draw type.
@@ -88,8 +90,26 @@ func (s Styles) AsUInt16s() (fg, bg, center uint16) {
 }
 
 // Display handles drawing our visualizer.
+//
+// Devices lists the devices for the picker shown with 'p', and Reconfigure
+// applies what is picked and the sample size and channel keys. It is also
+// asked for a Meter or Features when their overlay is first shown without
+// them. The keys do nothing if Reconfigure is nil.
+//
+// Metrics are shown in the stats overlay toggled with 'v'.
 type Display struct {
 	Smoother    dsp.Smoother
+	NoiseFloor  dsp.NoiseFloor
+	Meter       *meter.Meter
+	Features    *features.Extractor
+	Key         *dsp.KeyTracker
+	Scaler      *scale.Scaler
+	Windows     *window.Selector
+	Devices     func() ([]string, error)
+	Reconfigure func(Reconfig) error
+	Metrics     *processor.Metrics
+	stream      processor.Stream
+	picker      picker
 	running     uint32
 	barSize     int
 	spaceSize   int
@@ -97,9 +117,23 @@ type Display struct {
 	baseSize    int
 	termWidth   int
 	termHeight  int
-	trackZero   int
+	screenWidth int
 	invertDraw  bool
-	window      *util.MovingWindow
+	chroma      bool
+	showMeter   bool
+	showFeats   bool
+	showStats   bool
+	showLabels  bool
+	untriggered bool
+	trueColor   bool
+	scaled      [][]float64
+	chromaBuf   []float64
+	bands       []dsp.Band
+	canvas      brailleCanvas
+	history     spectrogram
+	gradient    []termbox.Attribute
+	notice      atomic.Value
+	scopePeak   float64
 	drawType    DrawType
 	styles      Styles
 	styleBuffer []termbox.Attribute
@@ -107,21 +141,29 @@ type Display struct {
 	cancel      context.CancelFunc
 }
 
-var _ processor.Output = &Display{}
+var _ processor.SampleOutput = &Display{}
+var _ processor.BandOutput = &Display{}
+var _ processor.Lifecycle = &Display{}
+var _ processor.StreamOutput = &Display{}
 
 func NewDisplay() *Display {
-	return &Display{}
+	return &Display{
+		chromaBuf: make([]float64, dsp.PitchClasses),
+		gradient:  makeGradient(GradientSteps, false),
+		// make a large buffer as this could be as big as the screen width/height.
+		styleBuffer: make([]termbox.Attribute, 4096),
+	}
 }
 
 // Init initializes the display.
 // Should be called before any other display method.
 func (d *Display) Init(sampleRate float64, sampleSize int) error {
-	// make a large buffer as this could be as big as the screen width/height.
-
-	windowSize := ((int(ScalingWindow * sampleRate)) / sampleSize) * 2
-	d.window = util.NewMovingWindow(windowSize)
-
-	d.styleBuffer = make([]termbox.Attribute, 4096)
+	if d.Scaler == nil {
+		d.Scaler = scale.New(scale.Config{
+			SampleRate: sampleRate,
+			SampleSize: sampleSize,
+		})
+	}
 
 	// Prevent crash on Tmux.
 	prevState, err := normalizeTerminal()
@@ -135,10 +177,15 @@ func (d *Display) Init(sampleRate float64, sampleSize int) error {
 	}
 
 	termbox.SetInputMode(termbox.InputAlt)
-	termbox.SetOutputMode(termbox.Output256)
+	if d.trueColor {
+		termbox.SetOutputMode(termbox.OutputRGB)
+	} else {
+		termbox.SetOutputMode(termbox.Output256)
+	}
 	termbox.HideCursor()
 
-	d.termWidth, d.termHeight = termbox.Size()
+	d.screenWidth, d.termHeight = termbox.Size()
+	d.updateLayout()
 
 	return nil
 }
@@ -149,13 +196,33 @@ func (d *Display) Close() error {
 	return nil
 }
 
-// Start display is bad.
-func (d *Display) Start(ctx context.Context) context.Context {
+// Setup initializes the display for stream.
+func (d *Display) Setup(stream processor.Stream) error {
+	d.stream = stream
+	return d.Init(stream.SampleRate, stream.SampleSize)
+}
+
+// SetStream follows a change of the stream while the display is up.
+func (d *Display) SetStream(stream processor.Stream) error {
+	d.stream = stream
+	d.Scaler.SetStream(stream.SampleRate, stream.SampleSize)
+	return nil
+}
+
+// Start starts handling key presses. The returned context is canceled when
+// the user quits.
+func (d *Display) Start(ctx context.Context) (context.Context, error) {
 	d.ctx, d.cancel = context.WithCancel(ctx)
 
 	go d.inputProcessor()
 
-	return d.ctx
+	return d.ctx, nil
+}
+
+// Cleanup stops the display and restores the terminal.
+func (d *Display) Cleanup() error {
+	d.Stop()
+	return d.Close()
 }
 
 // Stop display not work.
@@ -170,37 +237,14 @@ func (d *Display) Stop() error {
 // Draw takes data and draws.
 func (d *Display) Write(buffers [][]float64, channels int) error {
 
-	peak := 0.0
 	bins := d.binsInternal(channels, bufferLength(buffers))
 
-	for i := 0; i < channels; i++ {
-		for _, val := range buffers[i][:bins] {
-			if val > peak {
-				peak = val
-			}
-		}
-	}
+	d.scaled = d.Scaler.Scale(d.scaled, buffers[:channels], bins)
+	buffers = d.scaled
 
+	// the scaler has already fit the values to the screen.
 	scale := 1.0
 
-	if peak >= PeakThreshold {
-		d.trackZero = 0
-
-		// do some scaling if we are above the PeakThreshold
-		d.window.Update(peak)
-
-	} else {
-		if d.trackZero++; d.trackZero == 5 {
-			d.window.Recalculate()
-		}
-	}
-
-	vMean, vSD := d.window.Stats()
-
-	if t := vMean + (2.0 * vSD); t > 1.0 {
-		scale = t
-	}
-
 	switch d.drawType {
 	case DrawUp:
 		d.drawUp(buffers, channels, scale)
@@ -229,17 +273,80 @@ func (d *Display) Write(buffers [][]float64, channels int) error {
 	case DrawRight:
 		d.drawRight(buffers, channels, scale)
 
+	case DrawChroma:
+		d.drawChroma(buffers, channels, scale)
+
+	case DrawSpectrogram:
+		d.drawSpectrogram(buffers, channels, scale)
+
 	default:
 		return nil
 	}
 
-	termbox.Flush()
+	if d.showLabels {
+		d.drawLabels(bins, channels)
+	}
 
-	termbox.Clear(d.styles.Foreground, d.styles.Background)
+	d.present()
 
 	return nil
 }
 
+// SampleMode returns how the draw type wants raw samples.
+func (d *Display) SampleMode() processor.SampleMode {
+	switch d.drawType {
+	case DrawGoniometer:
+		return processor.SamplesRaw
+	case DrawScope:
+		if d.untriggered {
+			return processor.SamplesDecimated
+		}
+		return processor.SamplesTriggered
+	default:
+		return processor.SamplesNone
+	}
+}
+
+// WriteSamples takes raw samples and draws.
+func (d *Display) WriteSamples(buffers [][]float64, channels int) error {
+	switch d.drawType {
+	case DrawGoniometer:
+		d.drawGoniometer(buffers, channels)
+
+	case DrawScope:
+		d.drawScope(buffers, channels)
+
+	default:
+		return nil
+	}
+
+	d.present()
+
+	return nil
+}
+
+// present draws the side panels, shows the frame and clears for the next one.
+func (d *Display) present() {
+	if d.meterShown() {
+		d.drawMeter()
+	}
+
+	if d.showFeats && d.Features != nil {
+		d.drawFeatures()
+	}
+
+	if d.showStats && d.Metrics != nil {
+		d.drawStats()
+	}
+
+	d.drawNotice()
+	d.drawPicker()
+
+	termbox.Flush()
+
+	termbox.Clear(d.styles.Foreground, d.styles.Background)
+}
+
 // SetSizes takes a bar size and spacing size.
 // Returns number of bars able to show.
 func (d *Display) SetSizes(bar, space int) {
@@ -269,7 +376,15 @@ func (d *Display) AdjustBase(delta int) {
 	d.SetBase(d.baseSize + delta)
 }
 
+// SetStyles sets the styles. Colors are from the 256-color palette, and are
+// converted if true color is enabled.
 func (d *Display) SetStyles(styles Styles) {
+	if d.trueColor {
+		styles.Foreground = toRGBAttribute(styles.Foreground)
+		styles.Background = toRGBAttribute(styles.Background)
+		styles.CenterLine = toRGBAttribute(styles.CenterLine)
+	}
+
 	d.styles = styles
 
 	d.updateStyleBuffer()
@@ -286,13 +401,105 @@ func (d *Display) SetDrawType(dt DrawType) {
 		d.drawType = dt
 	}
 
+	// the chroma draw type only makes sense if we are fed pitch classes.
+	if d.drawType == DrawChroma && !d.chroma {
+		d.drawType = DrawDefault
+	}
+
 	d.updateStyleBuffer()
 }
 
+// CycleDrawType moves the draw type by delta, skipping draw types that are
+// not available.
+func (d *Display) CycleDrawType(delta DrawType) {
+	dt := d.drawType
+	for {
+		switch dt += delta; {
+		case dt <= DrawMin:
+			dt = DrawMax - 1
+		case dt >= DrawMax:
+			dt = DrawMin + 1
+		}
+
+		if dt != DrawChroma || d.chroma {
+			break
+		}
+	}
+
+	d.SetDrawType(dt)
+}
+
+// SetChroma tells the display that it is being fed pitch classes from a chroma
+// analyzer. This enables the chroma draw type.
+func (d *Display) SetChroma(chroma bool) {
+	d.chroma = chroma
+}
+
 func (d *Display) SetInvertDraw(invert bool) {
 	d.invertDraw = invert
 }
 
+// SetTrueColor enables 24-bit colors. It must be called before Init and
+// SetStyles.
+func (d *Display) SetTrueColor(trueColor bool) {
+	d.trueColor = trueColor
+	d.gradient = makeGradient(GradientSteps, trueColor)
+}
+
+// SetShowMeter shows or hides the level meter on the right side. The meter is
+// only drawn if Meter is set.
+func (d *Display) SetShowMeter(show bool) {
+	d.showMeter = show
+	d.updateLayout()
+}
+
+// SetShowFeatures shows or hides the spectral features overlay. The overlay is
+// only drawn if Features is set.
+func (d *Display) SetShowFeatures(show bool) {
+	d.showFeats = show
+}
+
+// SetShowStats shows or hides the pipeline stats overlay. The overlay is only
+// drawn if Metrics is set.
+func (d *Display) SetShowStats(show bool) {
+	d.showStats = show
+}
+
+// SetTriggered sets whether the scope aligns the waveform to a rising zero
+// crossing.
+func (d *Display) SetTriggered(triggered bool) {
+	d.untriggered = !triggered
+}
+
+// cycleWindow switches the window function by delta and shows its name.
+func (d *Display) cycleWindow(delta int) {
+	if d.Windows == nil {
+		return
+	}
+
+	if delta < 0 {
+		d.Windows.Prev()
+	} else {
+		d.Windows.Next()
+	}
+
+	d.notify("window: " + d.Windows.Name())
+}
+
+func (d *Display) meterShown() bool {
+	return d.showMeter && d.Meter != nil
+}
+
+// updateLayout splits the screen between the bars and the side panels.
+func (d *Display) updateLayout() {
+	d.termWidth = d.screenWidth
+	if d.meterShown() {
+		d.termWidth = intMax(d.screenWidth-MeterWidth, 0)
+	}
+
+	d.updateStyleBuffer()
+}
+
 // Bins returns the number of bars we will draw.
 func (d *Display) Bins(chCount int) int {
 
@@ -309,6 +516,14 @@ func (d *Display) Bins(chCount int) int {
 		return (d.termHeight / d.binSize) / 2
 	case DrawLeftRight:
 		return d.termHeight / d.binSize
+	case DrawChroma:
+		return dsp.PitchClasses
+	case DrawScope:
+		// one point per braille dot column
+		return d.termWidth * 2
+	case DrawSpectrogram:
+		// two bins per row with half blocks
+		return d.termHeight * 2
 	default:
 		return 0
 	}
@@ -339,10 +554,14 @@ func (d *Display) inputProcessor() {
 
 		switch ev.Type {
 		case termbox.EventKey:
+			if d.pickerKey(ev) {
+				break
+			}
+
 			switch ev.Key {
 
 			case termbox.KeySpace:
-				d.SetDrawType(d.drawType + 1)
+				d.CycleDrawType(1)
 
 			case termbox.KeyCtrlC:
 				return
@@ -350,10 +569,10 @@ func (d *Display) inputProcessor() {
 			default:
 				switch ev.Ch {
 				case 'b', 'B':
-					d.SetDrawType(d.drawType - 1)
+					d.CycleDrawType(-1)
 
 				case 'n', 'N':
-					d.SetDrawType(d.drawType + 1)
+					d.CycleDrawType(1)
 
 				case 'w', 'W':
 					d.AdjustSizes(1, 0)
@@ -370,8 +589,31 @@ func (d *Display) inputProcessor() {
 				case 'i', 'I':
 					d.SetInvertDraw(!d.invertDraw)
 
+				case 'm', 'M':
+					d.toggleMeter()
+
+				case 'c', 'C':
+					if d.NoiseFloor != nil {
+						d.NoiseFloor.Calibrate()
+					}
+
+				case 'f', 'F':
+					d.toggleFeatures()
+
+				case 'l', 'L':
+					d.SetShowLabels(!d.showLabels)
+
+				case ',', '<':
+					d.cycleWindow(-1)
+
+				case '.', '>':
+					d.cycleWindow(1)
+
+				case 't', 'T':
+					d.SetTriggered(d.untriggered)
+
 				case 'r', 'R':
-					d.window.Drop(d.window.Cap())
+					d.Scaler.Reset()
 
 				case '+', '=':
 					d.AdjustBase(1)
@@ -385,6 +627,21 @@ func (d *Display) inputProcessor() {
 				case ']':
 					d.Smoother.SetMethod(d.Smoother.GetMethod() + 1)
 
+				case 'p', 'P':
+					d.openPicker()
+
+				case '(':
+					d.adjustSampleSize(-1)
+
+				case ')':
+					d.adjustSampleSize(1)
+
+				case 'o', 'O':
+					d.toggleChannels()
+
+				case 'v', 'V':
+					d.SetShowStats(!d.showStats)
+
 				case 'q', 'Q':
 					return
 
@@ -393,9 +650,9 @@ func (d *Display) inputProcessor() {
 			} // switch ev.Key
 
 		case termbox.EventResize:
-			d.termWidth = ev.Width
+			d.screenWidth = ev.Width
 			d.termHeight = ev.Height
-			d.updateStyleBuffer()
+			d.updateLayout()
 
 		case termbox.EventInterrupt:
 			return
@@ -428,7 +685,7 @@ func intMin(x1, x2 int) int {
 
 func (d *Display) updateStyleBuffer() {
 	switch d.drawType {
-	case DrawUp:
+	case DrawUp, DrawChroma:
 		d.fillStyleBuffer(d.termHeight-d.baseSize, d.baseSize, 0)
 
 	case DrawUpDown, DrawUpDownSplit, DrawUpDownSplitVert:
@@ -485,6 +742,21 @@ func sizeAndCap(value float64, space int, zeroBase bool, baseRune rune) (int, ru
 	return space, baseRune
 }
 
+// drawString writes text starting at x, y in the foreground style.
+func (d *Display) drawString(x, y int, text string) {
+	for _, r := range text {
+		if x >= d.screenWidth {
+			return
+		}
+
+		if x >= 0 {
+			termbox.SetCell(x, y, r, d.styles.Foreground, d.styles.Background)
+		}
+
+		x++
+	}
+}
+
 // DRAWING METHODS
 
 // drawUp will draw up.
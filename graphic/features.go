@@ -0,0 +1,33 @@
+package graphic
+
+import (
+	"fmt"
+
+	"github.com/noriah/catnip/dsp/features"
+)
+
+// drawFeatures draws the spectral features, averaged over the channels, in
+// the top right corner of the bars.
+func (d *Display) drawFeatures() {
+	all := d.Features.Features()
+	if len(all) == 0 {
+		return
+	}
+
+	var avg features.Features
+	for _, f := range all {
+		avg.Centroid += f.Centroid
+		avg.Flux += f.Flux
+		avg.Rolloff += f.Rolloff
+		avg.Flatness += f.Flatness
+		avg.Crest += f.Crest
+	}
+
+	count := float64(len(all))
+
+	text := fmt.Sprintf("cen %5.0fHz flux %4.2f roll %5.0fHz flat %4.2f crest %5.1f",
+		avg.Centroid/count, avg.Flux/count, avg.Rolloff/count,
+		avg.Flatness/count, avg.Crest/count)
+
+	d.drawString(intMax(d.termWidth-len(text), 0), 0, text)
+}
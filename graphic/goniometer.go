@@ -0,0 +1,92 @@
+package graphic
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Scope Constants
+const (
+	// ScopePeakDecay is how fast the automatic gain of the sample views
+	// recovers from a peak, per frame.
+	ScopePeakDecay = 0.97
+	// ScopeMinPeak is the smallest peak the sample views will scale up to.
+	ScopeMinPeak = 0.01
+
+	correlationRune = '┃'
+)
+
+// scopeGain tracks the peak of the samples and returns the gain that fits them
+// to [-1, 1].
+func (d *Display) scopeGain(peak float64) float64 {
+	d.scopePeak = math.Max(d.scopePeak*ScopePeakDecay, math.Max(peak, ScopeMinPeak))
+	return 1.0 / d.scopePeak
+}
+
+// drawGoniometer draws the samples as a vectorscope. Mono signals form a
+// vertical line, out of phase signals a horizontal one. The bottom row shows
+// the phase correlation if a Meter is set.
+func (d *Display) drawGoniometer(buffers [][]float64, channelCount int) {
+	left, right := buffers[0], buffers[1%channelCount]
+
+	// rotate by 45 degrees so that mid is up and side is across.
+	peak := 0.0
+	for n, l := range left {
+		r := right[n]
+		peak = math.Max(peak, math.Max(math.Abs(l+r), math.Abs(r-l))/math.Sqrt2)
+	}
+
+	gain := d.scopeGain(peak)
+
+	rows := intMax(d.termHeight-1, 0)
+	d.canvas.resize(d.termWidth, rows)
+
+	dotsX, dotsY := d.canvas.dots()
+
+	// dots are about square, so fit a circle in the smaller side.
+	radius := float64(intMin(dotsX, dotsY)) / 2.0
+	cX, cY := float64(dotsX)/2.0, float64(dotsY)/2.0
+
+	for n, l := range left {
+		r := right[n]
+		side := (r - l) / math.Sqrt2 * gain
+		mid := (l + r) / math.Sqrt2 * gain
+
+		d.canvas.set(int(cX+(side*radius)), int(cY-(mid*radius)))
+	}
+
+	d.canvas.draw(0, 0, d.styles.Foreground, d.styles.Background)
+
+	if d.Meter != nil {
+		d.drawCorrelation(rows)
+	}
+}
+
+// drawCorrelation draws a -1 to +1 correlation bar on row.
+func (d *Display) drawCorrelation(row int) {
+	levels := d.Meter.Levels()
+
+	label := fmt.Sprintf(" %+.2f side %2.0f%%", levels.Correlation, levels.SideBalance*100.0)
+
+	d.drawString(0, row, "-1")
+
+	barStart := 3
+	barWidth := d.termWidth - barStart - 3 - len(label)
+	if barWidth < 3 {
+		return
+	}
+
+	for x := 0; x < barWidth; x++ {
+		termbox.SetCell(barStart+x, row, '─', d.styles.Foreground, d.styles.Background)
+	}
+
+	center := barStart + (barWidth / 2)
+	termbox.SetCell(center, row, '┼', d.styles.Foreground, d.styles.Background)
+
+	pos := barStart + int((levels.Correlation+1.0)/2.0*float64(barWidth-1))
+	termbox.SetCell(pos, row, correlationRune, d.styles.CenterLine, d.styles.Background)
+
+	d.drawString(barStart+barWidth+1, row, "+1"+label)
+}
@@ -0,0 +1,92 @@
+package graphic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/noriah/catnip/dsp"
+)
+
+// LabelGap is the least number of columns between two axis labels.
+const LabelGap = 2
+
+// SetBands sets the frequency band of each bin, used for axis labels.
+func (d *Display) SetBands(bands []dsp.Band) {
+	d.bands = append(d.bands[:0], bands...)
+}
+
+// SetShowLabels sets whether frequency labels are drawn along the bars.
+func (d *Display) SetShowLabels(show bool) {
+	d.showLabels = show
+}
+
+// drawLabels writes the center frequency of bars along the top row, skipping
+// bars that would crowd the previous label. Only draw types with bars along
+// the x axis have labels.
+func (d *Display) drawLabels(binCount, channelCount int) {
+	if binCount > len(d.bands) {
+		return
+	}
+
+	lastEnd := -LabelGap
+
+	label := func(xCol, xBin int) {
+		if xCol < lastEnd+LabelGap || xCol >= d.termWidth {
+			return
+		}
+
+		text := formatFrequency(d.bands[xBin].Center)
+		if xCol+len(text) > d.termWidth {
+			return
+		}
+
+		d.drawString(xCol, 0, text)
+		lastEnd = xCol + len(text)
+	}
+
+	switch d.drawType {
+	case DrawUp:
+		// same layout as drawUp.
+		paddedWidth := (d.binSize * binCount * channelCount) - d.spaceSize
+		paddedWidth = intMax(intMin(paddedWidth, d.termWidth), 0)
+
+		channelWidth := d.binSize * binCount
+		edgeOffset := (d.termWidth - paddedWidth) / 2
+
+		for xSet := 0; xSet < channelCount; xSet++ {
+			for xBar := 0; xBar < binCount; xBar++ {
+				xBin := (xBar * (1 - xSet)) + (((binCount - 1) - xBar) * xSet)
+				if d.invertDraw {
+					xBin = binCount - 1 - xBin
+				}
+
+				label((xBar*d.binSize)+(channelWidth*xSet)+edgeOffset, xBin)
+			}
+		}
+
+	case DrawUpDown:
+		// same layout as drawUpDown.
+		edgeOffset := intMax((d.termWidth-((d.binSize*binCount)-d.spaceSize))/2, 0)
+
+		for xCol := 0; xCol < binCount; xCol++ {
+			xBin := xCol
+			if d.invertDraw {
+				xBin = binCount - 1 - xBin
+			}
+
+			label(xCol*d.binSize+edgeOffset, xBin)
+		}
+	}
+}
+
+// formatFrequency formats freq in a few characters, e.g. 60, 250, 1.2k, 16k.
+func formatFrequency(freq float64) string {
+	switch {
+	case freq < 1000.0:
+		return fmt.Sprintf("%.0f", freq)
+	case freq < 10000.0:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", freq/1000.0), ".0") + "k"
+	default:
+		return fmt.Sprintf("%.0fk", freq/1000.0)
+	}
+}
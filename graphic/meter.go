@@ -0,0 +1,99 @@
+package graphic
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Meter Constants
+const (
+	// MeterWidth is the number of columns taken by the level meter.
+	MeterWidth = 14
+	// MeterFloor is the level in dB at the bottom of the meter.
+	MeterFloor = -60.0
+	// MeterTarget is the loudness target in LUFS marked on the meter.
+	MeterTarget = -14.0
+
+	meterTextRows = 5
+	peakRune      = '─'
+	targetRune    = '◀'
+)
+
+// drawMeter draws the level meter to the right of the bars.
+//
+// The top rows hold the momentary, short-term and integrated loudness and the
+// max true peak. Below are one rms bar per channel with a true peak marker and
+// a momentary loudness bar with the target marked.
+func (d *Display) drawMeter() {
+	levels := d.Meter.Levels()
+
+	xStart := d.termWidth + 1
+
+	d.drawString(xStart, 0, "M  "+formatLevel(levels.Momentary))
+	d.drawString(xStart, 1, "S  "+formatLevel(levels.ShortTerm))
+	d.drawString(xStart, 2, "I  "+formatLevel(levels.Integrated))
+	d.drawString(xStart, 3, "TP "+formatLevel(levels.MaxTruePeak))
+
+	barSpace := intMax(d.termHeight-meterTextRows, 0)
+
+	xCol := xStart
+	for ch := range levels.RMS {
+		d.drawMeterBar(xCol, barSpace, levels.RMS[ch])
+
+		if row, ok := d.meterRow(levels.TruePeak[ch], barSpace); ok {
+			termbox.SetCell(xCol, row, peakRune, d.styles.CenterLine, d.styles.Background)
+			termbox.SetCell(xCol+1, row, peakRune, d.styles.CenterLine, d.styles.Background)
+		}
+
+		xCol += 3
+	}
+
+	xCol++
+	d.drawMeterBar(xCol, barSpace, levels.Momentary)
+
+	if row, ok := d.meterRow(MeterTarget, barSpace); ok {
+		termbox.SetCell(xCol+2, row, targetRune, d.styles.CenterLine, d.styles.Background)
+		d.drawString(xCol+3, row, fmt.Sprintf("%.0f", MeterTarget))
+	}
+}
+
+// drawMeterBar draws a two column bar for level at xCol.
+func (d *Display) drawMeterBar(xCol, barSpace int, level float64) {
+	start, bCap := sizeAndCap(meterFraction(level)*float64(barSpace), barSpace, true, BarRuneV)
+	start += meterTextRows
+
+	for lCol := xCol + 2; xCol < lCol && xCol < d.screenWidth; xCol++ {
+		if bCap > BarRuneV {
+			termbox.SetCell(xCol, start-1, bCap, d.styles.Foreground, d.styles.Background)
+		}
+
+		for xRow := start; xRow < d.termHeight; xRow++ {
+			termbox.SetCell(xCol, xRow, BarRune, d.styles.Foreground, d.styles.Background)
+		}
+	}
+}
+
+// meterRow returns the screen row for level, and false if it is off the meter.
+func (d *Display) meterRow(level float64, barSpace int) (int, bool) {
+	frac := meterFraction(level)
+	if frac <= 0.0 || barSpace <= 0 {
+		return 0, false
+	}
+
+	row := d.termHeight - 1 - int(frac*float64(barSpace-1))
+	return row, true
+}
+
+// meterFraction maps a level in dB to [0, 1] on the meter scale.
+func meterFraction(level float64) float64 {
+	return math.Max(0.0, math.Min(1.0, (level-MeterFloor)/-MeterFloor))
+}
+
+func formatLevel(level float64) string {
+	if math.IsInf(level, -1) {
+		return "  -inf"
+	}
+	return fmt.Sprintf("%6.1f", level)
+}
@@ -0,0 +1,27 @@
+package graphic
+
+import "time"
+
+// NoticeDuration is how long a notice stays on screen.
+const NoticeDuration = 2 * time.Second
+
+// notice is a short message shown at the bottom of the screen.
+type notice struct {
+	text  string
+	until time.Time
+}
+
+// notify shows text for NoticeDuration. It may be called from any goroutine.
+func (d *Display) notify(text string) {
+	d.notice.Store(notice{text: text, until: time.Now().Add(NoticeDuration)})
+}
+
+// drawNotice draws the current notice, if any.
+func (d *Display) drawNotice() {
+	n, ok := d.notice.Load().(notice)
+	if !ok || time.Now().After(n.until) {
+		return
+	}
+
+	d.drawString(0, d.termHeight-1, n.text)
+}
@@ -0,0 +1,170 @@
+package graphic
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Reconfig is a change to the capture asked for from the display. Zero fields
+// are left as they are.
+type Reconfig struct {
+	Device       string // device to capture from
+	SampleSteps  int    // times to double (or halve if negative) the sample size
+	ChannelCount int    // number of channels
+	Meter        bool   // measure levels for the meter
+	Features     bool   // extract features for the overlay
+}
+
+// picker is the device list shown with 'p'.
+type picker struct {
+	mu    sync.Mutex
+	open  bool
+	items []string
+	index int
+}
+
+// openPicker lists the devices to pick from.
+func (d *Display) openPicker() {
+	if d.Devices == nil || d.Reconfigure == nil {
+		return
+	}
+
+	items, err := d.Devices()
+	if err != nil {
+		d.notify("devices: " + err.Error())
+		return
+	}
+
+	if len(items) == 0 {
+		d.notify("devices: none found")
+		return
+	}
+
+	d.picker.mu.Lock()
+	d.picker.open, d.picker.items, d.picker.index = true, items, 0
+	d.picker.mu.Unlock()
+}
+
+// pickerKey handles a key press while the picker is open. It returns false if
+// the picker is closed.
+func (d *Display) pickerKey(ev termbox.Event) bool {
+	d.picker.mu.Lock()
+
+	if !d.picker.open {
+		d.picker.mu.Unlock()
+		return false
+	}
+
+	var picked string
+
+	switch {
+	case ev.Key == termbox.KeyArrowUp || ev.Ch == 'k':
+		d.picker.index = intMax(d.picker.index-1, 0)
+
+	case ev.Key == termbox.KeyArrowDown || ev.Ch == 'j':
+		d.picker.index = intMin(d.picker.index+1, len(d.picker.items)-1)
+
+	case ev.Key == termbox.KeyEnter:
+		picked = d.picker.items[d.picker.index]
+		d.picker.open = false
+
+	case ev.Key == termbox.KeyEsc || ev.Ch == 'p' || ev.Ch == 'q':
+		d.picker.open = false
+	}
+
+	d.picker.mu.Unlock()
+
+	if picked != "" {
+		d.reconfigure(Reconfig{Device: picked}, "device: "+picked)
+	}
+
+	return true
+}
+
+// reconfigure asks for r and shows done or the error.
+func (d *Display) reconfigure(r Reconfig, done string) {
+	if d.Reconfigure == nil {
+		return
+	}
+
+	if err := d.Reconfigure(r); err != nil {
+		d.notify(err.Error())
+		return
+	}
+
+	d.notify(done)
+}
+
+// adjustSampleSize doubles or halves the sample size steps times.
+func (d *Display) adjustSampleSize(steps int) {
+	size := d.stream.SampleSize
+	if steps > 0 {
+		size <<= steps
+	} else {
+		size >>= -steps
+	}
+
+	d.reconfigure(Reconfig{SampleSteps: steps}, fmt.Sprintf("samples: %d", size))
+}
+
+// toggleChannels switches between one and two channels.
+func (d *Display) toggleChannels() {
+	count := 3 - d.stream.ChannelCount
+	d.reconfigure(Reconfig{ChannelCount: count}, fmt.Sprintf("channels: %d", count))
+}
+
+// toggleMeter shows or hides the meter, asking for one if there is none.
+func (d *Display) toggleMeter() {
+	if !d.showMeter && d.Meter == nil {
+		d.reconfigure(Reconfig{Meter: true}, "meter: on")
+	}
+
+	d.SetShowMeter(!d.showMeter)
+}
+
+// toggleFeatures shows or hides the features overlay, asking for features if
+// there are none.
+func (d *Display) toggleFeatures() {
+	if !d.showFeats && d.Features == nil {
+		d.reconfigure(Reconfig{Features: true}, "features: on")
+	}
+
+	d.SetShowFeatures(!d.showFeats)
+}
+
+// drawPicker draws the device list in the top left corner, if open.
+func (d *Display) drawPicker() {
+	d.picker.mu.Lock()
+	defer d.picker.mu.Unlock()
+
+	if !d.picker.open {
+		return
+	}
+
+	width := 0
+	for _, item := range d.picker.items {
+		width = intMax(width, len(item))
+	}
+
+	for idx, item := range d.picker.items {
+		if idx >= d.termHeight-1 {
+			return
+		}
+
+		fg, bg := d.styles.Foreground, d.styles.Background
+		if idx == d.picker.index {
+			fg |= termbox.AttrReverse
+		}
+
+		x := 0
+		for _, r := range fmt.Sprintf(" %-*s ", width, item) {
+			if x >= d.screenWidth {
+				break
+			}
+			termbox.SetCell(x, idx, r, fg, bg)
+			x++
+		}
+	}
+}
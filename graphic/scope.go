@@ -0,0 +1,44 @@
+package graphic
+
+import "math"
+
+// drawScope draws the waveform of each channel as a line, one lane per
+// channel from top to bottom.
+func (d *Display) drawScope(buffers [][]float64, channelCount int) {
+	peak := 0.0
+	for _, buf := range buffers[:channelCount] {
+		for _, v := range buf {
+			peak = math.Max(peak, math.Abs(v))
+		}
+	}
+
+	gain := d.scopeGain(peak)
+
+	d.canvas.resize(d.termWidth, d.termHeight)
+
+	dotsX, dotsY := d.canvas.dots()
+	laneHeight := dotsY / channelCount
+
+	for ch, buf := range buffers[:channelCount] {
+		points := len(buf)
+		if points < 2 {
+			continue
+		}
+
+		center := float64((laneHeight * ch) + (laneHeight / 2))
+		half := float64(laneHeight-1) / 2.0
+		step := float64(dotsX-1) / float64(points-1)
+
+		lastX, lastY := 0, int(center-(buf[0]*gain*half))
+
+		for idx, v := range buf[1:] {
+			x := int(float64(idx+1) * step)
+			y := int(center - (v * gain * half))
+
+			d.canvas.line(lastX, lastY, x, y)
+			lastX, lastY = x, y
+		}
+	}
+
+	d.canvas.draw(0, 0, d.styles.Foreground, d.styles.Background)
+}
@@ -0,0 +1,85 @@
+package graphic
+
+import "github.com/nsf/termbox-go"
+
+// GradientSteps is the number of colors in the spectrogram gradient.
+const GradientSteps = 64
+
+// spectrogram is the history of past frames for the spectrogram draw type.
+type spectrogram struct {
+	frames [][]float64 // ring of frames, values in [0, 1]
+	index  int         // where the next frame goes
+	mix    []float64   // channels mixed down for the current frame
+}
+
+// push adds a frame, scaled down by scale. The history is reset if the size
+// of the screen or the number of bins changed.
+func (s *spectrogram) push(values []float64, width int, scale float64) {
+	if len(s.frames) != width || (width > 0 && len(s.frames[0]) != len(values)) {
+		s.frames = make([][]float64, width)
+		for idx := range s.frames {
+			s.frames[idx] = make([]float64, len(values))
+		}
+		s.index = 0
+	}
+
+	if width == 0 {
+		return
+	}
+
+	frame := s.frames[s.index]
+	for idx, v := range values {
+		v /= scale
+		switch {
+		case !(v > 0.0):
+			// also NaN, from the smoother on silent bins.
+			v = 0.0
+		case v > 1.0:
+			v = 1.0
+		}
+		frame[idx] = v
+	}
+
+	s.index = (s.index + 1) % width
+}
+
+// drawSpectrogram draws time from left to right and frequency from bottom to
+// top. Each cell holds two bins with a half block.
+func (d *Display) drawSpectrogram(bins [][]float64, channelCount int, scale float64) {
+	binCount := d.binsInternal(channelCount, bufferLength(bins))
+
+	values := d.history.mix[:0]
+	for idx := 0; idx < binCount; idx++ {
+		sum := 0.0
+		for ch := 0; ch < channelCount; ch++ {
+			sum += bins[ch][idx]
+		}
+		values = append(values, sum/float64(channelCount))
+	}
+	d.history.mix = values
+
+	d.history.push(values, d.termWidth, scale)
+
+	last := len(d.gradient) - 1
+	color := func(frame []float64, idx int) termbox.Attribute {
+		if idx >= len(frame) {
+			return d.styles.Background
+		}
+		return d.gradient[int(frame[idx]*float64(last))]
+	}
+
+	// the oldest frame is at the current index.
+	for xCol := 0; xCol < d.termWidth; xCol++ {
+		frame := d.history.frames[(d.history.index+xCol)%d.termWidth]
+
+		col := xCol
+		if d.invertDraw {
+			col = d.termWidth - 1 - xCol
+		}
+
+		for xRow := 0; xRow < d.termHeight; xRow++ {
+			lo := (d.termHeight - 1 - xRow) * 2
+			termbox.SetCell(col, xRow, BarRuneV, color(frame, lo+1), color(frame, lo))
+		}
+	}
+}
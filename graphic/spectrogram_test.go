@@ -0,0 +1,37 @@
+package graphic
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSpectrogramPushSilence(t *testing.T) {
+	var s spectrogram
+
+	gradient := makeGradient(GradientSteps, false)
+	last := len(gradient) - 1
+
+	frames := [][]float64{
+		{math.NaN(), math.NaN(), math.NaN(), math.NaN()},
+		{0, 0, 0, 0},
+		{math.NaN(), -1, 0.5, math.Inf(1)},
+	}
+
+	for _, values := range frames {
+		s.push(values, 8, 1.0)
+	}
+
+	// a silent scaler gives a scale of 0.
+	s.push([]float64{0, 0, 0, 0}, 8, 0.0)
+
+	for _, frame := range s.frames[:len(frames)+1] {
+		for idx, v := range frame {
+			if !(v >= 0 && v <= 1) {
+				t.Fatalf("bin %d is %v, want [0, 1]", idx, v)
+			}
+
+			// the color lookup of drawSpectrogram.
+			_ = gradient[int(v*float64(last))]
+		}
+	}
+}
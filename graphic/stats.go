@@ -0,0 +1,35 @@
+package graphic
+
+import (
+	"fmt"
+	"time"
+)
+
+// drawStats draws the pipeline stats of the last second in the top left
+// corner. Output includes drawing the display itself.
+func (d *Display) drawStats() {
+	s := d.Metrics.Stats()
+
+	fps := fmt.Sprintf("fps      %5.1f", s.FPS)
+	if s.TargetFPS > 0 {
+		fps += fmt.Sprintf(" / %.0f", s.TargetFPS)
+	}
+
+	lines := []string{
+		fps,
+		fmt.Sprintf("latency  %6.2fms (max %.2fms)", ms(s.Latency), ms(s.MaxLatency)),
+		fmt.Sprintf("fft      %6.2fms", ms(s.FFT)),
+		fmt.Sprintf("analyze  %6.2fms", ms(s.Analyze)),
+		fmt.Sprintf("output   %6.2fms", ms(s.Output)),
+		fmt.Sprintf("late %d dropped %d expired %d overflows %d",
+			s.Late, s.Dropped, s.Expired, s.Overflows),
+	}
+
+	for row, line := range lines {
+		d.drawString(0, row, line)
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
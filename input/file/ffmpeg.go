@@ -0,0 +1,74 @@
+package file
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+
+	"github.com/pkg/errors"
+)
+
+// FFmpeg reads a file decoded by ffmpeg.
+type FFmpeg struct {
+	*pcmReader
+	cmd  *exec.Cmd
+	out  io.ReadCloser
+	rate float64
+}
+
+// OpenFFmpeg starts ffmpeg decoding path to rate and channels.
+func OpenFFmpeg(path string, rate float64, channels int) (*FFmpeg, error) {
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-loglevel", "error", "-nostdin",
+		"-i", path,
+		"-ar", fmt.Sprintf("%.0f", rate),
+		"-ac", fmt.Sprintf("%d", channels),
+		"-f", "f32le",
+		"-",
+	)
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get stdout pipe")
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrap(err, "failed to start ffmpeg")
+	}
+
+	decode := func(b []byte) float64 {
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	}
+
+	return &FFmpeg{
+		pcmReader: newPCMReader(out, channels, 4, decode),
+		cmd:       cmd,
+		out:       out,
+		rate:      rate,
+	}, nil
+}
+
+// SampleRate returns the rate ffmpeg resamples to.
+func (f *FFmpeg) SampleRate() float64 {
+	return f.rate
+}
+
+// Close stops ffmpeg. It returns the error ffmpeg failed with, if it ended on
+// its own.
+func (f *FFmpeg) Close() error {
+	f.out.Close()
+	f.cmd.Process.Kill()
+
+	err := f.cmd.Wait()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && !exitErr.Exited() {
+		// killed by us.
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,130 @@
+// Package file reads audio files for offline rendering, see catnip.Render.
+package file
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/noriah/catnip/input"
+
+	"github.com/pkg/errors"
+)
+
+// Reader reads the samples of an audio file.
+type Reader interface {
+	// SampleRate returns the rate of the samples read.
+	SampleRate() float64
+	// Read reads up to len(dst[0]) samples of each channel into dst. Channels
+	// of the file are mixed down or repeated to fill len(dst) channels. It
+	// returns the number of samples read, and io.EOF after the last one.
+	Read(dst [][]input.Sample) (int, error)
+	Close() error
+}
+
+// Open opens an audio file. WAV files are read directly; anything else, and
+// WAV encodings that are not supported, are decoded by ffmpeg at rate.
+func Open(path string, rate float64, channels int) (Reader, error) {
+	if !strings.EqualFold(filepath.Ext(path), ".wav") {
+		return OpenFFmpeg(path, rate, channels)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := NewWAV(f)
+	if errors.Is(err, ErrUnsupported) {
+		f.Close()
+		return OpenFFmpeg(path, rate, channels)
+	}
+
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+
+	return &fileReader{WAV: w, f: f}, nil
+}
+
+// fileReader is a WAV reading from a file it closes.
+type fileReader struct {
+	*WAV
+	f *os.File
+}
+
+func (r *fileReader) Close() error {
+	return r.f.Close()
+}
+
+// pcmReader reads interleaved frames of fixed size samples.
+type pcmReader struct {
+	r        *bufio.Reader
+	channels int
+	size     int // bytes per sample
+	decode   func([]byte) float64
+	raw      []byte
+	frame    []float64
+}
+
+func newPCMReader(r io.Reader, channels, size int, decode func([]byte) float64) *pcmReader {
+	return &pcmReader{
+		r:        bufio.NewReader(r),
+		channels: channels,
+		size:     size,
+		decode:   decode,
+		raw:      make([]byte, channels*size),
+		frame:    make([]float64, channels),
+	}
+}
+
+func (p *pcmReader) Read(dst [][]input.Sample) (int, error) {
+	if len(dst) == 0 {
+		return 0, nil
+	}
+
+	for n := range dst[0] {
+		if _, err := io.ReadFull(p.r, p.raw); err != nil {
+			// a cut off frame at the end is dropped.
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				err = io.EOF
+			}
+			return n, err
+		}
+
+		for ch := range p.frame {
+			p.frame[ch] = p.decode(p.raw[ch*p.size:])
+		}
+
+		mix(dst, n, p.frame)
+	}
+
+	return len(dst[0]), nil
+}
+
+// mix writes frame at idx of dst. A single dst channel gets the average of the
+// frame; otherwise channels missing from the frame repeat its last one.
+func mix(dst [][]input.Sample, idx int, frame []float64) {
+	if len(dst) == 1 && len(frame) > 1 {
+		sum := 0.0
+		for _, v := range frame {
+			sum += v
+		}
+		dst[0][idx] = sum / float64(len(frame))
+		return
+	}
+
+	for ch, buf := range dst {
+		buf[idx] = frame[intMin(ch, len(frame)-1)]
+	}
+}
+
+func intMin(x1, x2 int) int {
+	if x1 > x2 {
+		return x2
+	}
+	return x1
+}
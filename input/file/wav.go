@@ -0,0 +1,157 @@
+package file
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnsupported is returned by NewWAV for encodings it cannot read.
+var ErrUnsupported = errors.New("unsupported wav encoding")
+
+const (
+	wavPCM        = 1
+	wavFloat      = 3
+	wavExtensible = 0xfffe
+)
+
+// WAV reads the samples of a WAV file. It reads PCM of 8, 16, 24 and 32 bits,
+// and 32 and 64 bit floats.
+type WAV struct {
+	*pcmReader
+	rate     float64
+	channels int
+}
+
+// NewWAV reads the header of a WAV file from r, up to the samples.
+func NewWAV(r io.Reader) (*WAV, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, errors.Wrap(err, "failed to read header")
+	}
+
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, errors.New("not a wav file")
+	}
+
+	var (
+		format   uint16
+		channels int
+		rate     float64
+		bits     int
+		haveFmt  bool
+	)
+
+	for {
+		var head [8]byte
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return nil, errors.Wrap(err, "no data chunk")
+		}
+
+		id := string(head[0:4])
+		size := int64(binary.LittleEndian.Uint32(head[4:8]))
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, errors.New("short fmt chunk")
+			}
+
+			chunk := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return nil, errors.Wrap(err, "failed to read fmt chunk")
+			}
+
+			format = binary.LittleEndian.Uint16(chunk[0:2])
+			channels = int(binary.LittleEndian.Uint16(chunk[2:4]))
+			rate = float64(binary.LittleEndian.Uint32(chunk[4:8]))
+			bits = int(binary.LittleEndian.Uint16(chunk[14:16]))
+
+			// the real format is the start of the sub format guid.
+			if format == wavExtensible && size >= 26 {
+				format = binary.LittleEndian.Uint16(chunk[24:26])
+			}
+
+			haveFmt = true
+
+		case "data":
+			if !haveFmt {
+				return nil, errors.New("data chunk before fmt chunk")
+			}
+
+			// streamed files may not know the size.
+			if size > 0 && size != math.MaxUint32 {
+				r = io.LimitReader(r, size)
+			}
+
+			return newWAV(r, format, channels, rate, bits)
+
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, errors.Wrapf(err, "failed to skip %q chunk", id)
+			}
+		}
+	}
+}
+
+func newWAV(r io.Reader, format uint16, channels int, rate float64, bits int) (*WAV, error) {
+	if channels < 1 || rate <= 0 {
+		return nil, errors.New("invalid fmt chunk")
+	}
+
+	var decode func([]byte) float64
+
+	switch {
+	case format == wavPCM && bits == 8:
+		decode = func(b []byte) float64 {
+			return (float64(b[0]) - 128) / 128
+		}
+
+	case format == wavPCM && bits == 16:
+		decode = func(b []byte) float64 {
+			return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+		}
+
+	case format == wavPCM && bits == 24:
+		decode = func(b []byte) float64 {
+			v := int32(uint32(b[0])<<8 | uint32(b[1])<<16 | uint32(b[2])<<24)
+			return float64(v>>8) / (1 << 23)
+		}
+
+	case format == wavPCM && bits == 32:
+		decode = func(b []byte) float64 {
+			return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+		}
+
+	case format == wavFloat && bits == 32:
+		decode = func(b []byte) float64 {
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		}
+
+	case format == wavFloat && bits == 64:
+		decode = func(b []byte) float64 {
+			return math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}
+
+	default:
+		return nil, errors.Wrapf(ErrUnsupported, "format %d with %d bits", format, bits)
+	}
+
+	return &WAV{
+		pcmReader: newPCMReader(r, channels, bits/8, decode),
+		rate:      rate,
+		channels:  channels,
+	}, nil
+}
+
+// SampleRate returns the sample rate of the file.
+func (w *WAV) SampleRate() float64 {
+	return w.rate
+}
+
+// ChannelCount returns the number of channels in the file.
+func (w *WAV) ChannelCount() int {
+	return w.channels
+}
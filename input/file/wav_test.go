@@ -0,0 +1,284 @@
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/noriah/catnip/input"
+
+	"github.com/pkg/errors"
+)
+
+// testValues are exact in every encoding, as frames of two channels.
+var testValues = []float64{0, 0.5, -0.5, -1, 0.25, -0.125}
+
+// chunk returns a riff chunk, padded to an even size.
+func chunk(id string, data []byte) []byte {
+	out := append([]byte(id), make([]byte, 4)...)
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(data)))
+	out = append(out, data...)
+
+	if len(data)%2 != 0 {
+		out = append(out, 0)
+	}
+
+	return out
+}
+
+// fmtChunk returns a fmt chunk, in the extensible layout if extensible is set.
+func fmtChunk(format uint16, channels, rate, bits int, extensible bool) []byte {
+	size := 16
+	if extensible {
+		size = 40
+	}
+
+	data := make([]byte, size)
+	le := binary.LittleEndian
+
+	le.PutUint16(data[0:], format)
+	le.PutUint16(data[2:], uint16(channels))
+	le.PutUint32(data[4:], uint32(rate))
+	le.PutUint32(data[8:], uint32(rate*channels*bits/8))
+	le.PutUint16(data[12:], uint16(channels*bits/8))
+	le.PutUint16(data[14:], uint16(bits))
+
+	if extensible {
+		le.PutUint16(data[0:], wavExtensible)
+		le.PutUint16(data[16:], 22)
+		le.PutUint16(data[18:], uint16(bits))
+		le.PutUint16(data[24:], format)
+	}
+
+	return chunk("fmt ", data)
+}
+
+// riff returns a wav file of chunks.
+func riff(chunks ...[]byte) []byte {
+	body := []byte("WAVE")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+
+	return chunk("RIFF", body)
+}
+
+// encode returns values in format with bits per sample.
+func encode(format uint16, bits int, values []float64) []byte {
+	var out []byte
+	le := binary.LittleEndian
+
+	for _, v := range values {
+		b := make([]byte, bits/8)
+
+		switch {
+		case format == wavFloat && bits == 32:
+			le.PutUint32(b, math.Float32bits(float32(v)))
+		case format == wavFloat && bits == 64:
+			le.PutUint64(b, math.Float64bits(v))
+		case bits == 8:
+			b[0] = byte(v*128 + 128)
+		case bits == 16:
+			le.PutUint16(b, uint16(int16(v*(1<<15))))
+		case bits == 24:
+			i := uint32(int32(v * (1 << 23)))
+			b[0], b[1], b[2] = byte(i), byte(i>>8), byte(i>>16)
+		case bits == 32:
+			le.PutUint32(b, uint32(int32(v*(1<<31))))
+		}
+
+		out = append(out, b...)
+	}
+
+	return out
+}
+
+// readAll reads every frame of w into channels.
+func readAll(t *testing.T, w *WAV, channels int) [][]input.Sample {
+	t.Helper()
+
+	dst := input.MakeBuffers(channels, 64)
+
+	n, err := w.Read(dst)
+	if err != nil && !errors.Is(err, io.EOF) {
+		t.Fatal(err)
+	}
+
+	for ch := range dst {
+		dst[ch] = dst[ch][:n]
+	}
+
+	if n, err := w.Read(input.MakeBuffers(channels, 64)); n != 0 || !errors.Is(err, io.EOF) {
+		t.Fatalf("read %d samples and %v after the end, want 0 and EOF", n, err)
+	}
+
+	return dst
+}
+
+// checkStereo checks that samples are testValues as frames of two channels.
+func checkStereo(t *testing.T, samples [][]input.Sample) {
+	t.Helper()
+
+	if len(samples[0]) != len(testValues)/2 {
+		t.Fatalf("read %d frames, want %d", len(samples[0]), len(testValues)/2)
+	}
+
+	for idx, want := range testValues {
+		if got := samples[idx%2][idx/2]; got != want {
+			t.Errorf("sample %d of channel %d is %v, want %v", idx/2, idx%2, got, want)
+		}
+	}
+}
+
+func TestWAVFormats(t *testing.T) {
+	formats := []struct {
+		name   string
+		format uint16
+		bits   int
+	}{
+		{"pcm8", wavPCM, 8},
+		{"pcm16", wavPCM, 16},
+		{"pcm24", wavPCM, 24},
+		{"pcm32", wavPCM, 32},
+		{"float32", wavFloat, 32},
+		{"float64", wavFloat, 64},
+	}
+
+	for _, f := range formats {
+		for _, extensible := range []bool{false, true} {
+			name := f.name
+			if extensible {
+				name += "/extensible"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				data := riff(
+					fmtChunk(f.format, 2, 48000, f.bits, extensible),
+					chunk("data", encode(f.format, f.bits, testValues)),
+				)
+
+				w, err := NewWAV(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if w.SampleRate() != 48000 || w.ChannelCount() != 2 {
+					t.Fatalf("got %v Hz and %d channels, want 48000 and 2", w.SampleRate(), w.ChannelCount())
+				}
+
+				checkStereo(t, readAll(t, w, 2))
+			})
+		}
+	}
+}
+
+func TestWAVOddChunks(t *testing.T) {
+	// chunks of odd size are followed by a pad byte, which is not a sample.
+	data := riff(
+		chunk("LIST", []byte("odd")),
+		fmtChunk(wavPCM, 1, 8000, 8, false),
+		chunk("junk", []byte{1}),
+		chunk("data", encode(wavPCM, 8, []float64{0.5, -0.5, 0.25})),
+	)
+
+	w, err := NewWAV(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := readAll(t, w, 1)[0]
+	want := []float64{0.5, -0.5, 0.25}
+
+	if len(samples) != len(want) {
+		t.Fatalf("read %v, want %v", samples, want)
+	}
+
+	for idx := range want {
+		if samples[idx] != want[idx] {
+			t.Fatalf("read %v, want %v", samples, want)
+		}
+	}
+}
+
+func TestWAVMixesChannels(t *testing.T) {
+	data := riff(
+		fmtChunk(wavPCM, 2, 8000, 16, false),
+		chunk("data", encode(wavPCM, 16, []float64{0.5, -0.25, -1, 0})),
+	)
+
+	w, err := NewWAV(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := readAll(t, w, 1)[0]
+	if len(samples) != 2 || samples[0] != 0.125 || samples[1] != -0.5 {
+		t.Fatalf("read %v, want the average of each frame", samples)
+	}
+}
+
+func TestWAVErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		unsupported bool
+	}{
+		{
+			name: "data before fmt",
+			data: riff(
+				chunk("data", encode(wavPCM, 16, testValues)),
+				fmtChunk(wavPCM, 2, 48000, 16, false),
+			),
+		},
+		{
+			name: "no data",
+			data: riff(fmtChunk(wavPCM, 2, 48000, 16, false)),
+		},
+		{
+			name: "short fmt",
+			data: riff(chunk("fmt ", make([]byte, 8))),
+		},
+		{
+			name: "not riff",
+			data: append([]byte("RIFX"), riff()[4:]...),
+		},
+		{
+			name: "no channels",
+			data: riff(
+				fmtChunk(wavPCM, 0, 48000, 16, false),
+				chunk("data", nil),
+			),
+		},
+		{
+			name: "adpcm",
+			data: riff(
+				fmtChunk(2, 2, 48000, 4, false),
+				chunk("data", nil),
+			),
+			unsupported: true,
+		},
+		{
+			name: "pcm12",
+			data: riff(
+				fmtChunk(wavPCM, 2, 48000, 12, true),
+				chunk("data", nil),
+			),
+			unsupported: true,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := NewWAV(bytes.NewReader(test.data))
+
+		if err == nil {
+			t.Errorf("%s: no error", test.name)
+			continue
+		}
+
+		if got := errors.Is(err, ErrUnsupported); got != test.unsupported {
+			t.Errorf("%s: got %v, want unsupported %v", test.name, err, test.unsupported)
+		}
+	}
+}
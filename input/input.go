@@ -1,9 +1,6 @@
 package input
 
-import (
-	"context"
-	"sync"
-)
+import "context"
 
 type Device interface {
 	// String returns the device name.
@@ -15,15 +12,16 @@ type SessionConfig struct {
 	FrameSize  int     // number of channels per frame
 	SampleSize int     // number of frames per buffer write
 	SampleRate float64 // sample rate
+	Stats      *Stats  // counts of problems reading (optional)
 }
 
-// Session is the interface for an input session. Its task is to call the
-// processor everytime the buffer is full using the parameters given in
-// SessionConfig.
+// Session is the interface for an input session. Its task is to publish a
+// block to the buffer and kick the processor everytime a block is read, using
+// the parameters given in SessionConfig.
 type Session interface {
 	// Start blocks until either the context is canceled or an error is
 	// encountered.
-	Start(context.Context, [][]Sample, chan bool, *sync.Mutex) error
+	Start(context.Context, *TripleBuffer, chan bool) error
 }
 
 // Processor is called by Session everytime the buffer is full. Session may call
@@ -136,7 +136,7 @@ func NewSession(cfg input.SessionConfig) (*Session, error) {
 }
 
 // Start starts the session. It implements input.Session.
-func (s *Session) Start(ctx context.Context, dst [][]input.Sample, kickChan chan bool, mu *sync.Mutex) error {
+func (s *Session) Start(ctx context.Context, buf *input.TripleBuffer, kickChan chan bool) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -154,7 +154,7 @@ func (s *Session) Start(ctx context.Context, dst [][]input.Sample, kickChan chan
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		setErr(s.session.Start(ctx, dst, kickChan, mu))
+		setErr(s.session.Start(ctx, buf, kickChan))
 	}()
 
 	// No relinking needed if we're not connecting to a specific device.
@@ -3,7 +3,6 @@ package portaudio
 import (
 	"context"
 	"fmt"
-	"sync"
 
 	"github.com/noriah/catnip/input"
 	"github.com/noriah/catnip/input/portaudio/portaudio"
@@ -105,8 +104,8 @@ func NewSession(config input.SessionConfig) (*Session, error) {
 	}, nil
 }
 
-func (s *Session) Start(ctx context.Context, dst [][]input.Sample, kickChan chan bool, mu *sync.Mutex) error {
-	if !input.EnsureBufferLen(s.config, dst) {
+func (s *Session) Start(ctx context.Context, buf *input.TripleBuffer, kickChan chan bool) error {
+	if !input.EnsureBufferLen(s.config, buf.Back()) {
 		return errors.New("invalid dst length given")
 	}
 
@@ -141,16 +140,18 @@ func (s *Session) Start(ctx context.Context, dst [][]input.Sample, kickChan chan
 
 	for {
 
-		// Ignore overflow in case the processing is too slow.
-		if err := stream.Read(); err != nil && err != portaudio.InputOverflowed {
+		// Count overflow but carry on, in case the processing is too slow.
+		if err := stream.Read(); err == portaudio.InputOverflowed {
+			s.config.Stats.Overflow()
+		} else if err != nil {
 			return errors.Wrap(err, "failed to read stream")
 		}
 
-		mu.Lock()
+		dst := buf.Back()
 		for x := 0; x < samples; x++ {
 			dst[x%frameSize][x/frameSize] = input.Sample(src[x])
 		}
-		mu.Unlock()
+		buf.Publish()
 
 		loop := true
 		for {
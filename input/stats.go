@@ -0,0 +1,56 @@
+package input
+
+import "sync/atomic"
+
+// Stats counts problems reading input. It is safe for concurrent use, and a
+// nil *Stats counts nothing.
+type Stats struct {
+	expired   atomic.Uint64
+	overflows atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// Expire counts a read that hit its deadline and gave silence.
+func (s *Stats) Expire() {
+	if s != nil {
+		s.expired.Add(1)
+	}
+}
+
+// Overflow counts a read that lost samples because it was not made in time.
+func (s *Stats) Overflow() {
+	if s != nil {
+		s.overflows.Add(1)
+	}
+}
+
+// Drop counts a block replaced before the processor took it.
+func (s *Stats) Drop() {
+	if s != nil {
+		s.dropped.Add(1)
+	}
+}
+
+// Expired returns the number of reads that hit their deadline.
+func (s *Stats) Expired() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.expired.Load()
+}
+
+// Overflows returns the number of reads that lost samples.
+func (s *Stats) Overflows() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.overflows.Load()
+}
+
+// Dropped returns the number of blocks replaced before being processed.
+func (s *Stats) Dropped() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.dropped.Load()
+}
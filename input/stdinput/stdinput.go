@@ -6,7 +6,6 @@ import (
 	"io"
 	"math"
 	"os"
-	"sync"
 	"time"
 	_ "unsafe"
 
@@ -61,8 +60,8 @@ func NewStdinSession(cfg input.SessionConfig) *Session {
 	}
 }
 
-func (s *Session) Start(ctx context.Context, dst [][]input.Sample, kickChan chan bool, mu *sync.Mutex) error {
-	if !input.EnsureBufferLen(s.cfg, dst) {
+func (s *Session) Start(ctx context.Context, buf *input.TripleBuffer, kickChan chan bool) error {
+	if !input.EnsureBufferLen(s.cfg, buf.Back()) {
 		return errors.New("invalid dst length given")
 	}
 
@@ -120,6 +119,7 @@ func (s *Session) Start(ctx context.Context, dst [][]input.Sample, kickChan chan
 				return nil
 			case errors.Is(err, os.ErrDeadlineExceeded):
 				readExpired = true
+				s.cfg.Stats.Expire()
 			default:
 				return err
 			}
@@ -127,31 +127,33 @@ func (s *Session) Start(ctx context.Context, dst [][]input.Sample, kickChan chan
 			readExpired = false
 		}
 
+		dst := buf.Back()
+
 		if readExpired {
-			mu.Lock()
 			// We can write directly to dst just so we can avoid parsing zero
 			// bytes to floats.
-			for _, buf := range dst {
+			for _, samples := range dst {
 				// Go should optimize this to a memclr.
-				for i := range buf {
-					buf[i] = 0
+				for i := range samples {
+					samples[i] = 0
 				}
 			}
-			mu.Unlock()
 		} else {
 			reader.reset(raw)
-			mu.Lock()
 			for n := 0; n < s.samples; n++ {
 				dst[n%framesz][n/framesz] = reader.next()
 			}
-			mu.Unlock()
 		}
 
-		// Signal that we've written to dst.
+		buf.Publish()
+
+		// Signal that we've published a block. A kick still pending already
+		// covers it, and the next read must not wait on the processor.
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case kickChan <- true:
+		default:
 		}
 	}
 }
@@ -0,0 +1,100 @@
+package input
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	tripleIndex = 0b011 // index of the middle buffer
+	tripleFresh = 0b100 // set when the middle buffer was published and not taken
+)
+
+// TripleBuffer hands blocks of samples from a session to the processor without
+// locks. The session fills Back and calls Publish; the processor calls Acquire
+// to get the newest published block. Neither side ever waits for the other:
+// a block published before the last one was taken is dropped.
+//
+// One goroutine may write and one may read at a time.
+type TripleBuffer struct {
+	// Stats counts the blocks replaced before they were acquired, if set.
+	Stats *Stats
+
+	bufs  [3][][]Sample
+	times [3]time.Time // when each block was published
+	seqs  [3]uint64    // the number of each block, counting from 1
+
+	// middle is the index of the buffer between the two sides, with
+	// tripleFresh. It is the only state both sides touch.
+	middle atomic.Uint32
+
+	back      int    // owned by the writer
+	published uint64 // owned by the writer
+	front     int    // owned by the reader
+}
+
+// NewTripleBuffer creates a TripleBuffer of blocks of samples per channel.
+func NewTripleBuffer(channels, samples int) *TripleBuffer {
+	t := &TripleBuffer{back: 0, front: 1}
+
+	for idx := range t.bufs {
+		t.bufs[idx] = MakeBuffers(channels, samples)
+	}
+
+	t.middle.Store(2)
+
+	return t
+}
+
+// Back returns the buffers to write the next block to. They are only valid
+// until Publish.
+func (t *TripleBuffer) Back() [][]Sample {
+	return t.bufs[t.back]
+}
+
+// Publish makes the block written to Back the newest, and gives the writer
+// another Back.
+func (t *TripleBuffer) Publish() {
+	t.PublishAt(time.Now())
+}
+
+// PublishAt is Publish for a block captured at at, for writers that keep
+// their own time like offline rendering.
+func (t *TripleBuffer) PublishAt(at time.Time) {
+	t.published++
+	t.times[t.back] = at
+	t.seqs[t.back] = t.published
+
+	old := t.middle.Swap(uint32(t.back) | tripleFresh)
+	t.back = int(old & tripleIndex)
+
+	if old&tripleFresh != 0 {
+		t.Stats.Drop()
+	}
+}
+
+// Acquire returns the newest block, when it was published and whether that
+// was since the last Acquire. It is not written to until the next Acquire, and
+// the reader may change it in place.
+func (t *TripleBuffer) Acquire() ([][]Sample, time.Time, bool) {
+	if t.middle.Load()&tripleFresh == 0 {
+		return t.bufs[t.front], t.times[t.front], false
+	}
+
+	old := t.middle.Swap(uint32(t.front))
+	t.front = int(old & tripleIndex)
+
+	return t.bufs[t.front], t.times[t.front], true
+}
+
+// Sequence returns the number of the block returned by the last Acquire,
+// counting published blocks from 1. Blocks dropped between two Acquires leave
+// a gap in the numbers. It is 0 before the first block.
+func (t *TripleBuffer) Sequence() uint64 {
+	return t.seqs[t.front]
+}
+
+// Front returns the block returned by the last Acquire.
+func (t *TripleBuffer) Front() [][]Sample {
+	return t.bufs[t.front]
+}
@@ -0,0 +1,241 @@
+package input
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	tripleChannels = 2
+	tripleSamples  = 1024
+)
+
+// fill writes v to every sample of bufs.
+func fill(bufs [][]Sample, v Sample) {
+	for _, buf := range bufs {
+		for i := range buf {
+			buf[i] = v
+		}
+	}
+}
+
+// check returns the value of a block written by fill, and false if it is torn.
+func check(bufs [][]Sample) (Sample, bool) {
+	v := bufs[0][0]
+	for _, buf := range bufs {
+		for _, s := range buf {
+			if s != v {
+				return v, false
+			}
+		}
+	}
+	return v, true
+}
+
+func TestTripleBufferNewest(t *testing.T) {
+	tb := NewTripleBuffer(tripleChannels, tripleSamples)
+
+	if _, _, fresh := tb.Acquire(); fresh {
+		t.Fatal("fresh before the first Publish")
+	}
+
+	for v := 1; v <= 3; v++ {
+		fill(tb.Back(), Sample(v))
+		tb.Publish()
+	}
+
+	bufs, _, fresh := tb.Acquire()
+	if !fresh {
+		t.Fatal("not fresh after Publish")
+	}
+
+	if v, _ := check(bufs); v != 3 {
+		t.Fatalf("got block %v, want the newest, 3", v)
+	}
+
+	bufs, _, fresh = tb.Acquire()
+	if fresh {
+		t.Fatal("fresh twice for one Publish")
+	}
+
+	if v, _ := check(bufs); v != 3 {
+		t.Fatalf("got block %v after a stale Acquire, want 3", v)
+	}
+}
+
+func TestTripleBufferTimes(t *testing.T) {
+	tb := NewTripleBuffer(tripleChannels, tripleSamples)
+	base := time.Unix(1000, 0)
+
+	for v := 1; v <= 3; v++ {
+		fill(tb.Back(), Sample(v))
+		tb.PublishAt(base.Add(time.Duration(v) * time.Second))
+	}
+
+	want := base.Add(3 * time.Second)
+
+	for _, fresh := range []bool{true, false} {
+		_, at, got := tb.Acquire()
+		if got != fresh || !at.Equal(want) {
+			t.Fatalf("got %v, fresh %v, want %v, fresh %v", at, got, want, fresh)
+		}
+	}
+
+	before := time.Now()
+	tb.Publish()
+
+	if _, at, _ := tb.Acquire(); at.Before(before) || at.After(time.Now()) {
+		t.Fatalf("Publish timed the block %v, want the time it was called", at)
+	}
+}
+
+func TestTripleBufferSequence(t *testing.T) {
+	tb := NewTripleBuffer(tripleChannels, tripleSamples)
+
+	if tb.Sequence() != 0 {
+		t.Fatalf("sequence %d before the first block, want 0", tb.Sequence())
+	}
+
+	tb.Publish()
+	tb.Acquire()
+
+	// two blocks dropped before the fourth.
+	for v := 0; v < 3; v++ {
+		tb.Publish()
+	}
+	tb.Acquire()
+
+	if tb.Sequence() != 4 {
+		t.Fatalf("sequence %d, want 4", tb.Sequence())
+	}
+
+	// a repeated block keeps its number.
+	tb.Acquire()
+
+	if tb.Sequence() != 4 {
+		t.Fatalf("sequence %d after a stale Acquire, want 4", tb.Sequence())
+	}
+}
+
+func TestTripleBufferRace(t *testing.T) {
+	const blocks = 20000
+
+	tb := NewTripleBuffer(tripleChannels, tripleSamples)
+	base := time.Unix(1000, 0)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for v := 1; v <= blocks; v++ {
+			fill(tb.Back(), Sample(v))
+			tb.PublishAt(base.Add(time.Duration(v)))
+		}
+	}()
+
+	var last Sample
+	for last < blocks {
+		bufs, at, fresh := tb.Acquire()
+
+		v, ok := check(bufs)
+		if !ok {
+			t.Fatalf("torn block after %v", last)
+		}
+
+		if v > 0 && !at.Equal(base.Add(time.Duration(v))) {
+			t.Fatalf("block %v has the time of block %v", v, at.Sub(base))
+		}
+
+		if fresh && v <= last {
+			t.Fatalf("got block %v after %v", v, last)
+		}
+
+		if !fresh && v != last {
+			t.Fatalf("stale block changed from %v to %v", last, v)
+		}
+
+		last = v
+	}
+
+	wg.Wait()
+}
+
+// benchmarkHandOff writes b.N blocks while another goroutine keeps calling
+// read, so the time per op is how long a session takes to hand off a block.
+func benchmarkHandOff(b *testing.B, write func([][]Sample), read func()) {
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			read()
+		}
+	}()
+
+	src := MakeBuffers(tripleChannels, tripleSamples)
+	fill(src, 1)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		write(src)
+	}
+
+	b.StopTimer()
+
+	close(done)
+	wg.Wait()
+}
+
+// slowRead stands in for the fft and outputs working on a block.
+func slowRead(bufs [][]Sample) {
+	var sum Sample
+	for n := 0; n < 8; n++ {
+		for _, buf := range bufs {
+			for _, s := range buf {
+				sum += s
+			}
+		}
+	}
+	_ = sum
+}
+
+func BenchmarkHandOffTriple(b *testing.B) {
+	tb := NewTripleBuffer(tripleChannels, tripleSamples)
+
+	benchmarkHandOff(b, func(src [][]Sample) {
+		CopyBuffers(tb.Back(), src)
+		tb.Publish()
+	}, func() {
+		bufs, _, _ := tb.Acquire()
+		slowRead(bufs)
+	})
+}
+
+func BenchmarkHandOffMutex(b *testing.B) {
+	var mu sync.Mutex
+	shared := MakeBuffers(tripleChannels, tripleSamples)
+
+	benchmarkHandOff(b, func(src [][]Sample) {
+		mu.Lock()
+		CopyBuffers(shared, src)
+		mu.Unlock()
+	}, func() {
+		mu.Lock()
+		slowRead(shared)
+		mu.Unlock()
+	})
+}
@@ -0,0 +1,448 @@
+package catnip
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/noriah/catnip/dsp"
+	"github.com/noriah/catnip/processor"
+)
+
+// DefaultSubscribeBins is the number of bins per channel made for subscribers
+// when the pipeline has no output and Config.Bins is not set.
+const DefaultSubscribeBins = 64
+
+// Frame is a processed frame sent to subscribers. It is a copy owned by the
+// subscriber.
+type Frame = processor.Frame
+
+// ErrRunning is returned by Pipeline.Start if the pipeline is already running.
+var ErrRunning = errors.New("pipeline is already running")
+
+// Pipeline runs capture and analysis in the background, for programs that
+// embed catnip. Frames are read with Subscribe, and are also written to the
+// configured output if there is one.
+type Pipeline struct {
+	mu      sync.Mutex
+	cfg     Config
+	hub     *hub
+	updates chan update
+
+	parent context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// NewPipeline creates a Pipeline with cfg. It does not start it.
+func NewPipeline(cfg Config) *Pipeline {
+	return &Pipeline{
+		cfg:     cfg,
+		hub:     &hub{subs: make(map[*subscriber]struct{})},
+		updates: make(chan update),
+	}
+}
+
+// Config returns the current config.
+func (p *Pipeline) Config() Config {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.cfg
+}
+
+// Start starts the pipeline. It runs until ctx is canceled, Stop is called or
+// it fails; see Wait.
+func (p *Pipeline) Start(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.done != nil {
+		return ErrRunning
+	}
+
+	return p.start(ctx)
+}
+
+func (p *Pipeline) start(ctx context.Context) error {
+	cfg := p.runConfig(p.cfg)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	p.hub.setOutput(&p.cfg)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	p.parent, p.cancel, p.done, p.err = ctx, cancel, done, nil
+
+	go func() {
+		err := run(runCtx, cfg, p.updates)
+		cancel()
+
+		p.mu.Lock()
+		p.err = err
+		if p.done == done {
+			p.done = nil
+		}
+		p.mu.Unlock()
+
+		close(done)
+	}()
+
+	return nil
+}
+
+// runConfig returns cfg with the output replaced by the hub.
+func (p *Pipeline) runConfig(cfg Config) Config {
+	cfg.Output = nil
+	cfg.FrameOutput = p.hub
+
+	return cfg
+}
+
+// Stop stops the pipeline and waits for it to end. It returns the error the
+// run ended with, if any.
+func (p *Pipeline) Stop() error {
+	p.mu.Lock()
+	cancel, done := p.cancel, p.done
+	p.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	cancel()
+	<-done
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.err
+}
+
+// Wait waits for the pipeline to end and returns the error it ended with. It
+// returns right away if the pipeline is not running.
+func (p *Pipeline) Wait() error {
+	p.mu.Lock()
+	done := p.done
+	p.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.err
+}
+
+// Update changes the config with fn. A running pipeline is changed without
+// stopping the output, as with Control.Update, and keeps its subscriptions.
+// Changes to the output of a running pipeline are applied on the next Start.
+func (p *Pipeline) Update(fn func(*Config) error) error {
+	for {
+		p.mu.Lock()
+		done := p.done
+
+		if done == nil {
+			err := p.updateStopped(fn)
+			p.mu.Unlock()
+			return err
+		}
+
+		p.mu.Unlock()
+
+		var next Config
+
+		u := update{
+			fn: func(cfg *Config) error {
+				next = p.Config()
+				if err := fn(&next); err != nil {
+					return err
+				}
+
+				*cfg = p.runConfig(next)
+				return nil
+			},
+			done: make(chan error, 1),
+		}
+
+		select {
+		case p.updates <- u:
+		case <-done:
+			// ended before getting it, change the stopped config.
+			continue
+		}
+
+		if err := <-u.done; err != nil {
+			return err
+		}
+
+		p.mu.Lock()
+		p.cfg = next
+		p.mu.Unlock()
+
+		p.hub.setBins(next.Bins)
+
+		return nil
+	}
+}
+
+// updateStopped changes the config of a stopped pipeline. p.mu must be held.
+func (p *Pipeline) updateStopped(fn func(*Config) error) error {
+	next := p.cfg
+	if err := fn(&next); err != nil {
+		return err
+	}
+
+	check := p.runConfig(next)
+	if err := check.Validate(); err != nil {
+		return err
+	}
+
+	p.cfg = next
+	return nil
+}
+
+// Reconfigure changes the config. If it has another output a running
+// pipeline is restarted with it, otherwise it is changed live, see Update.
+// Subscriptions are kept.
+func (p *Pipeline) Reconfigure(cfg Config) error {
+	check := p.runConfig(cfg)
+	if err := check.Validate(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	running := p.done != nil
+	parent := p.parent
+	live := sameOutput(&p.cfg, &cfg)
+	p.mu.Unlock()
+
+	if live {
+		return p.Update(func(c *Config) error {
+			*c = cfg
+			return nil
+		})
+	}
+
+	if running {
+		if err := p.Stop(); err != nil {
+			return err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.cfg = cfg
+
+	if !running {
+		return nil
+	}
+
+	return p.start(parent)
+}
+
+// sameOutput reports whether a and b write to the same outputs.
+func sameOutput(a, b *Config) bool {
+	return same(a.Output, b.Output) && same(a.FrameOutput, b.FrameOutput)
+}
+
+// same reports whether a and b are equal, without panicking on values that
+// cannot be compared.
+func same(a, b any) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+
+	return a == b
+}
+
+// Subscribe returns a channel of frames. A subscriber that falls behind only
+// gets the newest frame. The channel is closed when ctx is done.
+func (p *Pipeline) Subscribe(ctx context.Context) <-chan Frame {
+	sub := &subscriber{ch: make(chan Frame, 1)}
+
+	p.hub.mu.Lock()
+	p.hub.subs[sub] = struct{}{}
+	p.hub.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		p.hub.mu.Lock()
+		delete(p.hub.subs, sub)
+		close(sub.ch)
+		p.hub.mu.Unlock()
+	}()
+
+	return sub.ch
+}
+
+type subscriber struct {
+	ch chan Frame
+}
+
+// hub is the output of a pipeline. It writes to the configured output and
+// sends a copy of every frame to the subscribers.
+type hub struct {
+	mu   sync.Mutex
+	subs map[*subscriber]struct{}
+
+	out  processor.FrameOutput
+	bins int
+}
+
+var _ processor.Wrapper = &hub{}
+var _ processor.Lifecycle = &hub{}
+var _ processor.StreamOutput = &hub{}
+
+// setOutput sets the output and bins to use for cfg.
+func (h *hub) setOutput(cfg *Config) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.out = nil
+	if cfg.Output != nil || cfg.FrameOutput != nil {
+		h.out = cfg.output()
+	}
+
+	h.bins = subscribeBins(cfg.Bins)
+}
+
+// setBins sets the bins made for subscribers when there is no output.
+func (h *hub) setBins(bins int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.bins = subscribeBins(bins)
+}
+
+func subscribeBins(bins int) int {
+	if bins <= 0 {
+		return DefaultSubscribeBins
+	}
+	return bins
+}
+
+func (h *hub) Bins(chCount int) int {
+	h.mu.Lock()
+	bins := h.bins
+	h.mu.Unlock()
+
+	if h.out != nil {
+		return h.out.Bins(chCount)
+	}
+	return bins
+}
+
+func (h *hub) WriteFrame(f *Frame) error {
+	if h.out != nil {
+		if err := h.out.WriteFrame(f); err != nil {
+			return err
+		}
+	}
+
+	h.Watch(f)
+
+	return nil
+}
+
+// Unwrap returns the configured output, so the processor can write a
+// MultiOutput or SampleOutput itself.
+func (h *hub) Unwrap() processor.FrameOutput {
+	return h.out
+}
+
+// Watching reports whether there are subscribers.
+func (h *hub) Watching() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.subs) > 0
+}
+
+// Watch sends a copy of f to every subscriber.
+func (h *hub) Watch(f *Frame) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+		send(sub.ch, copyFrame(f))
+	}
+}
+
+// send sends f to ch, replacing a frame not yet read.
+func send(ch chan Frame, f Frame) {
+	select {
+	case ch <- f:
+		return
+	default:
+	}
+
+	select {
+	case <-ch:
+	default:
+	}
+
+	select {
+	case ch <- f:
+	default:
+	}
+}
+
+func copyFrame(f *Frame) Frame {
+	c := Frame{
+		Stream:       f.Stream,
+		Sequence:     f.Sequence,
+		Time:         f.Time,
+		Bins:         make([][]float64, len(f.Bins)),
+		Peak:         append([]float64(nil), f.Peak...),
+		Bands:        append([]dsp.Band(nil), f.Bands...),
+		BandsChanged: f.BandsChanged,
+	}
+
+	for idx, buf := range f.Bins {
+		c.Bins[idx] = append([]float64(nil), buf...)
+	}
+
+	return c
+}
+
+func (h *hub) Setup(stream processor.Stream) error {
+	if lc, ok := h.out.(processor.Lifecycle); ok {
+		return lc.Setup(stream)
+	}
+	return nil
+}
+
+func (h *hub) Start(ctx context.Context) (context.Context, error) {
+	if lc, ok := h.out.(processor.Lifecycle); ok {
+		return lc.Start(ctx)
+	}
+	return ctx, nil
+}
+
+func (h *hub) Cleanup() error {
+	if lc, ok := h.out.(processor.Lifecycle); ok {
+		return lc.Cleanup()
+	}
+	return nil
+}
+
+func (h *hub) SetStream(stream processor.Stream) error {
+	if so, ok := h.out.(processor.StreamOutput); ok {
+		return so.SetStream(stream)
+	}
+	return nil
+}
@@ -0,0 +1,425 @@
+package catnip
+
+import (
+	"context"
+	"io"
+	"log"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/noriah/catnip/dsp"
+	"github.com/noriah/catnip/input"
+	"github.com/noriah/catnip/processor"
+)
+
+const testRate = 44100
+
+func init() {
+	input.RegisterBackend("test", testBackend{})
+}
+
+// testBackend captures a sine, a block every few milliseconds.
+type testBackend struct{}
+
+type testDevice struct{}
+
+func (testDevice) String() string { return "test" }
+
+func (testBackend) Init() error  { return nil }
+func (testBackend) Close() error { return nil }
+
+func (testBackend) Devices() ([]input.Device, error) {
+	return []input.Device{testDevice{}}, nil
+}
+
+func (testBackend) DefaultDevice() (input.Device, error) {
+	return testDevice{}, nil
+}
+
+func (testBackend) Start(cfg input.SessionConfig) (input.Session, error) {
+	return &testSession{cfg: cfg}, nil
+}
+
+type testSession struct {
+	cfg input.SessionConfig
+}
+
+func (s *testSession) Start(ctx context.Context, tb *input.TripleBuffer, kickChan chan bool) error {
+	ticker := time.NewTicker(2 * time.Millisecond)
+	defer ticker.Stop()
+
+	for n := 0; ; n++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		for _, buf := range tb.Back() {
+			for idx := range buf {
+				t := float64(idx+n*len(buf)) / s.cfg.SampleRate
+				buf[idx] = math.Sin(2*math.Pi*440*t) * 0.5
+			}
+		}
+		tb.Publish()
+
+		select {
+		case kickChan <- true:
+		default:
+		}
+	}
+}
+
+// recorder records what is written to it.
+type recorder struct {
+	bins int
+
+	mu      sync.Mutex
+	sizes   []int // bin count of each frame
+	streams []processor.Stream
+}
+
+func (r *recorder) Bins(int) int {
+	return r.bins
+}
+
+func (r *recorder) WriteFrame(f *Frame) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sizes = append(r.sizes, f.BinCount())
+	return nil
+}
+
+func (r *recorder) SetStream(stream processor.Stream) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.streams = append(r.streams, stream)
+	return nil
+}
+
+// frames returns the number of frames written, and checks they all had the
+// bins asked for.
+func (r *recorder) frames(t *testing.T) int {
+	t.Helper()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, size := range r.sizes {
+		if size != r.bins {
+			t.Fatalf("got a frame of %d bins, want %d", size, r.bins)
+		}
+	}
+
+	return len(r.sizes)
+}
+
+func (r *recorder) stream() processor.Stream {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.streams) == 0 {
+		return processor.Stream{}
+	}
+	return r.streams[len(r.streams)-1]
+}
+
+// sampleRecorder wants raw samples.
+type sampleRecorder struct {
+	recorder
+	samples int
+}
+
+func (r *sampleRecorder) SampleMode() processor.SampleMode {
+	return processor.SamplesRaw
+}
+
+func (r *sampleRecorder) WriteSamples([][]float64, int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.samples++
+	return nil
+}
+
+func (r *sampleRecorder) sampleWrites() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.samples
+}
+
+func newAnalyzer(size int) dsp.Analyzer {
+	return dsp.NewAnalyzer(dsp.AnalyzerConfig{
+		SampleRate: testRate,
+		SampleSize: size,
+		BinMethod:  dsp.MaxSampleValue(),
+	})
+}
+
+func newTestConfig(size int, out processor.FrameOutput) Config {
+	cfg := NewZeroConfig()
+	cfg.Backend = "test"
+	cfg.SampleRate = testRate
+	cfg.SampleSize = size
+	cfg.ProcessRate = 200
+	cfg.FrameOutput = out
+	cfg.Analyzer = newAnalyzer(size)
+
+	return cfg
+}
+
+// newMulti creates a MultiOutput for sample blocks of size.
+func newMulti(size int, outputs ...processor.FrameOutput) *processor.MultiOutput {
+	multi := processor.NewMultiOutput(processor.Factories{
+		Analyzer: func() dsp.Analyzer { return newAnalyzer(size) },
+	}, outputs...)
+	multi.Log = log.New(io.Discard, "", 0)
+
+	return multi
+}
+
+// waitFor fails t if cond is not true within a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// receive returns the next frame from ch.
+func receive(t *testing.T, ch <-chan Frame) Frame {
+	t.Helper()
+
+	select {
+	case f, ok := <-ch:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return f
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a frame")
+	}
+
+	return Frame{}
+}
+
+func startPipeline(t *testing.T, cfg Config) *Pipeline {
+	t.Helper()
+
+	p := NewPipeline(cfg)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err := p.Stop(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return p
+}
+
+func TestPipelineSubscribe(t *testing.T) {
+	cfg := newTestConfig(256, nil)
+	cfg.Bins = 8
+
+	p := startPipeline(t, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	frames := p.Subscribe(ctx)
+
+	if f := receive(t, frames); f.BinCount() != 8 {
+		t.Fatalf("got a frame of %d bins, want 8", f.BinCount())
+	}
+
+	if err := p.Start(context.Background()); err != ErrRunning {
+		t.Fatalf("second Start returned %v, want ErrRunning", err)
+	}
+
+	cancel()
+	for range frames {
+	}
+}
+
+func TestPipelineMultiOutput(t *testing.T) {
+	outputs := []*recorder{{bins: 8}, {bins: 16}}
+
+	p := startPipeline(t, newTestConfig(256, newMulti(256, outputs[0], outputs[1])))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	frames := p.Subscribe(ctx)
+
+	waitFor(t, "frames of every output", func() bool {
+		return outputs[0].frames(t) > 2 && outputs[1].frames(t) > 2
+	})
+
+	// subscribers get the frames of the first output.
+	if f := receive(t, frames); f.BinCount() != 8 {
+		t.Fatalf("subscriber got a frame of %d bins, want 8", f.BinCount())
+	}
+}
+
+func TestPipelineSampleOutput(t *testing.T) {
+	out := &sampleRecorder{recorder: recorder{bins: 16}}
+
+	p := startPipeline(t, newTestConfig(256, out))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	frames := p.Subscribe(ctx)
+
+	waitFor(t, "samples", func() bool {
+		return out.sampleWrites() > 2
+	})
+
+	// subscribers still get frames, in the bins of the output.
+	if f := receive(t, frames); f.BinCount() != 16 {
+		t.Fatalf("subscriber got a frame of %d bins, want 16", f.BinCount())
+	}
+
+	if n := out.frames(t); n != 0 {
+		t.Fatalf("got %d frames, want samples only", n)
+	}
+}
+
+func TestPipelineThreaded(t *testing.T) {
+	// four workers split the 64 bars of each of two channels in two.
+	cfg := newTestConfig(1024, &recorder{bins: 64})
+	cfg.ChannelCount = 2
+	cfg.UseThreaded = true
+	cfg.Workers = 4
+
+	p := startPipeline(t, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	frames := p.Subscribe(ctx)
+
+	for idx := 0; idx < 10; idx++ {
+		f := receive(t, frames)
+
+		if len(f.Bins) != 2 || f.BinCount() != 64 {
+			t.Fatalf("got a frame of %d channels of %d bins, want 2 of 64", len(f.Bins), f.BinCount())
+		}
+	}
+}
+
+func TestPipelineReconfigure(t *testing.T) {
+	first := &recorder{bins: 8}
+	cfg := newTestConfig(256, first)
+
+	p := startPipeline(t, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	frames := p.Subscribe(ctx)
+
+	receive(t, frames)
+
+	// the same output is changed live.
+	cfg = newTestConfig(512, first)
+	if err := p.Reconfigure(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := first.stream().SampleSize; got != 512 {
+		t.Fatalf("output stream has %d samples, want 512", got)
+	}
+
+	if got := p.Config().SampleSize; got != 512 {
+		t.Fatalf("config has %d samples, want 512", got)
+	}
+
+	// another output restarts the pipeline with it.
+	outputs := []*recorder{{bins: 16}, {bins: 4}}
+	cfg = newTestConfig(512, newMulti(512, outputs[0], outputs[1]))
+	if err := p.Reconfigure(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "frames of every new output", func() bool {
+		return outputs[0].frames(t) > 2 && outputs[1].frames(t) > 2
+	})
+
+	// drop a frame sent before the restart.
+	receive(t, frames)
+	if f := receive(t, frames); f.BinCount() != 16 {
+		t.Fatalf("subscriber got a frame of %d bins after the restart, want 16", f.BinCount())
+	}
+
+	if cfg.SampleSize = 3; p.Reconfigure(cfg) == nil {
+		t.Fatal("Reconfigure accepted a sample size of 3")
+	}
+}
+
+func TestControlUpdate(t *testing.T) {
+	outputs := []*recorder{{bins: 8}, {bins: 16}}
+
+	ctl := NewControl()
+	if err := ctl.Update(func(*Config) error { return nil }); err != ErrNotRunning {
+		t.Fatalf("Update before Run returned %v, want ErrNotRunning", err)
+	}
+
+	cfg := newTestConfig(256, newMulti(256, outputs[0], outputs[1]))
+	cfg.Control = ctl
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- Run(&cfg, ctx)
+	}()
+
+	waitFor(t, "frames of every output", func() bool {
+		return outputs[0].frames(t) > 2 && outputs[1].frames(t) > 2
+	})
+
+	err := ctl.Update(func(c *Config) error {
+		c.ProcessRate = 100
+		c.ChannelCount = 2
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for idx, out := range outputs {
+		if got := out.stream().ChannelCount; got != 2 {
+			t.Fatalf("output %d stream has %d channels, want 2", idx, got)
+		}
+	}
+
+	before := outputs[1].frames(t)
+	waitFor(t, "frames after the update", func() bool {
+		return outputs[1].frames(t) > before+2
+	})
+
+	err = ctl.Update(func(c *Config) error {
+		c.SampleSize = 3
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Update accepted a sample size of 3")
+	}
+
+	cancel()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ctl.Update(func(*Config) error { return nil }); err != ErrNotRunning {
+		t.Fatalf("Update after Run returned %v, want ErrNotRunning", err)
+	}
+}
@@ -0,0 +1,127 @@
+package processor
+
+import "github.com/noriah/catnip/dsp"
+
+// analysis turns fft output into bins for outputs that want the same number
+// of them.
+type analysis struct {
+	anlz   dsp.Analyzer
+	stages []Stage
+
+	want int // bins asked for by the output
+	bars int // bins given by the analyzer
+
+	barBufs [][]float64
+	bins    [][]float64 // barBufs cut to bars
+	peak    []float64
+	bands   []dsp.Band
+
+	seq uint64 // frame the bins were last made for
+}
+
+func newAnalysis(channelCount, sampleSize int, anlz dsp.Analyzer, stages []Stage) *analysis {
+	a := &analysis{
+		anlz:    anlz,
+		stages:  stages,
+		want:    -1,
+		barBufs: make([][]float64, channelCount),
+		bins:    make([][]float64, channelCount),
+		peak:    make([]float64, channelCount),
+	}
+
+	for idx := range a.barBufs {
+		a.barBufs[idx] = make([]float64, sampleSize)
+	}
+
+	return a
+}
+
+// resize recalculates the analyzer for n bins. It returns true if the bins
+// changed.
+func (a *analysis) resize(n int) bool {
+	if n == a.want {
+		return false
+	}
+
+	a.want = n
+	a.bars = a.anlz.Recalculate(n)
+
+	// analyzers that do not know their bands leave them unset.
+	a.bands = nil
+	if ba, ok := a.anlz.(dsp.BandAnalyzer); ok {
+		a.bands = ba.Bands()
+	}
+
+	for idx, buf := range a.barBufs {
+		a.bins[idx] = buf[:a.bars]
+	}
+
+	for _, stage := range a.stages {
+		if bs, ok := stage.(BandStage); ok {
+			bs.SetBands(a.bands)
+		}
+	}
+
+	return true
+}
+
+// process fills the bins from fftBufs, fresh if they are of a new block. The
+// analyzer runs on p if it is set, the stages always run on this goroutine.
+func (a *analysis) process(fftBufs [][]complex128, fresh bool, p *pool) error {
+	if p == nil {
+		for ch, fftBuf := range fftBufs {
+			a.bin(ch, 0, a.bars, fftBuf)
+		}
+	} else {
+		ranges := p.ranges(len(fftBufs), a.bars)
+
+		err := p.run(len(fftBufs)*ranges, func(idx int) error {
+			ch, r := idx/ranges, idx%ranges
+			lo, hi := a.bars*r/ranges, a.bars*(r+1)/ranges
+
+			return analyze(ch, func() { a.bin(ch, lo, hi, fftBufs[ch]) })
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, stage := range a.stages {
+		if bs, ok := stage.(BlockStage); ok && !fresh {
+			bs.ProcessRepeat(a.barBufs, a.bars)
+			continue
+		}
+
+		stage.Process(a.barBufs, a.bars)
+	}
+
+	for idx, buf := range a.bins {
+		peak := 0.0
+		for _, v := range buf {
+			if v > peak {
+				peak = v
+			}
+		}
+		a.peak[idx] = peak
+	}
+
+	return nil
+}
+
+// bin fills bins lo to hi of channel ch from fftBuf.
+func (a *analysis) bin(ch, lo, hi int, fftBuf []complex128) {
+	buf := a.barBufs[ch]
+
+	for bIdx := lo; bIdx < hi; bIdx++ {
+		buf[bIdx] = a.anlz.ProcessBin(bIdx, fftBuf)
+	}
+}
+
+// fill sets the bins of f.
+func (a *analysis) fill(f *Frame) {
+	f.Bins = a.bins
+	f.Peak = a.peak
+	f.Bands = a.bands
+	f.bufs = a.barBufs
+}
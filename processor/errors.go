@@ -0,0 +1,39 @@
+package processor
+
+import "fmt"
+
+// OutputError is returned when an output fails to write.
+type OutputError struct {
+	Output int // index of the output in a MultiOutput, 0 otherwise
+	Err    error
+}
+
+func (e *OutputError) Error() string {
+	return fmt.Sprintf("output %d: %v", e.Output, e.Err)
+}
+
+func (e *OutputError) Unwrap() error {
+	return e.Err
+}
+
+// AnalysisError is returned when the analysis of a channel fails.
+type AnalysisError struct {
+	Channel int
+	Err     error
+}
+
+func (e *AnalysisError) Error() string {
+	return fmt.Sprintf("channel %d: %v", e.Channel, e.Err)
+}
+
+func (e *AnalysisError) Unwrap() error {
+	return e.Err
+}
+
+// outputError wraps err of the output at idx, or returns nil.
+func outputError(idx int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &OutputError{Output: idx, Err: err}
+}
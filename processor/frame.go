@@ -0,0 +1,201 @@
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/noriah/catnip/dsp"
+)
+
+// OutputVersion is the version of the output API.
+//
+//	1: Output, written bins with Write.
+//	2: FrameOutput, written a Frame with WriteFrame, with Lifecycle hooks.
+//
+// Version 1 outputs are written through Adapt.
+const OutputVersion = 2
+
+// Stream describes the samples being processed.
+type Stream struct {
+	SampleRate   float64 // rate at which samples are read
+	SampleSize   int     // number of samples per buffer
+	ChannelCount int     // number of channels
+}
+
+// Frame is one processed frame. It and its slices are only valid during
+// WriteFrame.
+type Frame struct {
+	Stream
+
+	Sequence uint64    // number of the frame, counting from 1
+	Time     time.Time // when the newest samples were captured
+
+	Bins  [][]float64 // values of each channel, BinCount each
+	Peak  []float64   // highest value of each channel
+	Bands []dsp.Band  // frequency band of each bin, nil if not a dsp.BandAnalyzer
+
+	// BandsChanged is set on the first frame written to an output and every
+	// time the bins change.
+	BandsChanged bool
+
+	// bufs are the full bin buffers, for version 1 outputs.
+	bufs [][]float64
+}
+
+// BinCount returns the number of bins per channel.
+func (f *Frame) BinCount() int {
+	if len(f.Bins) == 0 {
+		return 0
+	}
+	return len(f.Bins[0])
+}
+
+// FrameOutput is the version 2 output. It is written a Frame with metadata
+// instead of bare buffers.
+type FrameOutput interface {
+	// Bins returns the number of bins per channel wanted for the next frame.
+	Bins(chCount int) int
+	WriteFrame(*Frame) error
+}
+
+// Lifecycle is an output with hooks for the start and end of a run. It can be
+// implemented by a FrameOutput or an Output.
+type Lifecycle interface {
+	// Setup is called once before capture starts.
+	Setup(Stream) error
+	// Start is called once capture is set up. The returned context is used for
+	// the run, so an output can end it.
+	Start(context.Context) (context.Context, error)
+	// Cleanup is called at the end of the run if Setup succeeded.
+	Cleanup() error
+}
+
+// StreamOutput is an output that follows changes to the stream of a running
+// pipeline instead of being set up again. It can be implemented by a
+// FrameOutput or an Output.
+type StreamOutput interface {
+	// SetStream is called when the pipeline is reconfigured, between the
+	// last frame of the old capture and the first of the new one.
+	SetStream(Stream) error
+}
+
+// Adapt returns a FrameOutput writing to a version 1 Output. Bands are passed
+// on to a BandOutput, and Lifecycle hooks are called if out has them.
+func Adapt(out Output) FrameOutput {
+	if fo, ok := out.(FrameOutput); ok {
+		return fo
+	}
+	return &adapter{out: out}
+}
+
+// adapter writes frames to a version 1 Output.
+type adapter struct {
+	out Output
+}
+
+func (a *adapter) Bins(chCount int) int {
+	return a.out.Bins(chCount)
+}
+
+func (a *adapter) WriteFrame(f *Frame) error {
+	if f.BandsChanged {
+		if bo, ok := a.out.(BandOutput); ok {
+			bo.SetBands(f.Bands)
+		}
+	}
+
+	return a.out.Write(f.bufs, f.ChannelCount)
+}
+
+func (a *adapter) Setup(stream Stream) error {
+	if lc, ok := a.out.(Lifecycle); ok {
+		return lc.Setup(stream)
+	}
+	return nil
+}
+
+func (a *adapter) Start(ctx context.Context) (context.Context, error) {
+	if lc, ok := a.out.(Lifecycle); ok {
+		return lc.Start(ctx)
+	}
+	return ctx, nil
+}
+
+func (a *adapter) Cleanup() error {
+	if lc, ok := a.out.(Lifecycle); ok {
+		return lc.Cleanup()
+	}
+	return nil
+}
+
+func (a *adapter) SetStream(stream Stream) error {
+	if so, ok := a.out.(StreamOutput); ok {
+		return so.SetStream(stream)
+	}
+	return nil
+}
+
+// Wrapper is a FrameOutput that writes to another one and watches its frames,
+// like the hub of a catnip.Pipeline. The processor writes to the output it
+// wraps directly if that is a MultiOutput or wants samples, and gives the
+// wrapper the frames of the first output with Watch. If that output is given
+// samples, frames are still made for Watch while Watching is true.
+type Wrapper interface {
+	FrameOutput
+
+	// Unwrap returns the wrapped output, or nil if there is none.
+	Unwrap() FrameOutput
+	// Watching reports whether Watch wants frames.
+	Watching() bool
+	// Watch is called with a frame written to the wrapped output.
+	Watch(*Frame)
+}
+
+// Unwrap returns the output an adapter or Wrapper writes to, or out itself.
+// Optional interfaces like SampleOutput are checked on it.
+func Unwrap(out FrameOutput) any {
+	for {
+		switch o := out.(type) {
+		case *adapter:
+			return o.out
+
+		case Wrapper:
+			if inner := o.Unwrap(); inner != nil {
+				out = inner
+				continue
+			}
+		}
+
+		return out
+	}
+}
+
+// watch gives f to every Wrapper out is under.
+func watch(out FrameOutput, f *Frame) {
+	for out != nil {
+		w, ok := out.(Wrapper)
+		if !ok {
+			return
+		}
+
+		w.Watch(f)
+		out = w.Unwrap()
+	}
+}
+
+// watching reports whether any Wrapper out is under wants frames.
+func watching(out FrameOutput) bool {
+	for out != nil {
+		w, ok := out.(Wrapper)
+		if !ok {
+			return false
+		}
+
+		if w.Watching() {
+			return true
+		}
+		out = w.Unwrap()
+	}
+
+	return false
+}
@@ -0,0 +1,197 @@
+package processor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/noriah/catnip/input"
+)
+
+// Stats are the timings of the frames of a processor over the last whole
+// second, and the counts of problems since it started.
+type Stats struct {
+	FPS        float64       // frames processed per second
+	TargetFPS  float64       // the ProcessRate, 0 if frames follow the input
+	Latency    time.Duration // mean time from a block being read to its frame being written
+	MaxLatency time.Duration // longest time from a block being read to its frame being written
+	FFT        time.Duration // mean time per frame preparing the samples and running the fft
+	Analyze    time.Duration // mean time per frame in the analyzer and stages
+	Output     time.Duration // mean time per frame writing to the outputs
+	Frames     uint64        // frames processed
+	Late       uint64        // frames that took longer than a frame at ProcessRate
+	Dropped    uint64        // blocks replaced before being processed
+	Expired    uint64        // reads that hit their deadline and gave silence
+	Overflows  uint64        // reads that lost samples
+}
+
+// String formats s as one line, with times in milliseconds.
+func (s Stats) String() string {
+	fps := fmt.Sprintf("%.1f", s.FPS)
+	if s.TargetFPS > 0 {
+		fps += fmt.Sprintf("/%.0f", s.TargetFPS)
+	}
+
+	return fmt.Sprintf("fps %s latency %.2fms max %.2fms fft %.2fms analyze %.2fms output %.2fms "+
+		"frames %d late %d dropped %d expired %d overflows %d",
+		fps, millis(s.Latency), millis(s.MaxLatency),
+		millis(s.FFT), millis(s.Analyze), millis(s.Output),
+		s.Frames, s.Late, s.Dropped, s.Expired, s.Overflows)
+}
+
+// millis returns d in milliseconds.
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Metrics collects the Stats of a processor. It is safe to read while the
+// processor runs, and may be kept across processors to keep counting.
+type Metrics struct {
+	// Input counts the problems of the input sessions feeding the processor.
+	Input input.Stats
+
+	mu     sync.Mutex
+	target float64
+	frames uint64
+	late   uint64
+	cur    metricsWindow
+	last   Stats
+}
+
+// metricsWindow adds up the frames of one second.
+type metricsWindow struct {
+	start      time.Time
+	frames     int
+	fresh      int
+	latency    time.Duration
+	maxLatency time.Duration
+	fft        time.Duration
+	analyze    time.Duration
+	output     time.Duration
+}
+
+// frameTimes are the times taken by one frame.
+type frameTimes struct {
+	start    time.Time
+	fftDone  time.Time
+	analyze  time.Duration
+	captured time.Time // set if the frame is of a new block
+}
+
+// NewMetrics creates empty Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+// setTarget sets the frame rate the processor aims for.
+func (m *Metrics) setTarget(rate int) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	m.target = float64(rate)
+	m.mu.Unlock()
+}
+
+// record adds a frame that ended now.
+func (m *Metrics) record(t *frameTimes) {
+	if m == nil {
+		return
+	}
+
+	m.recordAt(t, time.Now())
+}
+
+// recordAt adds a frame that ended at now.
+func (m *Metrics) recordAt(t *frameTimes, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.roll(now)
+
+	m.frames++
+	if m.target > 0 && now.Sub(t.start) > time.Duration(float64(time.Second)/m.target) {
+		m.late++
+	}
+
+	w := &m.cur
+	w.frames++
+
+	writeStart := t.fftDone
+	if writeStart.IsZero() {
+		// sample outputs are written before the fft.
+		writeStart = t.start
+	} else {
+		w.fft += t.fftDone.Sub(t.start)
+	}
+
+	w.analyze += t.analyze
+	w.output += now.Sub(writeStart) - t.analyze
+
+	if !t.captured.IsZero() {
+		latency := now.Sub(t.captured)
+
+		w.fresh++
+		w.latency += latency
+		if latency > w.maxLatency {
+			w.maxLatency = latency
+		}
+	}
+}
+
+// roll starts a new window if the current one is a second old.
+func (m *Metrics) roll(now time.Time) {
+	w := &m.cur
+
+	if w.start.IsZero() {
+		w.start = now
+		return
+	}
+
+	elapsed := now.Sub(w.start)
+	if elapsed < time.Second {
+		return
+	}
+
+	m.last = Stats{
+		FPS:        float64(w.frames) / elapsed.Seconds(),
+		MaxLatency: w.maxLatency,
+	}
+
+	if w.frames > 0 {
+		m.last.FFT = w.fft / time.Duration(w.frames)
+		m.last.Analyze = w.analyze / time.Duration(w.frames)
+		m.last.Output = w.output / time.Duration(w.frames)
+	}
+
+	if w.fresh > 0 {
+		m.last.Latency = w.latency / time.Duration(w.fresh)
+	}
+
+	*w = metricsWindow{start: now}
+}
+
+// Stats returns the stats of the last whole second.
+func (m *Metrics) Stats() Stats {
+	return m.statsAt(time.Now())
+}
+
+// statsAt returns the stats of the last whole second before now.
+func (m *Metrics) statsAt(now time.Time) Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// a stalled processor shows up as no frames.
+	m.roll(now)
+
+	s := m.last
+	s.TargetFPS = m.target
+	s.Frames = m.frames
+	s.Late = m.late
+	s.Dropped = m.Input.Dropped()
+	s.Expired = m.Input.Expired()
+	s.Overflows = m.Input.Overflows()
+
+	return s
+}
@@ -0,0 +1,175 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noriah/catnip/input"
+)
+
+var metricsBase = time.Unix(1000, 0)
+
+// at returns the time ms milliseconds after metricsBase.
+func at(ms float64) time.Time {
+	return metricsBase.Add(time.Duration(ms * float64(time.Millisecond)))
+}
+
+func TestMetricsWindow(t *testing.T) {
+	m := NewMetrics()
+	m.setTarget(100)
+
+	// ten frames over a second, ending 100ms apart. Each took 4ms: 1ms in
+	// the fft, 2ms analyzing and 1ms writing.
+	for idx := 0; idx < 10; idx++ {
+		end := float64(idx * 100)
+
+		times := frameTimes{
+			start:   at(end - 4),
+			fftDone: at(end - 3),
+			analyze: 2 * time.Millisecond,
+		}
+
+		// every other frame is of a new block, read 5ms before the end,
+		// except one read 20ms before.
+		switch {
+		case idx == 4:
+			times.captured = at(end - 20)
+		case idx%2 == 0:
+			times.captured = at(end - 5)
+		}
+
+		m.recordAt(&times, at(end))
+	}
+
+	s := m.statsAt(at(1000))
+
+	want := Stats{
+		FPS:        10,
+		TargetFPS:  100,
+		Latency:    8 * time.Millisecond, // (4*5 + 20) / 5
+		MaxLatency: 20 * time.Millisecond,
+		FFT:        time.Millisecond,
+		Analyze:    2 * time.Millisecond,
+		Output:     time.Millisecond,
+		Frames:     10,
+	}
+
+	if s != want {
+		t.Fatalf("got  %v\nwant %v", s, want)
+	}
+
+	// a stalled processor has no frames in the next window.
+	s = m.statsAt(at(2500))
+	if s.FPS != 0 || s.Latency != 0 || s.Frames != 10 {
+		t.Fatalf("got %v after a stall, want no frames in the window", s)
+	}
+}
+
+func TestMetricsLate(t *testing.T) {
+	m := NewMetrics()
+	m.setTarget(100)
+
+	for _, took := range []float64{5, 10, 11, 30} {
+		m.recordAt(&frameTimes{start: at(-took)}, at(0))
+	}
+
+	if s := m.statsAt(at(0)); s.Late != 2 || s.Frames != 4 {
+		t.Fatalf("got %d late of %d frames, want 2 of 4", s.Late, s.Frames)
+	}
+
+	// without a target rate no frame is late.
+	m = NewMetrics()
+	m.recordAt(&frameTimes{start: at(-1000)}, at(0))
+
+	if s := m.statsAt(at(0)); s.Late != 0 {
+		t.Fatalf("got %d late frames without a target, want 0", s.Late)
+	}
+}
+
+func TestMetricsSampleOutput(t *testing.T) {
+	m := NewMetrics()
+
+	// sample outputs have no fft, the whole frame is writing.
+	m.recordAt(&frameTimes{start: at(-3)}, at(0))
+
+	if s := m.statsAt(at(1000)); s.FFT != 0 || s.Output != 3*time.Millisecond {
+		t.Fatalf("got fft %v and output %v, want 0 and 3ms", s.FFT, s.Output)
+	}
+}
+
+func TestMetricsInput(t *testing.T) {
+	m := NewMetrics()
+
+	m.Input.Drop()
+	m.Input.Expire()
+	m.Input.Expire()
+	m.Input.Overflow()
+
+	s := m.Stats()
+	if s.Dropped != 1 || s.Expired != 2 || s.Overflows != 1 {
+		t.Fatalf("got %v, want 1 dropped, 2 expired and 1 overflow", s)
+	}
+
+	if got := s.String(); !strings.Contains(got, "dropped 1 expired 2 overflows 1") {
+		t.Fatalf("String() = %q", got)
+	}
+
+	// a processor without metrics records nothing.
+	var nilMetrics *Metrics
+	nilMetrics.setTarget(60)
+	nilMetrics.record(&frameTimes{start: time.Now()})
+}
+
+// timeRecorder keeps the time of every frame.
+type timeRecorder struct {
+	times []time.Time
+}
+
+func (r *timeRecorder) Bins(int) int {
+	return 16
+}
+
+func (r *timeRecorder) WriteFrame(f *Frame) error {
+	r.times = append(r.times, f.Time)
+	return nil
+}
+
+func TestMetricsLatencyFromPublish(t *testing.T) {
+	const channels, size = 1, 1024
+
+	tb := input.NewTripleBuffer(channels, size)
+	rec := &timeRecorder{}
+
+	cfg := testConfig(channels, size, 1, tb, rec)
+	cfg.Metrics = NewMetrics()
+	vis := New(cfg)
+
+	// the block is timed when it is published, not when the processor is
+	// woken for it.
+	published := time.Now().Add(-50 * time.Millisecond)
+
+	fillBlock(tb.Back(), 0)
+	tb.PublishAt(published)
+
+	if err := vis.Process(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !vis.times.captured.Equal(published) {
+		t.Fatalf("frame captured at %v, want %v", vis.times.captured, published)
+	}
+
+	// a repeat of the block has its time, but adds no latency.
+	if err := vis.Process(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !vis.times.captured.IsZero() {
+		t.Fatalf("repeated block counted as captured at %v", vis.times.captured)
+	}
+
+	if !rec.times[1].Equal(published) {
+		t.Fatalf("repeated frame timed %v, want %v", rec.times[1], published)
+	}
+}
@@ -0,0 +1,702 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noriah/catnip/dsp"
+)
+
+// Policy is what a MultiOutput does when one of its outputs fails to write.
+type Policy int
+
+const (
+	PolicyFail    Policy = iota // end the run
+	PolicyRetry                 // write the frame again, fail after RetryLimit tries
+	PolicyDrop                  // skip the frame, log the error and keep writing
+	PolicyDisable               // stop writing to the output and log the error
+)
+
+// DefaultRetryLimit is the number of tries at writing a frame after which an
+// output with PolicyRetry fails.
+const DefaultRetryLimit = 5
+
+// DefaultRetryDelay is the wait before the first retry of a frame.
+const DefaultRetryDelay = 20 * time.Millisecond
+
+// DefaultQueue is the number of frames an output after the first can fall
+// behind by.
+const DefaultQueue = 2
+
+var policyNames = [...]string{
+	PolicyFail:    "fail",
+	PolicyRetry:   "retry",
+	PolicyDrop:    "drop",
+	PolicyDisable: "disable",
+}
+
+// PolicyNames returns the names of all policies.
+func PolicyNames() []string {
+	return policyNames[:]
+}
+
+// ParsePolicy returns the policy with the given name.
+func ParsePolicy(name string) (Policy, error) {
+	for p, n := range policyNames {
+		if n == name {
+			return Policy(p), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown output policy %q (%s)", name, strings.Join(policyNames[:], ", "))
+}
+
+func (p Policy) String() string {
+	if p < 0 || int(p) >= len(policyNames) {
+		return fmt.Sprintf("Policy(%d)", int(p))
+	}
+	return policyNames[p]
+}
+
+// Factories make the analysis stages for outputs of a MultiOutput that want a
+// different number of bins than the first one. Analyzer must be set. Stages is
+// used instead of NoiseFloor and Smoother if set.
+type Factories struct {
+	Analyzer   func() dsp.Analyzer
+	NoiseFloor func() dsp.NoiseFloor
+	Smoother   func() dsp.Smoother
+	Stages     func() []Stage
+}
+
+// MultiOutput writes one capture to several outputs. Lifecycle hooks are
+// called on every output that has them.
+//
+// The first output uses the analyzer, noise floor and smoother of the
+// processor. Every other bin count asked for gets its own set from the
+// factories, shared by all outputs asking for it. What happens when an output
+// fails to write is set by its Policy, PolicyFail by default.
+//
+// The first output is written on the processor goroutine. The others are each
+// written on their own goroutine from a copy of the frame, so a slow output
+// does not hold up the rest. If one falls behind by more than Queue frames,
+// its oldest frame is dropped. The error of an output that ends the run is
+// returned by the write of the frame after it.
+type MultiOutput struct {
+	// RetryLimit is the number of tries at writing a frame after which an
+	// output with PolicyRetry fails.
+	RetryLimit int
+	// RetryDelay is the wait before the first retry of a frame, doubled for
+	// each one after. The wait ends early when the run does.
+	RetryDelay time.Duration
+	// Queue is the number of frames an output after the first can fall behind
+	// by. If 0, all outputs are written on the processor goroutine. It must be
+	// set before the first frame.
+	Queue int
+	// Log gets the errors of outputs that skip frames, retry or are disabled.
+	// If nil the standard logger is used.
+	Log *log.Logger
+
+	factories Factories
+	branches  []*branch
+	analyses  map[int]*analysis
+	first     *Frame          // the frame written to the first output, for Wrapper
+	ctx       context.Context // of the run, set by Start
+}
+
+// branch is one output of a MultiOutput.
+type branch struct {
+	out    FrameOutput
+	policy Policy
+	want   int // bins of the last write, to know when to send bands
+
+	// frames skipped in a row by PolicyDrop, only used by the writer.
+	failing int
+
+	mu    sync.Mutex
+	err   error // the error that disabled the output
+	fatal error // the error that ends the run, from the goroutine
+
+	// the goroutine writing the output, started by the first queued write.
+	cond   *sync.Cond
+	queue  []*item // oldest first, at most Queue
+	free   []*item // written or dropped items to reuse
+	busy   bool    // an item is being written
+	closed bool    // set by stop
+	done   chan struct{}
+}
+
+// item is a copy of a frame, or of the samples for a SampleOutput, owned by
+// the goroutine of a branch until it is written.
+type item struct {
+	frame   Frame
+	samples bool
+	bufs    [][]float64 // copy of the full bin buffers or of the samples
+	bins    [][]float64 // bufs cut to the bins of the frame
+	peak    []float64
+	bands   []dsp.Band
+}
+
+var _ FrameOutput = &MultiOutput{}
+var _ Lifecycle = &MultiOutput{}
+var _ StreamOutput = &MultiOutput{}
+
+// NewMultiOutput creates a MultiOutput writing to outputs. Version 1 outputs
+// can be passed through Adapt.
+func NewMultiOutput(factories Factories, outputs ...FrameOutput) *MultiOutput {
+	m := &MultiOutput{
+		RetryLimit: DefaultRetryLimit,
+		RetryDelay: DefaultRetryDelay,
+		Queue:      DefaultQueue,
+		factories:  factories,
+		branches:   make([]*branch, len(outputs)),
+		analyses:   make(map[int]*analysis),
+	}
+
+	for idx, out := range outputs {
+		m.branches[idx] = &branch{out: out, want: -1}
+	}
+
+	return m
+}
+
+// Bins returns the bins of the first output.
+func (m *MultiOutput) Bins(chCount int) int {
+	if len(m.branches) == 0 {
+		return 0
+	}
+	return m.branches[0].out.Bins(chCount)
+}
+
+// WriteFrame writes the same frame to every output. The processor does not
+// use it, it analyzes for each output.
+func (m *MultiOutput) WriteFrame(f *Frame) error {
+	var errs []error
+
+	for idx, b := range m.branches {
+		if err := b.fatalErr(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if b.disabled() {
+			continue
+		}
+
+		if err := m.send(idx, b, f); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Setup sets up every output. Outputs set up before one fails are cleaned up.
+func (m *MultiOutput) Setup(stream Stream) error {
+	for idx, b := range m.branches {
+		lc, ok := b.out.(Lifecycle)
+		if !ok {
+			continue
+		}
+
+		if err := lc.Setup(stream); err != nil {
+			m.cleanup(m.branches[:idx])
+			return fmt.Errorf("output %d: %w", idx, err)
+		}
+	}
+
+	return nil
+}
+
+// Start starts every output, each with the context of the one before.
+func (m *MultiOutput) Start(ctx context.Context) (context.Context, error) {
+	for idx, b := range m.branches {
+		lc, ok := b.out.(Lifecycle)
+		if !ok {
+			continue
+		}
+
+		var err error
+		if ctx, err = lc.Start(ctx); err != nil {
+			return ctx, fmt.Errorf("output %d: %w", idx, err)
+		}
+	}
+
+	m.ctx = ctx
+
+	return ctx, nil
+}
+
+// Cleanup waits for the queued frames to be written and cleans up every
+// output.
+func (m *MultiOutput) Cleanup() error {
+	for _, b := range m.branches {
+		b.stop()
+	}
+
+	return m.cleanup(m.branches)
+}
+
+func (m *MultiOutput) cleanup(branches []*branch) error {
+	var errs []error
+
+	for idx, b := range branches {
+		if lc, ok := b.out.(Lifecycle); ok {
+			if err := lc.Cleanup(); err != nil {
+				errs = append(errs, fmt.Errorf("output %d: %w", idx, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// SetStream drops the analyses made for the old stream, so they are made
+// again by the factories, and passes stream on to every output.
+func (m *MultiOutput) SetStream(stream Stream) error {
+	m.analyses = make(map[int]*analysis)
+
+	var errs []error
+
+	for idx, b := range m.branches {
+		b.want = -1
+
+		// frames of the old stream are written first.
+		b.wait()
+
+		if so, ok := b.out.(StreamOutput); ok {
+			if err := so.SetStream(stream); err != nil {
+				errs = append(errs, fmt.Errorf("output %d: %w", idx, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// stages returns new stages for an analysis.
+func (f *Factories) stages() []Stage {
+	if f.Stages != nil {
+		return f.Stages()
+	}
+
+	var nf dsp.NoiseFloor
+	if f.NoiseFloor != nil {
+		nf = f.NoiseFloor()
+	}
+
+	var smth dsp.Smoother
+	if f.Smoother != nil {
+		smth = f.Smoother()
+	}
+
+	return DefaultStages(nf, smth)
+}
+
+// SetPolicy sets the policy of the output at idx.
+func (m *MultiOutput) SetPolicy(idx int, policy Policy) {
+	m.branches[idx].policy = policy
+}
+
+// Errors returns the error that disabled each output, nil for outputs still
+// being written to.
+func (m *MultiOutput) Errors() []error {
+	errs := make([]error, len(m.branches))
+	for idx, b := range m.branches {
+		b.mu.Lock()
+		errs[idx] = b.err
+		b.mu.Unlock()
+	}
+	return errs
+}
+
+// wantsSamples reports whether any output wants raw samples this frame.
+func (m *MultiOutput) wantsSamples() bool {
+	for _, b := range m.branches {
+		if b.disabled() {
+			continue
+		}
+
+		if so, ok := Unwrap(b.out).(SampleOutput); ok && so.SampleMode() != SamplesNone {
+			return true
+		}
+	}
+
+	return false
+}
+
+// analysisFor returns the analysis for the output at idx asking for n bins.
+func (m *MultiOutput) analysisFor(vis *processor, idx, n int) *analysis {
+	if idx == 0 || n == vis.main.want {
+		return vis.main
+	}
+
+	a, ok := m.analyses[n]
+	if !ok {
+		a = newAnalysis(vis.channelCount, len(vis.inputBufs[0]),
+			m.factories.Analyzer(), m.factories.stages())
+		a.resize(n)
+
+		m.analyses[n] = a
+	}
+
+	return a
+}
+
+// write runs the analysis for each bin count and writes every output. It
+// returns the errors of outputs that fail the run.
+func (m *MultiOutput) write(vis *processor) error {
+	var errs []error
+
+	m.first = nil
+
+	for idx, b := range m.branches {
+		if err := b.fatalErr(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if b.disabled() {
+			continue
+		}
+
+		if err := m.writeBranch(vis, idx, b); err != nil {
+			// a failed analysis is not the fault of the output.
+			var analysisErr *AnalysisError
+			if errors.As(err, &analysisErr) {
+				return err
+			}
+
+			errs = append(errs, err)
+		}
+	}
+
+	// drop analyses no output asked for this frame.
+	for n, a := range m.analyses {
+		if a.seq != vis.seq {
+			delete(m.analyses, n)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// try calls write, and again up to RetryLimit tries in all if the output at
+// idx has PolicyRetry. It applies the policy to the last error and returns it
+// if the run should end.
+func (m *MultiOutput) try(idx int, b *branch, write func() error) error {
+	err := write()
+
+	if b.policy == PolicyRetry {
+		delay := m.RetryDelay
+		for tries := 1; err != nil && tries < m.RetryLimit; tries++ {
+			m.logf("%v (retrying, %d/%d)", outputError(idx, err), tries, m.RetryLimit)
+			if !m.sleep(delay) {
+				break
+			}
+
+			delay *= 2
+			err = write()
+		}
+	}
+
+	if err == nil {
+		if b.failing > 0 {
+			m.logf("output %d: writing again after %d skipped frames", idx, b.failing)
+			b.failing = 0
+		}
+		return nil
+	}
+
+	err = outputError(idx, err)
+
+	switch b.policy {
+	case PolicyDrop:
+		// a run of failures is logged once.
+		if b.failing == 0 {
+			m.logf("%v (skipping frames)", err)
+		}
+		b.failing++
+		return nil
+
+	case PolicyDisable:
+		b.mu.Lock()
+		b.err = err
+		b.mu.Unlock()
+
+		m.logf("%v (disabled)", err)
+		return nil
+	}
+
+	return err
+}
+
+// sleep waits for d and reports whether the run is still going.
+func (m *MultiOutput) sleep(d time.Duration) bool {
+	ctx := m.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// send writes a frame, or the samples if f is nil, to the output at idx. The
+// first output is written now, the others are queued for their goroutine.
+func (m *MultiOutput) send(idx int, b *branch, f *Frame, samples ...[]float64) error {
+	if idx == 0 || m.Queue <= 0 {
+		return m.try(idx, b, func() error {
+			if f == nil {
+				return Unwrap(b.out).(SampleOutput).WriteSamples(samples, len(samples))
+			}
+			return b.out.WriteFrame(f)
+		})
+	}
+
+	b.mu.Lock()
+	if b.done == nil {
+		b.cond = sync.NewCond(&b.mu)
+		b.done = make(chan struct{})
+
+		go m.run(idx, b)
+	}
+
+	var it *item
+	if n := len(b.free); n > 0 {
+		it, b.free = b.free[n-1], b.free[:n-1]
+	} else {
+		it = &item{}
+	}
+	b.mu.Unlock()
+
+	if f == nil {
+		it.setSamples(samples)
+	} else {
+		it.setFrame(f)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.queue) >= m.Queue {
+		old := b.queue[0]
+		b.queue = append(b.queue[:0], b.queue[1:]...)
+		b.free = append(b.free, old)
+
+		// the bands of a dropped frame still changed for the next one.
+		next := it
+		if len(b.queue) > 0 {
+			next = b.queue[0]
+		}
+
+		if !old.samples && old.frame.BandsChanged && !next.samples {
+			next.frame.BandsChanged = true
+		}
+	}
+
+	b.queue = append(b.queue, it)
+	b.cond.Broadcast()
+
+	return nil
+}
+
+// run writes the queued items of the output at idx until stop.
+func (m *MultiOutput) run(idx int, b *branch) {
+	defer close(b.done)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for {
+		for len(b.queue) == 0 && !b.closed {
+			b.cond.Wait()
+		}
+
+		if len(b.queue) == 0 {
+			return
+		}
+
+		it := b.queue[0]
+		b.queue = append(b.queue[:0], b.queue[1:]...)
+		b.busy = true
+		skip := b.err != nil || b.fatal != nil
+		b.mu.Unlock()
+
+		var err error
+		if !skip {
+			err = m.try(idx, b, func() error {
+				if it.samples {
+					return Unwrap(b.out).(SampleOutput).WriteSamples(it.bufs, len(it.bufs))
+				}
+				return b.out.WriteFrame(&it.frame)
+			})
+		}
+
+		b.mu.Lock()
+		if err != nil {
+			b.fatal = err
+		}
+		b.busy = false
+		b.free = append(b.free, it)
+		b.cond.Broadcast()
+	}
+}
+
+// wait waits for the queued items to be written.
+func (b *branch) wait() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.done != nil && (len(b.queue) > 0 || b.busy) {
+		b.cond.Wait()
+	}
+}
+
+// stop waits for the queued items to be written and ends the goroutine.
+func (b *branch) stop() {
+	b.mu.Lock()
+	done := b.done
+	if done != nil {
+		b.closed = true
+		b.cond.Broadcast()
+	}
+	b.mu.Unlock()
+
+	if done == nil {
+		return
+	}
+
+	<-done
+
+	b.mu.Lock()
+	b.done, b.closed = nil, false
+	b.mu.Unlock()
+}
+
+// disabled reports whether the output was disabled by PolicyDisable.
+func (b *branch) disabled() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.err != nil
+}
+
+// fatalErr returns the error of a queued write that ends the run.
+func (b *branch) fatalErr() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.fatal
+}
+
+// setFrame makes it a copy of f.
+func (it *item) setFrame(f *Frame) {
+	src := f.bufs
+	if src == nil {
+		src = f.Bins
+	}
+
+	it.samples = false
+	it.bufs = copyBuffers(it.bufs, src)
+	it.peak = append(it.peak[:0], f.Peak...)
+	it.bands = append(it.bands[:0], f.Bands...)
+
+	if cap(it.bins) < len(f.Bins) {
+		it.bins = make([][]float64, len(f.Bins))
+	}
+	it.bins = it.bins[:len(f.Bins)]
+
+	for ch, buf := range f.Bins {
+		it.bins[ch] = it.bufs[ch][:len(buf)]
+	}
+
+	it.frame = *f
+	it.frame.Bins = it.bins
+	it.frame.Peak = it.peak
+	it.frame.Bands = it.bands
+	it.frame.bufs = it.bufs
+}
+
+// setSamples makes it a copy of samples.
+func (it *item) setSamples(samples [][]float64) {
+	it.samples = true
+	it.bufs = copyBuffers(it.bufs, samples)
+}
+
+// copyBuffers copies src into dst, growing it if needed, and returns it.
+func copyBuffers(dst, src [][]float64) [][]float64 {
+	if cap(dst) < len(src) {
+		dst = append(dst[:cap(dst)], make([][]float64, len(src)-cap(dst))...)
+	}
+	dst = dst[:len(src)]
+
+	for ch, buf := range src {
+		dst[ch] = append(dst[ch][:0], buf...)
+	}
+
+	return dst
+}
+
+func (m *MultiOutput) logf(format string, v ...any) {
+	if m.Log != nil {
+		m.Log.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+func (m *MultiOutput) writeBranch(vis *processor, idx int, b *branch) error {
+	chCount := vis.channelCount
+
+	if so, ok := Unwrap(b.out).(SampleOutput); ok {
+		if mode := so.SampleMode(); mode != SamplesNone {
+			return m.send(idx, b, nil, vis.scope(mode, b.out.Bins(chCount))...)
+		}
+	}
+
+	n := b.out.Bins(chCount)
+	a := m.analysisFor(vis, idx, n)
+
+	changed := a == vis.main && a.resize(n)
+
+	if a.seq != vis.seq {
+		start := time.Now()
+		a.seq = vis.seq
+		err := a.process(vis.fftBufs, vis.fresh, vis.pool)
+		vis.times.analyze += time.Since(start)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	frame := vis.newFrame()
+	a.fill(&frame)
+
+	if changed || b.want != n {
+		b.want = n
+		frame.BandsChanged = true
+	}
+
+	if idx == 0 {
+		m.first = &frame
+	}
+
+	return m.send(idx, b, &frame)
+}
@@ -0,0 +1,537 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/noriah/catnip/dsp"
+	"github.com/noriah/catnip/input"
+)
+
+var errWrite = errors.New("write failed")
+
+// scriptOutput records the frames written to it. fail is called with the number
+// of the try at each frame, counting from 1, and its error returned.
+type scriptOutput struct {
+	bins  int
+	fail  func(try int) error
+	block chan struct{} // if set, every write waits on it
+
+	mu      sync.Mutex
+	tries   int
+	seqs    []uint64 // sequence of each written frame
+	sizes   []int    // bin count of each written frame
+	changed []bool   // BandsChanged of each written frame
+	lastSeq uint64
+}
+
+func (o *scriptOutput) Bins(int) int {
+	return o.bins
+}
+
+func (o *scriptOutput) WriteFrame(f *Frame) error {
+	if o.block != nil {
+		<-o.block
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if f.Sequence != o.lastSeq {
+		o.lastSeq, o.tries = f.Sequence, 0
+	}
+
+	o.tries++
+	if o.fail != nil {
+		if err := o.fail(o.tries); err != nil {
+			return err
+		}
+	}
+
+	o.seqs = append(o.seqs, f.Sequence)
+	o.sizes = append(o.sizes, f.BinCount())
+	o.changed = append(o.changed, f.BandsChanged)
+
+	return nil
+}
+
+func (o *scriptOutput) written() []uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]uint64(nil), o.seqs...)
+}
+
+// newMultiProcessor creates a processor writing to outputs through a
+// MultiOutput.
+func newMultiProcessor(outputs ...FrameOutput) (*processor, *MultiOutput, *input.TripleBuffer) {
+	const channels, size = 2, 1024
+
+	multi := NewMultiOutput(Factories{
+		Analyzer: func() dsp.Analyzer {
+			return dsp.NewAnalyzer(dsp.AnalyzerConfig{
+				SampleRate: testRate,
+				SampleSize: size,
+				BinMethod:  dsp.MaxSampleValue(),
+			})
+		},
+	}, outputs...)
+	multi.Log = log.New(io.Discard, "", 0)
+	multi.RetryDelay = time.Millisecond
+
+	tb := input.NewTripleBuffer(channels, size)
+	vis := New(testConfig(channels, size, 1, tb, multi))
+
+	return vis, multi, tb
+}
+
+// processFrames runs n frames of the test signal, and returns the first error.
+func processFrames(vis *processor, tb *input.TripleBuffer, n int) error {
+	for idx := 0; idx < n; idx++ {
+		fillBlock(tb.Back(), idx)
+		tb.Publish()
+
+		if err := vis.Process(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestMultiRetryWritesTheFrameAgain(t *testing.T) {
+	for _, idx := range []int{0, 1} {
+		outputs := []*scriptOutput{{bins: 16}, {bins: 16}}
+		outputs[idx].fail = func(try int) error {
+			if try < 3 {
+				return errWrite
+			}
+			return nil
+		}
+
+		vis, multi, tb := newMultiProcessor(outputs[0], outputs[1])
+		multi.RetryLimit = 3
+		multi.Queue = 8 // room for every frame
+		multi.SetPolicy(idx, PolicyRetry)
+
+		if err := processFrames(vis, tb, 4); err != nil {
+			t.Fatalf("output %d: %v", idx, err)
+		}
+
+		multi.Cleanup()
+
+		if got := outputs[idx].written(); len(got) != 4 || got[3] != 4 {
+			t.Errorf("output %d: wrote frames %v, want 1 to 4", idx, got)
+		}
+	}
+}
+
+func TestMultiRetryLimit(t *testing.T) {
+	out := &scriptOutput{bins: 16, fail: func(int) error { return errWrite }}
+
+	vis, multi, tb := newMultiProcessor(out)
+	multi.RetryLimit = 4
+	multi.SetPolicy(0, PolicyRetry)
+
+	var outErr *OutputError
+	if err := processFrames(vis, tb, 1); !errors.As(err, &outErr) || !errors.Is(err, errWrite) {
+		t.Fatalf("got %v, want the write error", err)
+	}
+
+	if out.tries != 4 {
+		t.Errorf("tried %d times, want 4", out.tries)
+	}
+}
+
+func TestMultiDrop(t *testing.T) {
+	// the bad output fails for a while, then writes again.
+	good := &scriptOutput{bins: 16}
+	bad := &scriptOutput{bins: 16}
+
+	var failed int
+	bad.fail = func(int) error {
+		if failed < 3 {
+			failed++
+			return errWrite
+		}
+		return nil
+	}
+
+	vis, multi, tb := newMultiProcessor(good, bad)
+	multi.Queue = 0
+	multi.SetPolicy(1, PolicyDrop)
+
+	if err := processFrames(vis, tb, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := multi.Errors(); errs[0] != nil || errs[1] != nil {
+		t.Errorf("got errors %v, want none", errs)
+	}
+
+	if got := good.written(); len(got) != 6 {
+		t.Errorf("wrote %d frames to the good output, want 6", len(got))
+	}
+
+	// only the failed frames are skipped.
+	if got := bad.written(); len(got) != 3 || got[0] != 4 {
+		t.Errorf("wrote frames %v to the bad output, want 4 to 6", got)
+	}
+}
+
+func TestMultiDisable(t *testing.T) {
+	good := &scriptOutput{bins: 16}
+	bad := &scriptOutput{bins: 16, fail: func(int) error { return errWrite }}
+
+	vis, multi, tb := newMultiProcessor(good, bad)
+	multi.SetPolicy(1, PolicyDisable)
+
+	if err := processFrames(vis, tb, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	multi.Cleanup()
+
+	if errs := multi.Errors(); errs[0] != nil || !errors.Is(errs[1], errWrite) {
+		t.Errorf("got errors %v", errs)
+	}
+
+	if got := good.written(); len(got) != 5 {
+		t.Errorf("wrote %d frames to the good output, want 5", len(got))
+	}
+
+	if bad.tries != 1 {
+		t.Errorf("disabled output tried %d times, want 1", bad.tries)
+	}
+}
+
+func TestMultiRetryBackoff(t *testing.T) {
+	out := &scriptOutput{bins: 16, fail: func(int) error { return errWrite }}
+
+	vis, multi, tb := newMultiProcessor(out)
+	multi.SetPolicy(0, PolicyRetry)
+
+	// retries wait longer each time.
+	multi.RetryLimit = 4
+	multi.RetryDelay = 10 * time.Millisecond
+
+	start := time.Now()
+	if err := processFrames(vis, tb, 1); !errors.Is(err, errWrite) {
+		t.Fatalf("got %v, want the write error", err)
+	}
+
+	if took := time.Since(start); took < 70*time.Millisecond {
+		t.Errorf("4 tries took %v, want 10+20+40ms of waits", took)
+	}
+
+	// the wait ends with the run.
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := multi.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	multi.RetryDelay = time.Hour
+	out.tries = 0
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	if err := processFrames(vis, tb, 1); !errors.Is(err, errWrite) {
+		t.Fatalf("got %v, want the write error", err)
+	}
+
+	if out.tries != 1 {
+		t.Errorf("tried %d times after the run ended, want 1", out.tries)
+	}
+}
+
+func TestMultiFail(t *testing.T) {
+	// a queued output fails the run on the frame after.
+	out := &scriptOutput{bins: 16, fail: func(int) error { return errWrite }}
+
+	vis, multi, tb := newMultiProcessor(&scriptOutput{bins: 16}, out)
+	defer multi.Cleanup()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	var err error
+	for err == nil && time.Now().Before(deadline) {
+		err = processFrames(vis, tb, 1)
+	}
+
+	var outErr *OutputError
+	if !errors.As(err, &outErr) || outErr.Output != 1 {
+		t.Errorf("got %v, want an error of output 1", err)
+	}
+}
+
+func TestMultiSlowOutput(t *testing.T) {
+	fast := &scriptOutput{bins: 16}
+	slow := &scriptOutput{bins: 16, block: make(chan struct{})}
+
+	vis, multi, tb := newMultiProcessor(fast, slow)
+
+	done := make(chan error)
+	go func() {
+		done <- processFrames(vis, tb, 20)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a blocked output held up the processor")
+	}
+
+	close(slow.block)
+	multi.Cleanup()
+
+	if got := fast.written(); len(got) != 20 {
+		t.Errorf("wrote %d frames to the fast output, want 20", len(got))
+	}
+
+	// the one being written when it blocked, and the newest Queue frames.
+	got := slow.written()
+	if len(got) > DefaultQueue+1 || got[len(got)-1] != 20 {
+		t.Errorf("wrote frames %v to the slow output, want the newest", got)
+	}
+}
+
+func TestMultiBinCounts(t *testing.T) {
+	outputs := []*scriptOutput{{bins: 16}, {bins: 48}, {bins: 48}}
+
+	vis, multi, tb := newMultiProcessor(outputs[0], outputs[1], outputs[2])
+	multi.Queue = 0
+
+	if err := processFrames(vis, tb, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// outputs asking for the same count share an analysis.
+	if len(multi.analyses) != 1 {
+		t.Errorf("%d extra analyses, want 1", len(multi.analyses))
+	}
+
+	small, large := outputs[0].sizes[0], outputs[1].sizes[0]
+	if small == 0 || small >= large {
+		t.Fatalf("got %d and %d bins for 16 and 48", small, large)
+	}
+
+	for idx, want := range []int{small, large, large} {
+		for _, n := range outputs[idx].sizes {
+			if n != want {
+				t.Errorf("output %d: got a frame of %d bins, want %d", idx, n, want)
+			}
+		}
+	}
+
+	// the extra analysis of a count no output asks for anymore is dropped.
+	outputs[1].bins, outputs[2].bins = 16, 16
+
+	if err := processFrames(vis, tb, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(multi.analyses) != 0 {
+		t.Errorf("%d extra analyses left", len(multi.analyses))
+	}
+
+	for idx, out := range outputs {
+		if got := out.sizes[len(out.sizes)-1]; got != small {
+			t.Errorf("output %d: got %d bins after the change, want %d", idx, got, small)
+		}
+	}
+}
+
+func TestMultiBandsChanged(t *testing.T) {
+	main := &scriptOutput{bins: 16}
+	slow := &scriptOutput{bins: 48, block: make(chan struct{})}
+
+	vis, multi, tb := newMultiProcessor(main, slow)
+
+	// the slow output drops frames, the change must not be dropped with them.
+	if err := processFrames(vis, tb, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	main.bins, slow.bins = 24, 64
+
+	if err := processFrames(vis, tb, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	close(slow.block)
+	multi.Cleanup()
+
+	for idx, out := range []*scriptOutput{main, slow} {
+		changes := 0
+
+		// the first frame and every frame of a new count, whichever frames
+		// were dropped between them.
+		for n, changed := range out.changed {
+			want := n == 0 || out.sizes[n] != out.sizes[n-1]
+			if changed != want {
+				t.Errorf("output %d: frame %d of %d bins has BandsChanged %v", idx, n, out.sizes[n], changed)
+			}
+
+			if changed {
+				changes++
+			}
+		}
+
+		if changes == 0 || changes > 2 {
+			t.Errorf("output %d: bands sent %d times", idx, changes)
+		}
+	}
+
+	if main.sizes[0] == main.sizes[len(main.sizes)-1] {
+		t.Error("main output did not change bins")
+	}
+}
+
+// wrapOutput writes to out and records the frames it watches, like the hub of
+// a pipeline.
+type wrapOutput struct {
+	out      FrameOutput
+	watching bool // frames are wanted when out is given samples
+	watched  scriptOutput
+}
+
+func (w *wrapOutput) Bins(chCount int) int {
+	return w.out.Bins(chCount)
+}
+
+func (w *wrapOutput) WriteFrame(f *Frame) error {
+	if err := w.out.WriteFrame(f); err != nil {
+		return err
+	}
+
+	w.Watch(f)
+	return nil
+}
+
+func (w *wrapOutput) Unwrap() FrameOutput {
+	return w.out
+}
+
+func (w *wrapOutput) Watching() bool {
+	return w.watching
+}
+
+func (w *wrapOutput) Watch(f *Frame) {
+	w.watched.WriteFrame(f)
+}
+
+// sampleOutput wants raw samples.
+type sampleOutput struct {
+	scriptOutput
+	samples int
+}
+
+func (o *sampleOutput) SampleMode() SampleMode {
+	return SamplesRaw
+}
+
+func (o *sampleOutput) WriteSamples([][]float64, int) error {
+	o.samples++
+	return nil
+}
+
+func TestMultiUnderWrapper(t *testing.T) {
+	outputs := []*scriptOutput{{bins: 8}, {bins: 16}}
+
+	vis, multi, tb := newMultiProcessor(outputs[0], outputs[1])
+	multi.Queue = 0
+
+	wrap := &wrapOutput{out: multi}
+	vis.out = wrap
+
+	if err := processFrames(vis, tb, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	for idx, out := range outputs {
+		for _, size := range out.sizes {
+			if size != out.bins {
+				t.Fatalf("output %d got %d bins, want %d", idx, size, out.bins)
+			}
+		}
+
+		if len(out.sizes) != 3 {
+			t.Fatalf("output %d got %d frames, want 3", idx, len(out.sizes))
+		}
+	}
+
+	if got := wrap.watched.sizes; len(got) != 3 || got[0] != 8 {
+		t.Fatalf("wrapper watched frames of %v bins, want 3 of 8", got)
+	}
+
+	if !wrap.watched.changed[0] || wrap.watched.changed[1] {
+		t.Errorf("watched BandsChanged %v, want only the first", wrap.watched.changed)
+	}
+}
+
+func TestSampleOutputUnderWrapper(t *testing.T) {
+	const channels, size = 1, 1024
+
+	for _, watching := range []bool{false, true} {
+		out := &sampleOutput{scriptOutput: scriptOutput{bins: 16}}
+		wrap := &wrapOutput{out: out, watching: watching}
+
+		tb := input.NewTripleBuffer(channels, size)
+		vis := New(testConfig(channels, size, 1, tb, wrap))
+
+		if err := processFrames(vis, tb, 3); err != nil {
+			t.Fatal(err)
+		}
+
+		if out.samples != 3 || len(out.seqs) != 0 {
+			t.Fatalf("got %d sample writes and %d frames, want 3 and 0", out.samples, len(out.seqs))
+		}
+
+		want := 0
+		if watching {
+			want = 3
+		}
+
+		if got := wrap.watched.sizes; len(got) != want {
+			t.Fatalf("watching %v: watched %d frames, want %d", watching, len(got), want)
+		}
+
+		for _, n := range wrap.watched.sizes {
+			if n != 16 {
+				t.Fatalf("watched a frame of %d bins, want 16", n)
+			}
+		}
+	}
+}
+
+func TestMultiSampleOutputUnderWrapper(t *testing.T) {
+	first := &sampleOutput{scriptOutput: scriptOutput{bins: 8}}
+	second := &scriptOutput{bins: 16}
+
+	vis, multi, tb := newMultiProcessor(first, second)
+	multi.Queue = 0
+
+	wrap := &wrapOutput{out: multi, watching: true}
+	vis.out = wrap
+
+	if err := processFrames(vis, tb, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if first.samples != 3 || len(second.seqs) != 3 {
+		t.Fatalf("got %d sample writes and %d frames, want 3 and 3", first.samples, len(second.seqs))
+	}
+
+	// the watcher follows the first output, in bins.
+	if got := wrap.watched.sizes; len(got) != 3 || got[0] != 8 {
+		t.Fatalf("watched frames of %v bins, want 3 of 8", got)
+	}
+}
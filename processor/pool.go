@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// splitBars is the fewest bars in a range a channel is split into when there
+// are more workers than channels, so each job is worth handing to a worker.
+const splitBars = 16
+
+// pool runs the jobs of a frame on a fixed number of goroutines. The workers
+// are started by the first run and ended by close, so none is left waiting on
+// a frame that will not come.
+type pool struct {
+	size int
+	jobs chan int
+	fn   func(int) error
+	errs []error
+
+	pending sync.WaitGroup // jobs of the current run
+	workers sync.WaitGroup
+}
+
+// newPool creates a pool of size workers, or GOMAXPROCS if size is 0 or less.
+func newPool(size int) *pool {
+	if size <= 0 {
+		size = runtime.GOMAXPROCS(0)
+	}
+
+	return &pool{size: size}
+}
+
+// run calls fn(0) to fn(n-1) on the workers, waits for them and returns their
+// errors. It must not be called by more than one goroutine at a time.
+func (p *pool) run(n int, fn func(int) error) error {
+	if p.jobs == nil {
+		p.start()
+	}
+
+	if cap(p.errs) < n {
+		p.errs = make([]error, n)
+	}
+	p.errs = p.errs[:n]
+
+	p.fn = fn
+	p.pending.Add(n)
+
+	for idx := 0; idx < n; idx++ {
+		p.jobs <- idx
+	}
+
+	p.pending.Wait()
+	p.fn = nil
+
+	return errors.Join(p.errs...)
+}
+
+func (p *pool) start() {
+	p.jobs = make(chan int, p.size)
+	p.workers.Add(p.size)
+
+	for i := 0; i < p.size; i++ {
+		go p.work()
+	}
+}
+
+func (p *pool) work() {
+	defer p.workers.Done()
+
+	for idx := range p.jobs {
+		p.errs[idx] = p.fn(idx)
+		p.pending.Done()
+	}
+}
+
+// close ends the workers. The next run starts them again.
+func (p *pool) close() {
+	if p.jobs == nil {
+		return
+	}
+
+	close(p.jobs)
+	p.workers.Wait()
+	p.jobs = nil
+}
+
+// ranges returns how many bar ranges to split each of channels into. Channels
+// are only split if there are workers left over, into ranges of at least
+// splitBars bars.
+func (p *pool) ranges(channels, bars int) int {
+	n := p.size / channels
+	if byBars := bars / splitBars; byBars < n {
+		n = byBars
+	}
+
+	if n < 1 {
+		return 1
+	}
+
+	return n
+}
+
+// analyze calls fn for channel ch, and returns a panic as an *AnalysisError
+// so it ends the run instead of the program.
+func analyze(ch int, fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &AnalysisError{Channel: ch, Err: fmt.Errorf("panic: %v", r)}
+		}
+	}()
+
+	fn()
+
+	return nil
+}
@@ -2,60 +2,144 @@ package processor
 
 import (
 	"context"
-	"sync"
 	"time"
 
 	"github.com/noriah/catnip/dsp"
+	"github.com/noriah/catnip/dsp/features"
+	"github.com/noriah/catnip/dsp/filter"
+	"github.com/noriah/catnip/dsp/meter"
 	"github.com/noriah/catnip/dsp/window"
 	"github.com/noriah/catnip/fft"
 	"github.com/noriah/catnip/input"
 )
 
+// Output is the version 1 output. New outputs should implement FrameOutput.
 type Output interface {
 	Bins(int) int
 	Write([][]float64, int) error
 }
 
+// SampleMode is how a SampleOutput wants the raw samples.
+type SampleMode int
+
+const (
+	SamplesNone      SampleMode = iota // write bins, not samples
+	SamplesRaw                         // all samples as they were read
+	SamplesDecimated                   // the last half of the samples, reduced to Bins points
+	SamplesTriggered                   // like SamplesDecimated, starting at a rising zero crossing
+)
+
+// SampleOutput is an output that can also draw the raw time domain samples.
+type SampleOutput interface {
+	// SampleMode returns how the next frame should be written. Anything other
+	// than SamplesNone calls WriteSamples instead of Write.
+	SampleMode() SampleMode
+	WriteSamples([][]float64, int) error
+}
+
+// BandOutput is an Output that wants to know the frequency band of each bin.
+// A FrameOutput gets them in the Frame.
+type BandOutput interface {
+	Output
+	// SetBands is called with the bands of the analyzer every time the
+	// number of bins changes, before the next Write.
+	SetBands([]dsp.Band)
+}
+
 type Processor interface {
-	Start(ctx context.Context, kickChan chan bool, mu *sync.Mutex) context.Context
+	// Start starts processing. kickChan is sent to when a block is
+	// published. The returned context is canceled with the error if
+	// processing fails, see context.Cause.
+	Start(ctx context.Context, kickChan chan bool) context.Context
 	Stop()
-	Process()
+	Process() error
 }
 
 type Config struct {
-	SampleRate   float64          // rate at which samples are read
-	SampleSize   int              // number of samples per buffer
-	ChannelCount int              // number of channels
-	ProcessRate  int              // target framerate
-	Buffers      [][]input.Sample // sample buffers
-	Analyzer     dsp.Analyzer     // audio analyzer
-	Output       Output           // data output
-	Smoother     dsp.Smoother     // time smoother
-	Windower     window.Function  // data windower
+	SampleRate   float64             // rate at which samples are read
+	SampleSize   int                 // number of samples per buffer
+	ChannelCount int                 // number of channels
+	ProcessRate  int                 // target framerate
+	Input        *input.TripleBuffer // blocks of samples from the input session
+	Analyzer     dsp.Analyzer        // audio analyzer
+	Output       Output              // data output, version 1
+	FrameOutput  FrameOutput         // data output, used instead of Output if set
+	Smoother     dsp.Smoother        // time smoother
+	NoiseFloor   dsp.NoiseFloor      // noise floor removal, before smoothing
+	Stages       []Stage             // stages run on the bins, used instead of NoiseFloor and Smoother if set
+	Windower     window.Windower     // data windower
+	Filter       *filter.Chain       // pre-filter, run on new samples before windowing
+	Meter        *meter.Meter        // level meter, fed raw samples
+	Features     *features.Extractor // spectral features, fed fft output of new blocks
+	Key          *dsp.KeyTracker     // key estimate, fed fft output of new blocks
+	Metrics      *Metrics            // collects timings of the frames (optional)
+	Workers      int                 // goroutines of the threaded processor (0 for GOMAXPROCS)
 }
 
 type processor struct {
 	channelCount int
 	processRate  int
 
-	bars int
-
 	fftBufs [][]complex128
-	barBufs [][]float64
 
-	// Double-buffer the audio samples so we can read on it again while the code
-	// is processing it.
+	// main analysis, with the configured analyzer, noise floor and smoother.
+	main *analysis
+
+	stream Stream
+
+	// seq counts the calls to Process.
+	seq uint64
+
+	// fresh is set if the block of the current frame is new.
+	fresh bool
+
+	// captured is when the block of the current frame was published.
+	captured time.Time
+
+	metrics *Metrics
+	times   frameTimes // of the frame being processed
+
+	// copy of the raw samples for sample outputs, taken before windowing.
+	sampleBufs [][]input.Sample
+	scopeBufs  [][]input.Sample
+
+	// blocks published by the input session.
+	in *input.TripleBuffer
+
+	// copy of the newest block, windowed in place for the fft plans.
 	inputBufs [][]input.Sample
 
 	plans []*fft.Plan
 
-	mu        *sync.Mutex
-	ctxCancel context.CancelFunc
+	// runs the fft and analyzer, on this goroutine if nil.
+	pool *pool
+
+	ctxCancel context.CancelCauseFunc
+	done      chan struct{} // closed when run returns
 
-	anlz  dsp.Analyzer
-	out   Output
-	smth  dsp.Smoother
-	wndwr window.Function
+	out   FrameOutput
+	wndwr window.Windower
+	fltr  *filter.Chain
+	mtr   *meter.Meter
+	block uint64 // sequence of the last block metered
+	feat  *features.Extractor
+	key   *dsp.KeyTracker
+}
+
+// stages returns the stages to run on the bins.
+func (cfg *Config) stages() []Stage {
+	if cfg.Stages != nil {
+		return cfg.Stages
+	}
+	return DefaultStages(cfg.NoiseFloor, cfg.Smoother)
+}
+
+// output returns the output to write frames to.
+func (cfg *Config) output() FrameOutput {
+	if cfg.FrameOutput != nil {
+		return cfg.FrameOutput
+	}
+	return Adapt(cfg.Output)
 }
 
 func New(cfg Config) *processor {
@@ -64,39 +148,61 @@ func New(cfg Config) *processor {
 		channelCount: cfg.ChannelCount,
 		processRate:  cfg.ProcessRate,
 		fftBufs:      make([][]complex128, cfg.ChannelCount),
-		barBufs:      make([][]float64, cfg.ChannelCount),
-		inputBufs:    cfg.Buffers,
+		sampleBufs:   input.MakeBuffers(cfg.ChannelCount, cfg.SampleSize),
+		scopeBufs:    input.MakeBuffers(cfg.ChannelCount, cfg.SampleSize),
+		in:           cfg.Input,
+		inputBufs:    input.MakeBuffers(cfg.ChannelCount, cfg.SampleSize),
 		plans:        make([]*fft.Plan, cfg.ChannelCount),
-		anlz:         cfg.Analyzer,
-		out:          cfg.Output,
-		smth:         cfg.Smoother,
+		out:          cfg.output(),
 		wndwr:        cfg.Windower,
+		fltr:         cfg.Filter,
+		mtr:          cfg.Meter,
+		feat:         cfg.Features,
+		key:          cfg.Key,
+		metrics:      cfg.Metrics,
+		main: newAnalysis(cfg.ChannelCount, cfg.SampleSize,
+			cfg.Analyzer, cfg.stages()),
+		stream: Stream{
+			SampleRate:   cfg.SampleRate,
+			SampleSize:   cfg.SampleSize,
+			ChannelCount: cfg.ChannelCount,
+		},
 	}
 
-	for idx := range vis.barBufs {
-		vis.barBufs[idx] = make([]float64, cfg.SampleSize)
+	for idx := range vis.fftBufs {
 		vis.fftBufs[idx] = make([]complex128, cfg.SampleSize/2+1)
 
 		fft.InitPlan(&vis.plans[idx], vis.inputBufs[idx], vis.fftBufs[idx])
 	}
 
+	cfg.Metrics.setTarget(cfg.ProcessRate)
+
 	return vis
 }
 
-func (vis *processor) Start(ctx context.Context, kickChan chan bool, mu *sync.Mutex) context.Context {
-	newCtx, cancel := context.WithCancel(ctx)
+func (vis *processor) Start(ctx context.Context, kickChan chan bool) context.Context {
+	newCtx, cancel := context.WithCancelCause(ctx)
 	vis.ctxCancel = cancel
-	vis.mu = mu
+	vis.done = make(chan struct{})
 	go vis.run(newCtx, kickChan)
 
 	return newCtx
 }
 
+// Stop stops the processor and waits for the frame being processed, so the
+// output is not written to after it returns.
 func (vis *processor) Stop() {
-	vis.ctxCancel()
+	if vis.done == nil {
+		return
+	}
+
+	vis.ctxCancel(nil)
+	<-vis.done
 }
 
 func (vis *processor) run(ctx context.Context, kickChan chan bool) {
+	defer close(vis.done)
+
 	if vis.processRate <= 0 {
 		// if we do not have a framerate set, allow at most 1 second per sampling
 		vis.processRate = 1
@@ -107,7 +213,11 @@ func (vis *processor) run(ctx context.Context, kickChan chan bool) {
 	defer ticker.Stop()
 
 	for {
-		vis.Process()
+		if err := vis.Process(); err != nil {
+			vis.ctxCancel(err)
+			return
+		}
+
 		select {
 		case <-ctx.Done():
 			return
@@ -120,31 +230,201 @@ func (vis *processor) run(ctx context.Context, kickChan chan bool) {
 }
 
 // Process runs processing on sample sets and calls Write on the output once per sample set.
-func (vis *processor) Process() {
-	vis.mu.Lock()
-	for idx := range vis.barBufs {
+// It returns an *OutputError if the output fails.
+func (vis *processor) Process() error {
+	vis.times = frameTimes{start: time.Now()}
+	defer vis.metrics.record(&vis.times)
+
+	// the block is ours until the next Acquire, the input never waits on it.
+	samples, captured, fresh := vis.in.Acquire()
+	vis.fresh, vis.captured = fresh, captured
+	if fresh {
+		vis.times.captured = captured
+	}
+
+	// stateful stages that need contiguous blocks only see each block once.
+	if fresh {
+		// meter before filtering so levels are of the real signal.
+		if vis.mtr != nil {
+			vis.meter(samples)
+		}
+
+		if vis.fltr != nil {
+			vis.fltr.Process(samples)
+		}
+	}
+
+	vis.seq++
+
+	// a wrapper is looked through, it only watches the frames.
+	multi, isMulti := Unwrap(vis.out).(*MultiOutput)
+
+	// set if the output was given samples, and the frame is only watched.
+	sampled := false
+
+	switch {
+	case isMulti:
+		if multi.wantsSamples() {
+			input.CopyBuffers(vis.sampleBufs, samples)
+		}
+
+	default:
+		if so, ok := Unwrap(vis.out).(SampleOutput); ok {
+			if mode := so.SampleMode(); mode != SamplesNone {
+				input.CopyBuffers(vis.sampleBufs, samples)
+
+				err := so.WriteSamples(vis.scope(mode, vis.out.Bins(vis.channelCount)), vis.channelCount)
+				if err != nil || !watching(vis.out) {
+					return outputError(0, err)
+				}
+
+				sampled = true
+			}
+		}
+	}
+
+	if err := vis.transform(samples); err != nil {
+		return err
+	}
+
+	// flux compares blocks, a repeat of the last one would read as no change.
+	if fresh && vis.feat != nil {
+		vis.feat.Process(vis.fftBufs)
+	}
+
+	if fresh && vis.key != nil {
+		vis.key.Process(vis.fftBufs)
+	}
+
+	vis.times.fftDone = time.Now()
+
+	if isMulti {
+		if err := multi.write(vis); err != nil {
+			return err
+		}
+
+		switch {
+		case multi.first != nil:
+			watch(vis.out, multi.first)
+
+		case watching(vis.out):
+			// the first output was given samples.
+			frame, err := vis.analyzeMain(multi.Bins(vis.channelCount))
+			if err != nil {
+				return err
+			}
+			watch(vis.out, &frame)
+		}
+
+		return nil
+	}
+
+	frame, err := vis.analyzeMain(vis.out.Bins(vis.channelCount))
+	if err != nil {
+		return err
+	}
+
+	if sampled {
+		watch(vis.out, &frame)
+		return nil
+	}
+
+	return outputError(0, vis.out.WriteFrame(&frame))
+}
+
+// analyzeMain returns the frame of the main analysis with bins per channel. The
+// analysis runs once per frame, even if it is asked for again.
+func (vis *processor) analyzeMain(bins int) (Frame, error) {
+	frame := vis.newFrame()
+	frame.BandsChanged = vis.main.resize(bins)
+
+	if vis.main.seq != vis.seq {
+		start := time.Now()
+		vis.main.seq = vis.seq
+		err := vis.main.process(vis.fftBufs, vis.fresh, vis.pool)
+		vis.times.analyze += time.Since(start)
+
+		if err != nil {
+			return frame, err
+		}
+	}
+
+	vis.main.fill(&frame)
+
+	return frame, nil
+}
+
+// meter feeds a new block to the meter, telling it of the blocks the input
+// dropped since the last one.
+func (vis *processor) meter(samples [][]input.Sample) {
+	seq := vis.in.Sequence()
+	if vis.block > 0 && seq > vis.block+1 {
+		vis.mtr.Skip(seq - vis.block - 1)
+	}
+	vis.block = seq
+
+	vis.mtr.Process(samples)
+}
+
+// transform windows a copy of each channel of samples and runs its fft.
+func (vis *processor) transform(samples [][]input.Sample) error {
+	fn := func(ch int) error {
+		copy(vis.inputBufs[ch], samples[ch])
+
 		if vis.wndwr != nil {
-			vis.wndwr(vis.inputBufs[idx])
+			vis.wndwr.Apply(vis.inputBufs[ch])
 		}
-		vis.plans[idx].Execute()
+		vis.plans[ch].Execute()
+
+		return nil
 	}
-	vis.mu.Unlock()
 
-	if n := vis.out.Bins(vis.channelCount); n != vis.bars {
-		vis.bars = vis.anlz.Recalculate(n)
+	if vis.pool == nil {
+		for ch := range vis.fftBufs {
+			fn(ch)
+		}
+		return nil
 	}
 
-	for idx, fftBuf := range vis.fftBufs {
-		buf := vis.barBufs[idx]
+	return vis.pool.run(len(vis.fftBufs), func(ch int) error {
+		return analyze(ch, func() { fn(ch) })
+	})
+}
+
+// newFrame returns a frame for the current Process, without bins.
+func (vis *processor) newFrame() Frame {
+	return Frame{
+		Stream:   vis.stream,
+		Sequence: vis.seq,
+		Time:     vis.captured,
+	}
+}
 
-		for bIdx := range buf[:vis.bars] {
-			buf[bIdx] = vis.anlz.ProcessBin(bIdx, fftBuf)
+// scope prepares the copied samples for a sample output.
+func (vis *processor) scope(mode SampleMode, points int) [][]float64 {
+	if mode == SamplesRaw {
+		return vis.sampleBufs
+	}
+
+	size := len(vis.sampleBufs[0])
+	span := size / 2
+	start := size - span
+
+	// align all channels to the first rising zero crossing of the first one.
+	if mode == SamplesTriggered {
+		if idx := dsp.FindTrigger(vis.sampleBufs[0], size-span); idx >= 0 {
+			start = idx
 		}
 	}
 
-	if vis.smth != nil {
-		vis.smth.SmoothBuffers(vis.barBufs)
+	if points <= 0 || points > span {
+		points = span
+	}
+
+	for idx, buf := range vis.sampleBufs {
+		vis.scopeBufs[idx] = vis.scopeBufs[idx][:points]
+		dsp.Decimate(vis.scopeBufs[idx], buf[start:start+span])
 	}
 
-	vis.out.Write(vis.barBufs, vis.channelCount)
+	return vis.scopeBufs
 }
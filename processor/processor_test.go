@@ -1,9 +1,9 @@
 package processor
 
 import (
-	"sync"
 	"testing"
 
+	"github.com/noriah/catnip/dsp"
 	"github.com/noriah/catnip/input"
 )
 
@@ -44,19 +44,16 @@ type Analyzer interface {
 
 func BenchmarkSlices(b *testing.B) {
 
-	inputBuffers := input.MakeBuffers(ChCount, BinSize)
-
 	cfg := Config{
 		SampleRate:   122880.0,
 		SampleSize:   BinSize,
 		ChannelCount: ChCount,
-		Buffers:      inputBuffers,
+		Input:        input.NewTripleBuffer(ChCount, BinSize),
 		Output:       &testOutput{},
 		Analyzer:     &testAnalyzer{},
 	}
 
 	proc := New(cfg)
-	proc.mu = &sync.Mutex{}
 
 	b.ResetTimer()
 
@@ -64,3 +61,26 @@ func BenchmarkSlices(b *testing.B) {
 		proc.Process()
 	}
 }
+
+func TestAnalysisBands(t *testing.T) {
+	weighting := dsp.NewWeighting(dsp.WeightingA)
+
+	// an analyzer that does not know its bands leaves them unset.
+	a := newAnalysis(ChCount, BinSize, &testAnalyzer{}, []Stage{weighting})
+	a.resize(8)
+
+	if a.bands != nil {
+		t.Fatalf("got bands %v from an analyzer without them", a.bands)
+	}
+
+	a = newAnalysis(ChCount, BinSize, dsp.NewAnalyzer(dsp.AnalyzerConfig{
+		SampleRate: 48000,
+		SampleSize: BinSize,
+		BinMethod:  dsp.MaxSampleValue(),
+	}), []Stage{weighting})
+	a.resize(8)
+
+	if len(a.bands) != a.bars {
+		t.Fatalf("got %d bands for %d bars", len(a.bands), a.bars)
+	}
+}
@@ -0,0 +1,107 @@
+package processor
+
+import (
+	"github.com/noriah/catnip/dsp"
+	"github.com/noriah/catnip/dsp/scale"
+)
+
+// Stage transforms the bins between the analyzer and the output. Stages run in
+// order and may keep state across frames, so every set of bins (see
+// MultiOutput) needs its own.
+type Stage interface {
+	// Process changes the first bins values of each buffer in place.
+	Process(bufs [][]float64, bins int)
+}
+
+// BandStage is a Stage that wants to know the frequency band of each bin.
+type BandStage interface {
+	Stage
+	// SetBands is called with the bands of the analyzer every time the
+	// number of bins changes, before the next Process. They are nil if the
+	// analyzer is not a dsp.BandAnalyzer.
+	SetBands([]dsp.Band)
+}
+
+// BlockStage is a Stage that learns from the signal, and so must learn from
+// each block once.
+type BlockStage interface {
+	Stage
+	// ProcessRepeat is called instead of Process when the bins are of the same
+	// block as the last frame. It changes them as Process would, without
+	// learning from them.
+	ProcessRepeat(bufs [][]float64, bins int)
+}
+
+// StageFunc is a function used as a Stage.
+type StageFunc func(bufs [][]float64, bins int)
+
+func (f StageFunc) Process(bufs [][]float64, bins int) {
+	f(bufs, bins)
+}
+
+// DefaultStages returns the stages used if none are configured: the noise floor
+// then the smoother. Either may be nil.
+func DefaultStages(nf dsp.NoiseFloor, smth dsp.Smoother) []Stage {
+	var stages []Stage
+
+	if nf != nil {
+		stages = append(stages, NoiseFloorStage(nf))
+	}
+
+	if smth != nil {
+		stages = append(stages, SmootherStage(smth))
+	}
+
+	return stages
+}
+
+// NoiseFloorStage removes the noise floor with nf, learning it from new blocks.
+// The floor is learned again when the bins change.
+func NoiseFloorStage(nf dsp.NoiseFloor) Stage {
+	return noiseFloorStage{nf}
+}
+
+type noiseFloorStage struct {
+	nf dsp.NoiseFloor
+}
+
+func (s noiseFloorStage) Process(bufs [][]float64, bins int) {
+	s.nf.ProcessBuffers(bufs, bins)
+}
+
+func (s noiseFloorStage) ProcessRepeat(bufs [][]float64, bins int) {
+	s.nf.RemoveBuffers(bufs, bins)
+}
+
+// SetBands drops the floor, as it is of other bins.
+func (s noiseFloorStage) SetBands([]dsp.Band) {
+	s.nf.Reset()
+}
+
+// SmootherStage smooths over time with smth. The smoother works on whole
+// buffers.
+func SmootherStage(smth dsp.Smoother) Stage {
+	return StageFunc(func(bufs [][]float64, _ int) {
+		smth.SmoothBuffers(bufs)
+	})
+}
+
+// ScaleStage scales the bins with s, for outputs that do not scale themselves.
+func ScaleStage(s *scale.Scaler) Stage {
+	return StageFunc(func(bufs [][]float64, bins int) {
+		scales := s.Update(bufs, bins)
+
+		for ch, buf := range bufs {
+			for idx := range buf[:bins] {
+				buf[idx] /= scales[ch]
+			}
+		}
+	})
+}
+
+var (
+	_ BlockStage = noiseFloorStage{}
+	_ BandStage  = noiseFloorStage{}
+	_ BandStage  = &dsp.PeakHold{}
+	_ BandStage  = &dsp.Weighting{}
+)
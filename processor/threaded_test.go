@@ -0,0 +1,396 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/noriah/catnip/dsp"
+	"github.com/noriah/catnip/dsp/features"
+	"github.com/noriah/catnip/dsp/meter"
+	"github.com/noriah/catnip/dsp/window"
+	"github.com/noriah/catnip/input"
+)
+
+const testRate = 48000
+
+// frameRecorder keeps a copy of the bins of every frame.
+type frameRecorder struct {
+	bins int
+
+	mu     sync.Mutex
+	frames [][][]float64
+}
+
+func (r *frameRecorder) Bins(int) int {
+	return r.bins
+}
+
+func (r *frameRecorder) WriteFrame(f *Frame) error {
+	bins := make([][]float64, len(f.Bins))
+	for ch, buf := range f.Bins {
+		bins[ch] = append([]float64(nil), buf...)
+	}
+
+	r.mu.Lock()
+	r.frames = append(r.frames, bins)
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *frameRecorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.frames)
+}
+
+// panicAnalyzer fails every bin.
+type panicAnalyzer struct {
+	testAnalyzer
+}
+
+func (panicAnalyzer) ProcessBin(int, []complex128) float64 {
+	panic("bad bin")
+}
+
+// fillBlock writes block n of a test signal, different for every channel.
+func fillBlock(dst [][]input.Sample, n int) {
+	for ch, buf := range dst {
+		freq := float64(200*(ch+1)+50*(n%5)) / testRate
+		for i := range buf {
+			buf[i] = math.Sin(2*math.Pi*freq*float64(i+n*len(buf))) * 0.5
+		}
+	}
+}
+
+// testConfig returns a config with a real analyzer, a window and a stateful
+// smoothing stage.
+func testConfig(channels, size, workers int, tb *input.TripleBuffer, out FrameOutput) Config {
+	return Config{
+		SampleRate:   testRate,
+		SampleSize:   size,
+		ChannelCount: channels,
+		ProcessRate:  200,
+		Input:        tb,
+		FrameOutput:  out,
+		Workers:      workers,
+		Windower:     window.Hann(),
+		Analyzer: dsp.NewAnalyzer(dsp.AnalyzerConfig{
+			SampleRate: testRate,
+			SampleSize: size,
+			BinMethod:  dsp.MaxSampleValue(),
+		}),
+		Stages: DefaultStages(nil, dsp.NewSmoother(dsp.SmootherConfig{
+			SampleRate:      testRate,
+			SampleSize:      size,
+			ChannelCount:    channels,
+			SmoothingFactor: 0.5,
+			SmoothingMethod: dsp.SmoothNew,
+		})),
+	}
+}
+
+// process runs frames of the test signal through a new processor.
+func process(t *testing.T, threaded bool, channels, size, workers, frames int) [][][]float64 {
+	tb := input.NewTripleBuffer(channels, size)
+	rec := &frameRecorder{bins: 64}
+	cfg := testConfig(channels, size, workers, tb, rec)
+
+	var vis Processor
+	if threaded {
+		vis = NewThreaded(cfg)
+	} else {
+		vis = New(cfg)
+	}
+	defer vis.Stop()
+
+	for n := 0; n < frames; n++ {
+		fillBlock(tb.Back(), n)
+		tb.Publish()
+
+		if err := vis.Process(); err != nil {
+			t.Fatalf("frame %d: %v", n, err)
+		}
+	}
+
+	return rec.frames
+}
+
+func TestThreadedMatchesSingle(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		channels int
+		size     int
+		workers  int
+	}{
+		{"stereo", 2, 1024, 2},
+		{"more channels than workers", 5, 1024, 3},
+		{"split bars", 2, 2048, 8},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			want := process(t, false, tc.channels, tc.size, tc.workers, 6)
+			got := process(t, true, tc.channels, tc.size, tc.workers, 6)
+
+			if len(got) != len(want) {
+				t.Fatalf("got %d frames, want %d", len(got), len(want))
+			}
+
+			signal := false
+
+			for n := range want {
+				for ch := range want[n] {
+					for idx, v := range want[n][ch] {
+						// the smoother gives NaN for silent bins.
+						if math.Float64bits(got[n][ch][idx]) != math.Float64bits(v) {
+							t.Fatalf("frame %d channel %d bin %d: got %v, want %v",
+								n, ch, idx, got[n][ch][idx], v)
+						}
+
+						signal = signal || v > 0
+					}
+				}
+			}
+
+			if !signal {
+				t.Fatal("all bins are zero")
+			}
+		})
+	}
+}
+
+func TestPoolRanges(t *testing.T) {
+	p := newPool(8)
+
+	if n := p.ranges(2, 64); n != 4 {
+		t.Errorf("stereo bars split into %d ranges, want 4", n)
+	}
+
+	if n := p.ranges(1, 24); n != 1 {
+		t.Errorf("%d bars split into %d ranges, want 1", 24, n)
+	}
+
+	if n := p.ranges(8, 256); n != 1 {
+		t.Errorf("channels without spare workers split into %d ranges", n)
+	}
+}
+
+func TestThreadedStartStop(t *testing.T) {
+	const channels, size = 2, 1024
+
+	tb := input.NewTripleBuffer(channels, size)
+	rec := &frameRecorder{bins: 64}
+
+	cfg := testConfig(channels, size, 4, tb, rec)
+	cfg.Metrics = NewMetrics()
+
+	vis := NewThreaded(cfg)
+
+	// the bars of each channel are split over the spare workers.
+	if n := vis.pool.ranges(channels, rec.bins); n < 2 {
+		t.Fatalf("bars split into %d ranges, want more than 1", n)
+	}
+
+	kickChan := make(chan bool, 1)
+	ctx := vis.Start(context.Background(), kickChan)
+
+	// the input and stats readers keep going while the processor stops.
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		for n := 0; ; n++ {
+			fillBlock(tb.Back(), n)
+			tb.Publish()
+
+			select {
+			case <-stop:
+				return
+			case kickChan <- true:
+			default:
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				cfg.Metrics.Stats()
+			}
+		}
+	}()
+
+	for rec.count() < 10 {
+		time.Sleep(time.Millisecond)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		vis.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return")
+	}
+
+	close(stop)
+	wg.Wait()
+
+	if vis.pool.jobs != nil {
+		t.Error("workers still running after Stop")
+	}
+
+	if cause := context.Cause(ctx); !errors.Is(cause, context.Canceled) {
+		t.Errorf("ended with %v", cause)
+	}
+
+	// no frame is written after Stop.
+	count := rec.count()
+	time.Sleep(10 * time.Millisecond)
+
+	if rec.count() != count {
+		t.Error("frames written after Stop")
+	}
+}
+
+func TestThreadedAnalysisPanic(t *testing.T) {
+	const channels, size = 2, 1024
+
+	tb := input.NewTripleBuffer(channels, size)
+
+	cfg := testConfig(channels, size, 2, tb, &frameRecorder{bins: 16})
+	cfg.Analyzer = &panicAnalyzer{}
+
+	vis := NewThreaded(cfg)
+	defer vis.Stop()
+
+	var analysisErr *AnalysisError
+	if err := vis.Process(); !errors.As(err, &analysisErr) {
+		t.Fatalf("got %v, want an *AnalysisError", err)
+	}
+}
+
+func TestFeaturesOnNewBlocks(t *testing.T) {
+	const channels, size = 1, 1024
+
+	tb := input.NewTripleBuffer(channels, size)
+
+	cfg := testConfig(channels, size, 1, tb, &frameRecorder{bins: 16})
+	cfg.Features = features.New(features.Config{
+		SampleRate:   testRate,
+		SampleSize:   size,
+		ChannelCount: channels,
+	})
+
+	vis := New(cfg)
+	defer vis.Stop()
+
+	for n := 0; n < 2; n++ {
+		fillBlock(tb.Back(), n)
+		tb.Publish()
+
+		if err := vis.Process(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	flux := cfg.Features.Features()[0].Flux
+	if flux <= 0 {
+		t.Fatalf("flux %v between different blocks", flux)
+	}
+
+	// a frame without a new block repeats the last one.
+	if err := vis.Process(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cfg.Features.Features()[0].Flux; got != flux {
+		t.Errorf("flux changed from %v to %v without a new block", flux, got)
+	}
+}
+
+func TestMeterGaps(t *testing.T) {
+	const channels, size = 1, 1024
+
+	tb := input.NewTripleBuffer(channels, size)
+
+	cfg := testConfig(channels, size, 1, tb, &frameRecorder{bins: 16})
+	cfg.Meter = meter.New(meter.Config{
+		SampleRate:   testRate,
+		ChannelCount: channels,
+	})
+
+	vis := New(cfg)
+	defer vis.Stop()
+
+	// the first block is metered, then two are dropped before the fourth.
+	for _, published := range []int{1, 3, 0} {
+		for n := 0; n < published; n++ {
+			fillBlock(tb.Back(), n)
+			tb.Publish()
+		}
+
+		if err := vis.Process(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if gaps := cfg.Meter.Levels().Gaps; gaps != 2 {
+		t.Fatalf("meter counted %d gaps, want 2", gaps)
+	}
+}
+
+// blockRecorder counts the calls of a BlockStage.
+type blockRecorder struct {
+	fresh, repeats int
+}
+
+func (r *blockRecorder) Process([][]float64, int) {
+	r.fresh++
+}
+
+func (r *blockRecorder) ProcessRepeat([][]float64, int) {
+	r.repeats++
+}
+
+func TestBlockStageRepeats(t *testing.T) {
+	const channels, size = 1, 1024
+
+	tb := input.NewTripleBuffer(channels, size)
+	rec := &blockRecorder{}
+
+	cfg := testConfig(channels, size, 1, tb, &frameRecorder{bins: 16})
+	cfg.Stages = []Stage{rec}
+
+	vis := New(cfg)
+	defer vis.Stop()
+
+	fillBlock(tb.Back(), 0)
+	tb.Publish()
+
+	for n := 0; n < 3; n++ {
+		if err := vis.Process(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if rec.fresh != 1 || rec.repeats != 2 {
+		t.Errorf("got %d new blocks and %d repeats, want 1 and 2", rec.fresh, rec.repeats)
+	}
+}
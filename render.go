@@ -0,0 +1,166 @@
+package catnip
+
+import (
+	"context"
+	"io"
+	"math"
+	"time"
+
+	"github.com/noriah/catnip/input"
+	"github.com/noriah/catnip/processor"
+
+	"github.com/pkg/errors"
+)
+
+// Source is the audio read by Render, see the input/file package.
+type Source interface {
+	// Read reads up to len(dst[0]) samples of each channel into dst. It
+	// returns the number read, and io.EOF after the last one.
+	Read(dst [][]input.Sample) (int, error)
+}
+
+// RenderConfig is the timeline of Render.
+type RenderConfig struct {
+	// Frames per second of the timeline
+	FrameRate float64
+	// Time of the first sample of the source, frames are timed from it
+	Start time.Time
+}
+
+// Render runs the pipeline over src as fast as it can instead of in real time.
+// Frame n is made from the SampleSize samples up to n/FrameRate seconds into
+// src and is timed at that point, so the same config and source always give
+// the same frames. Samples after the last whole frame are dropped.
+//
+// The filter and meter see every sample once, even if frames overlap. Backend,
+// Device, ProcessRate, UseThreaded, Control and Metrics are not used.
+func Render(cfg *Config, ctx context.Context, src Source, rcfg RenderConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	if rcfg.FrameRate <= 0 {
+		return errors.New("frame rate must be above 0")
+	}
+
+	output := cfg.output()
+
+	ctx, cleanup, err := startOutput(ctx, cfg, output)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// the newest SampleSize samples, filtered.
+	history := input.MakeBuffers(cfg.ChannelCount, cfg.SampleSize)
+	// hands each frame's history to the processor.
+	frames := input.NewTripleBuffer(cfg.ChannelCount, cfg.SampleSize)
+	// samples read since the last frame.
+	block := input.MakeBuffers(cfg.ChannelCount, cfg.SampleSize)
+	read := make([][]input.Sample, cfg.ChannelCount)
+
+	vis := processor.New(processor.Config{
+		SampleRate:   cfg.SampleRate,
+		SampleSize:   cfg.SampleSize,
+		ChannelCount: cfg.ChannelCount,
+		Input:        frames,
+		Analyzer:     cfg.Analyzer,
+		FrameOutput:  output,
+		Smoother:     cfg.Smoother,
+		NoiseFloor:   cfg.NoiseFloor,
+		Stages:       cfg.Stages,
+		Windower:     cfg.Windower,
+		Features:     cfg.Features,
+		Key:          cfg.Key,
+	})
+
+	var pos int64 // samples read
+
+	for frame := int64(1); ; frame++ {
+		end := int64(math.Round(float64(frame) * cfg.SampleRate / rcfg.FrameRate))
+
+		for pos < end {
+			n := int(end - pos)
+			if n > cfg.SampleSize {
+				n = cfg.SampleSize
+			}
+
+			for ch := range read {
+				read[ch] = block[ch][:n]
+			}
+
+			n, err := readFull(src, read)
+
+			if n > 0 {
+				for ch := range read {
+					read[ch] = read[ch][:n]
+				}
+
+				if cfg.Meter != nil {
+					cfg.Meter.Process(read)
+				}
+
+				if cfg.Filter != nil {
+					cfg.Filter.Process(read)
+				}
+
+				for ch, buf := range history {
+					copy(buf, buf[n:])
+					copy(buf[len(buf)-n:], read[ch])
+				}
+
+				pos += int64(n)
+			}
+
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			if err != nil {
+				return errors.Wrap(err, "failed to read the source")
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			if cause := context.Cause(ctx); !errors.Is(cause, context.Canceled) {
+				return cause
+			}
+			return nil
+		}
+
+		at := time.Duration(float64(pos) / cfg.SampleRate * float64(time.Second))
+
+		input.CopyBuffers(frames.Back(), history)
+		frames.PublishAt(rcfg.Start.Add(at))
+
+		if err := vis.Process(); err != nil {
+			return errors.Wrap(err, "processing failed")
+		}
+	}
+}
+
+// readFull reads until dst is full, src ends or fails.
+func readFull(src Source, dst [][]input.Sample) (int, error) {
+	total := len(dst[0])
+	part := make([][]input.Sample, len(dst))
+
+	n := 0
+	for n < total {
+		for ch := range part {
+			part[ch] = dst[ch][n:]
+		}
+
+		read, err := src.Read(part)
+		n += read
+
+		if err != nil {
+			return n, err
+		}
+
+		if read == 0 {
+			return n, io.ErrNoProgress
+		}
+	}
+
+	return n, nil
+}
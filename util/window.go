@@ -136,6 +136,27 @@ func (mw *MovingWindow) Recalculate() (mean float64, stddev float64) {
 	return mw.Stats()
 }
 
+// Min returns the smallest value in the window, or 0 if it is empty.
+func (mw *MovingWindow) Min() float64 {
+	if mw.length <= 0 {
+		return 0.0
+	}
+
+	min := math.Inf(1)
+
+	for count := mw.length; count > 0; count-- {
+		idx := (mw.index - count)
+
+		if idx < 0 {
+			idx = mw.capacity + idx
+		}
+
+		min = math.Min(min, mw.data[idx])
+	}
+
+	return min
+}
+
 // Len returns how many items in the window
 func (mw *MovingWindow) Len() int {
 	// logical length